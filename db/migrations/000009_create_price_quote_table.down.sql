@@ -0,0 +1 @@
+DROP TABLE IF EXISTS price_quote;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS price_quote (
+  id SERIAL NOT NULL PRIMARY KEY,
+  patient_id INT NOT NULL,
+  items JSONB NOT NULL,
+  total_price DECIMAL(12) NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)
\ No newline at end of file
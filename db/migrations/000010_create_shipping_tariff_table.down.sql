@@ -0,0 +1 @@
+DROP TABLE IF EXISTS shipping_tariff;
\ No newline at end of file
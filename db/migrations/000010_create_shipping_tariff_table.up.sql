@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS shipping_tariff (
+  id SERIAL NOT NULL PRIMARY KEY,
+  sub_district VARCHAR(255) NULL,
+  min_distance_km NUMERIC(8,2) NOT NULL DEFAULT 0,
+  max_distance_km NUMERIC(8,2) NOT NULL DEFAULT 0,
+  price DECIMAL(12) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO shipping_tariff (min_distance_km, max_distance_km, price) VALUES
+  (0, 3, 10000),
+  (3, 7, 15000),
+  (7, 15, 25000),
+  (15, 30, 40000);
\ No newline at end of file
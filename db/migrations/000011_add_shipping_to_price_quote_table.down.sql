@@ -0,0 +1,3 @@
+ALTER TABLE price_quote
+  DROP COLUMN IF EXISTS patient_address_id,
+  DROP COLUMN IF EXISTS shipping_cost;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE price_quote
+  ADD COLUMN IF NOT EXISTS patient_address_id INT NULL,
+  ADD COLUMN IF NOT EXISTS shipping_cost DECIMAL(12) NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS payment_event (
+  id SERIAL NOT NULL PRIMARY KEY,
+  callback_id VARCHAR(255) NOT NULL UNIQUE,
+  invoice_id VARCHAR(255) NOT NULL,
+  external_id VARCHAR(255) NOT NULL,
+  status VARCHAR(255) NOT NULL,
+  raw_payload JSONB NOT NULL,
+  result VARCHAR(255) NULL,
+  note TEXT NULL,
+  received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  processed_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS payment_event_invoice_id_idx ON payment_event (invoice_id);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS reconciliation_discrepancy;
\ No newline at end of file
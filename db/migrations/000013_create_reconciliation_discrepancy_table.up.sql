@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS reconciliation_discrepancy (
+  id SERIAL NOT NULL PRIMARY KEY,
+  payment_id INT NOT NULL,
+  transaction_id INT NOT NULL,
+  invoice_id VARCHAR(255) NOT NULL,
+  payment_status VARCHAR(255) NOT NULL,
+  invoice_status VARCHAR(255) NOT NULL,
+  result VARCHAR(255) NOT NULL,
+  note TEXT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS reconciliation_discrepancy_payment_id_idx ON reconciliation_discrepancy (payment_id);
\ No newline at end of file
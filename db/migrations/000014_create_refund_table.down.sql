@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS refund_detail;
+DROP TABLE IF EXISTS refund;
\ No newline at end of file
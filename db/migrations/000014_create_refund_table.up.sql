@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS refund (
+  id SERIAL NOT NULL PRIMARY KEY,
+  transaction_id INT NOT NULL,
+  payment_id INT NOT NULL,
+  partner_id VARCHAR(255) NULL,
+  amount DECIMAL(12) NOT NULL,
+  reason TEXT NOT NULL,
+  status VARCHAR(255) NOT NULL,
+  failure_code VARCHAR(255) NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS refund_transaction_id_idx ON refund (transaction_id);
+
+CREATE TABLE IF NOT EXISTS refund_detail (
+  id SERIAL NOT NULL PRIMARY KEY,
+  refund_id INT NOT NULL,
+  transaction_detail_id INT NOT NULL,
+  amount DECIMAL(12) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS refund_detail_refund_id_idx ON refund_detail (refund_id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE payment
+  DROP COLUMN IF EXISTS method,
+  DROP COLUMN IF EXISTS channel_code;
\ No newline at end of file
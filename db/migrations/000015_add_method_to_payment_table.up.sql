@@ -0,0 +1,3 @@
+ALTER TABLE payment
+  ADD COLUMN IF NOT EXISTS method VARCHAR(255) NOT NULL DEFAULT 'INVOICE',
+  ADD COLUMN IF NOT EXISTS channel_code VARCHAR(255) NULL;
\ No newline at end of file
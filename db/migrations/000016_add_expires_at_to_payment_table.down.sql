@@ -0,0 +1,2 @@
+ALTER TABLE payment
+  DROP COLUMN IF EXISTS expires_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE payment
+  ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE patient
+  DROP COLUMN IF EXISTS email;
\ No newline at end of file
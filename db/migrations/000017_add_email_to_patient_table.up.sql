@@ -0,0 +1,2 @@
+ALTER TABLE patient
+  ADD COLUMN IF NOT EXISTS email VARCHAR(255) NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE payment
+  DROP COLUMN IF EXISTS gateway;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE payment
+  ADD COLUMN IF NOT EXISTS gateway VARCHAR(255) NOT NULL DEFAULT 'XENDIT';
\ No newline at end of file
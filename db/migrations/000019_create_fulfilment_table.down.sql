@@ -0,0 +1 @@
+DROP TABLE IF EXISTS fulfilment;
\ No newline at end of file
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS fulfilment (
+  id SERIAL NOT NULL PRIMARY KEY,
+  transaction_id INT NOT NULL,
+  status VARCHAR(255) NOT NULL,
+  paid_at TIMESTAMPTZ NOT NULL,
+  preparing_at TIMESTAMPTZ NULL,
+  shipped_at TIMESTAMPTZ NULL,
+  delivered_at TIMESTAMPTZ NULL,
+  returned_at TIMESTAMPTZ NULL,
+  note TEXT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS fulfilment_transaction_id_idx ON fulfilment (transaction_id);
+
+INSERT INTO fulfilment (transaction_id, status, paid_at)
+SELECT
+  t.id,
+  'PAID',
+  COALESCE(p.completed_at, t.updated_at, t.created_at)
+FROM
+  transaction t
+JOIN
+  payment p ON p.transaction_id = t.id
+WHERE
+  t.status IN ('SUCCESS', 'PARTIALLY_REFUNDED')
+ON CONFLICT (transaction_id) DO NOTHING;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS shipment;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS shipment (
+  id SERIAL NOT NULL PRIMARY KEY,
+  transaction_id INT NOT NULL,
+  partner_id VARCHAR(255) NOT NULL,
+  tracking_number VARCHAR(255) NOT NULL,
+  tracking_url TEXT NULL,
+  courier_status VARCHAR(255) NOT NULL,
+  price DECIMAL(12) NOT NULL,
+  courier_updated_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS shipment_transaction_id_idx ON shipment (transaction_id);
+CREATE UNIQUE INDEX IF NOT EXISTS shipment_partner_id_idx ON shipment (partner_id);
\ No newline at end of file
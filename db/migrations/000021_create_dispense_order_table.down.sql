@@ -0,0 +1 @@
+DROP TABLE IF EXISTS dispense_order;
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS dispense_order (
+  id SERIAL NOT NULL PRIMARY KEY,
+  transaction_id INT NOT NULL,
+  status VARCHAR(255) NOT NULL,
+  partner_id VARCHAR(255) NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_error TEXT NULL,
+  submitted_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS dispense_order_transaction_id_idx ON dispense_order (transaction_id);
+
+CREATE INDEX IF NOT EXISTS dispense_order_status_next_attempt_at_idx ON dispense_order (status, next_attempt_at);
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS outbox (
+  id SERIAL NOT NULL PRIMARY KEY,
+  event_type VARCHAR(255) NOT NULL,
+  aggregate_id VARCHAR(255) NOT NULL,
+  payload JSONB NOT NULL,
+  status VARCHAR(255) NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_error TEXT NULL,
+  dispatched_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS outbox_status_next_attempt_at_idx ON outbox (status, next_attempt_at);
\ No newline at end of file
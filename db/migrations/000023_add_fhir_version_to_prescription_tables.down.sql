@@ -0,0 +1,25 @@
+DROP TABLE IF EXISTS idempotency_key;
+
+DROP INDEX IF EXISTS medication_request_prescription_item_id_idx;
+DROP INDEX IF EXISTS medication_request_ref_id_idx;
+DROP INDEX IF EXISTS medication_ref_id_idx;
+
+ALTER TABLE medication_request
+  DROP COLUMN IF EXISTS version_id,
+  DROP COLUMN IF EXISTS last_updated,
+  DROP COLUMN IF EXISTS content_hash,
+  DROP COLUMN IF EXISTS updated_at;
+
+ALTER TABLE medication
+  DROP COLUMN IF EXISTS version_id,
+  DROP COLUMN IF EXISTS last_updated,
+  DROP COLUMN IF EXISTS content_hash,
+  DROP COLUMN IF EXISTS updated_at;
+
+INSERT INTO medication SELECT * FROM medication_backup_000023;
+INSERT INTO medication_ingredient SELECT * FROM medication_ingredient_backup_000023;
+INSERT INTO medication_request SELECT * FROM medication_request_backup_000023;
+
+DROP TABLE IF EXISTS medication_request_backup_000023;
+DROP TABLE IF EXISTS medication_ingredient_backup_000023;
+DROP TABLE IF EXISTS medication_backup_000023;
\ No newline at end of file
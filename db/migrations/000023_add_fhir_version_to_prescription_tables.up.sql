@@ -0,0 +1,80 @@
+DO $$
+DECLARE
+  conflicting INT;
+BEGIN
+  -- medication requests of different FHIR id sharing one prescription item are different prescriptions, none of them can be dropped
+  SELECT COUNT(*) INTO conflicting FROM (
+    SELECT prescription_item_id FROM medication_request GROUP BY prescription_item_id HAVING COUNT(DISTINCT ref_id) > 1
+  ) c;
+
+  IF conflicting > 0 THEN
+    RAISE EXCEPTION '% prescription item(s) are shared by medication requests of different FHIR id, resolve them before migrating', conflicting;
+  END IF;
+END $$;
+
+-- duplicated rows of one FHIR id are kept in backup tables, the newest row is kept and every reference is moved to it
+CREATE TABLE IF NOT EXISTS medication_backup_000023 AS SELECT * FROM medication WHERE FALSE;
+CREATE TABLE IF NOT EXISTS medication_ingredient_backup_000023 AS SELECT * FROM medication_ingredient WHERE FALSE;
+CREATE TABLE IF NOT EXISTS medication_request_backup_000023 AS SELECT * FROM medication_request WHERE FALSE;
+
+INSERT INTO medication_backup_000023
+SELECT m.* FROM medication m
+JOIN (SELECT id, MAX(id) OVER (PARTITION BY ref_id) AS keep_id FROM medication) r ON r.id = m.id
+WHERE r.id <> r.keep_id;
+
+INSERT INTO medication_ingredient_backup_000023
+SELECT mi.* FROM medication_ingredient mi
+JOIN medication_backup_000023 b ON b.id = mi.medication_id;
+
+INSERT INTO medication_request_backup_000023
+SELECT mr.* FROM medication_request mr
+JOIN (SELECT id, MAX(id) OVER (PARTITION BY ref_id) AS keep_id FROM medication_request) r ON r.id = mr.id
+WHERE r.id <> r.keep_id;
+
+UPDATE medication_request mr
+SET medication_id = r.keep_id
+FROM (SELECT id, MAX(id) OVER (PARTITION BY ref_id) AS keep_id FROM medication) r
+WHERE mr.medication_id = r.id AND r.id <> r.keep_id;
+
+UPDATE transaction_detail td
+SET medication_id = r.keep_id
+FROM (SELECT id, MAX(id) OVER (PARTITION BY ref_id) AS keep_id FROM medication) r
+WHERE td.medication_id = r.id AND r.id <> r.keep_id;
+
+DELETE FROM medication_ingredient mi
+USING medication_backup_000023 b
+WHERE mi.medication_id = b.id;
+
+DELETE FROM medication m
+USING medication_backup_000023 b
+WHERE m.id = b.id;
+
+DELETE FROM medication_request mr
+USING medication_request_backup_000023 b
+WHERE mr.id = b.id;
+
+ALTER TABLE medication
+  ADD COLUMN IF NOT EXISTS version_id VARCHAR(255) NULL,
+  ADD COLUMN IF NOT EXISTS last_updated TIMESTAMPTZ NULL,
+  ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64) NULL,
+  ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NULL;
+
+ALTER TABLE medication_request
+  ADD COLUMN IF NOT EXISTS version_id VARCHAR(255) NULL,
+  ADD COLUMN IF NOT EXISTS last_updated TIMESTAMPTZ NULL,
+  ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64) NULL,
+  ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS medication_ref_id_idx ON medication (ref_id);
+CREATE UNIQUE INDEX IF NOT EXISTS medication_request_ref_id_idx ON medication_request (ref_id);
+CREATE UNIQUE INDEX IF NOT EXISTS medication_request_prescription_item_id_idx ON medication_request (prescription_item_id);
+
+CREATE TABLE IF NOT EXISTS idempotency_key (
+  id SERIAL NOT NULL PRIMARY KEY,
+  scope VARCHAR(255) NOT NULL,
+  key VARCHAR(255) NOT NULL,
+  request_hash VARCHAR(255) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idempotency_key_scope_key_idx ON idempotency_key (scope, key);
\ No newline at end of file
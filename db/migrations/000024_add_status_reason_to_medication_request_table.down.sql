@@ -0,0 +1,3 @@
+ALTER TABLE medication_request
+  DROP COLUMN IF EXISTS status_reason,
+  DROP COLUMN IF EXISTS status_changed_at;
\ No newline at end of file
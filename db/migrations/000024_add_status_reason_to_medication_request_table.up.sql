@@ -0,0 +1,3 @@
+ALTER TABLE medication_request
+  ADD COLUMN IF NOT EXISTS status_reason VARCHAR(255) NULL,
+  ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ NULL;
\ No newline at end of file
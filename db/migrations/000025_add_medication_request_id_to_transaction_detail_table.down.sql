@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS transaction_detail_medication_request_id_idx;
+
+ALTER TABLE transaction_detail DROP COLUMN IF EXISTS medication_request_id;
\ No newline at end of file
@@ -0,0 +1,14 @@
+ALTER TABLE transaction_detail ADD COLUMN IF NOT EXISTS medication_request_id INT NULL;
+
+UPDATE transaction_detail td
+SET medication_request_id = (
+  SELECT mr.id
+  FROM medication_request mr
+  JOIN transaction t ON t.patient_id = mr.patient_id
+  WHERE t.id = td.transaction_id AND mr.medication_id = td.medication_id
+  ORDER BY mr.id DESC
+  LIMIT 1
+)
+WHERE td.medication_request_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS transaction_detail_medication_request_id_idx ON transaction_detail (medication_request_id);
\ No newline at end of file
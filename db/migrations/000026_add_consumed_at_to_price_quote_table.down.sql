@@ -0,0 +1,3 @@
+ALTER TABLE price_quote
+  DROP COLUMN IF EXISTS transaction_id,
+  DROP COLUMN IF EXISTS consumed_at;
\ No newline at end of file
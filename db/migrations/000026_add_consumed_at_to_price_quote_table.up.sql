@@ -0,0 +1,3 @@
+ALTER TABLE price_quote
+  ADD COLUMN IF NOT EXISTS transaction_id INT NULL,
+  ADD COLUMN IF NOT EXISTS consumed_at TIMESTAMPTZ NULL;
\ No newline at end of file
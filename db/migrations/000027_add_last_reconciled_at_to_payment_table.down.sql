@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS payment_status_last_reconciled_at_idx;
+
+ALTER TABLE payment DROP COLUMN IF EXISTS last_reconciled_at;
\ No newline at end of file
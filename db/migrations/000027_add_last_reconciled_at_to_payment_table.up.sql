@@ -0,0 +1,3 @@
+ALTER TABLE payment ADD COLUMN IF NOT EXISTS last_reconciled_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS payment_status_last_reconciled_at_idx ON payment (status, last_reconciled_at NULLS FIRST);
\ No newline at end of file
@@ -0,0 +1,29 @@
+ALTER TABLE dispense_order
+  ALTER COLUMN partner_id DROP NOT NULL,
+  ALTER COLUMN submitted_at DROP NOT NULL,
+  ADD COLUMN IF NOT EXISTS status VARCHAR(255) NOT NULL DEFAULT 'SUBMITTED',
+  ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 1,
+  ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  ADD COLUMN IF NOT EXISTS last_error TEXT NULL;
+
+ALTER TABLE dispense_order ALTER COLUMN status DROP DEFAULT, ALTER COLUMN attempts SET DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS dispense_order_status_next_attempt_at_idx ON dispense_order (status, next_attempt_at);
+
+INSERT INTO dispense_order (transaction_id, status, attempts, next_attempt_at, last_error, created_at)
+SELECT
+  (payload->>'transaction_id')::INT,
+  CASE WHEN status = 'DEAD' THEN 'FAILED' ELSE 'PENDING' END,
+  attempts,
+  next_attempt_at,
+  last_error,
+  created_at
+FROM
+  outbox
+WHERE
+  event_type = 'DISPENSE_ORDER'
+AND
+  status <> 'DISPATCHED'
+ON CONFLICT (transaction_id) DO NOTHING;
+
+DELETE FROM outbox WHERE event_type = 'DISPENSE_ORDER' AND status <> 'DISPATCHED';
\ No newline at end of file
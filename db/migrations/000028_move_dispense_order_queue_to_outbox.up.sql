@@ -0,0 +1,28 @@
+INSERT INTO outbox (event_type, aggregate_id, payload, status, attempts, next_attempt_at, last_error, created_at)
+SELECT
+  'DISPENSE_ORDER',
+  transaction_id::TEXT,
+  jsonb_build_object('transaction_id', transaction_id),
+  CASE WHEN status = 'FAILED' THEN 'DEAD' ELSE 'PENDING' END,
+  attempts,
+  next_attempt_at,
+  last_error,
+  created_at
+FROM
+  dispense_order
+WHERE
+  status <> 'SUBMITTED'
+ORDER BY
+  id ASC;
+
+DELETE FROM dispense_order WHERE status <> 'SUBMITTED';
+
+DROP INDEX IF EXISTS dispense_order_status_next_attempt_at_idx;
+
+ALTER TABLE dispense_order
+  DROP COLUMN IF EXISTS status,
+  DROP COLUMN IF EXISTS attempts,
+  DROP COLUMN IF EXISTS next_attempt_at,
+  DROP COLUMN IF EXISTS last_error,
+  ALTER COLUMN partner_id SET NOT NULL,
+  ALTER COLUMN submitted_at SET NOT NULL;
\ No newline at end of file
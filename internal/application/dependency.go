@@ -2,6 +2,7 @@ package application
 
 import (
 	controllerV1 "e-resep-be/internal/controller/v1"
+	"e-resep-be/internal/model"
 	"e-resep-be/internal/repository"
 	"e-resep-be/internal/requester"
 	"e-resep-be/internal/service"
@@ -14,13 +15,29 @@ type Dependency struct {
 	PatientAddressController controllerV1.PatientAddressController
 	TransactionController    controllerV1.TransactionController
 	PaymentController        controllerV1.PaymentController
+	ReconciliationController controllerV1.ReconciliationController
+	RefundController         controllerV1.RefundController
+	FulfilmentController     controllerV1.FulfilmentController
+
+	// services run by background workers
+	ReconciliationService service.ReconciliationService
+	RefundService         service.RefundService
+	OutboxService         service.OutboxService
 }
 
 func SetupDependencyInjection(app *App) *Dependency {
 	// requester
 	whatsappRequesterImpl := requester.NewWhatsappRequester(app.Context, app.Config, app.Logger, app.HTTPClient)
-	kimiaFarmaRequesterImpl := requester.NewKimiaFarmaRequester(app.Context, app.Config, app.Logger, app.HTTPClient)
+	kimiaFarmaRequesterImpl := requester.NewKimiaFarmaCacheRequester(app.Context, app.Config, app.Logger, requester.NewKimiaFarmaRequester(app.Context, app.Config, app.Logger, app.HTTPClient))
 	xenditRequesterImpl := requester.NewXenditRequester(app.Context, app.Config, app.Logger, app.XenditSDK)
+	courierRequesterImpl := requester.NewCourierRequester(app.Context, app.Config, app.Logger, app.HTTPClient)
+	paymentGateways := map[model.PaymentGatewayEnum]requester.PaymentGateway{
+		model.PaymentGatewayEnumXendit: requester.NewXenditPaymentGateway(app.Context, app.Config, app.Logger, xenditRequesterImpl),
+	}
+	if app.Config.Midtrans.Enabled() {
+		paymentGateways[model.PaymentGatewayEnumMidtrans] = requester.NewMidtransPaymentGateway(app.Context, app.Config, app.Logger, app.HTTPClient)
+	}
+	paymentGatewayProviderImpl := requester.NewPaymentGatewayProvider(app.Config, paymentGateways)
 
 	// repository
 	healthCheckRepoImpl := repository.NewHealthCheckRepository(app.Context, app.Config, app.Logger, app.DB)
@@ -29,16 +46,32 @@ func SetupDependencyInjection(app *App) *Dependency {
 	patientRepoImpl := repository.NewPatientRepository(app.Context, app.Config, app.Logger, app.DB)
 	patientAddressRepoImpl := repository.NewPatientAddressRepository(app.Context, app.Config, app.Logger, app.DB)
 	medicationRepoImpl := repository.NewMedicationRepository(app.Context, app.Config, app.Logger, app.DB)
+	medicationRequestRepoImpl := repository.NewMedicationRequestRepository(app.Context, app.Config, app.Logger, app.DB)
 	transactionRepoImpl := repository.NewTransactionRepository(app.Context, app.Config, app.Logger, app.DB)
 	paymentRepoImpl := repository.NewPaymentRepository(app.Context, app.Config, app.Logger, app.DB)
+	paymentEventRepoImpl := repository.NewPaymentEventRepository(app.Context, app.Config, app.Logger, app.DB)
+	priceQuoteRepoImpl := repository.NewPriceQuoteRepository(app.Context, app.Config, app.Logger, app.DB)
+	shippingTariffRepoImpl := repository.NewShippingTariffRepository(app.Context, app.Config, app.Logger, app.DB)
+	reconciliationDiscrepancyRepoImpl := repository.NewReconciliationDiscrepancyRepository(app.Context, app.Config, app.Logger, app.DB)
+	fulfilmentRepoImpl := repository.NewFulfilmentRepository(app.Context, app.Config, app.Logger, app.DB)
+	shipmentRepoImpl := repository.NewShipmentRepository(app.Context, app.Config, app.Logger, app.DB)
+	dispenseOrderRepoImpl := repository.NewDispenseOrderRepository(app.Context, app.Config, app.Logger, app.DB)
+	outboxRepoImpl := repository.NewOutboxRepository(app.Context, app.Config, app.Logger, app.DB)
+	refundRepoImpl := repository.NewRefundRepository(app.Context, app.Config, app.Logger, app.DB)
+	unitOfWorkImpl := repository.NewUnitOfWork(app.Context, app.Config, app.Logger, app.DB)
 
 	// service
+	shippingCalculatorImpl := service.NewDistanceBandShippingCalculator(app.Context, app.Config, shippingTariffRepoImpl)
 	healthCheckSvcImpl := service.NewHealthCheckService(app.Context, app.Config, healthCheckRepoImpl)
-	prescriptionSvcImpl := service.NewPrescriptionService(app.Context, app.Config, prescriptionRepoImpl, whatsappRequesterImpl, kimiaFarmaRequesterImpl)
+	prescriptionSvcImpl := service.NewPrescriptionService(app.Context, app.Config, prescriptionRepoImpl, kimiaFarmaRequesterImpl, unitOfWorkImpl)
 	addressSvcImpl := service.NewAddressService(app.Context, app.Config, addressRepoImpl)
 	patientAddressSvcImpl := service.NewPatientAddressService(app.Context, app.Config, patientRepoImpl, patientAddressRepoImpl)
-	paymentSvc := service.NewPaymentService(app.Context, app.Config, medicationRepoImpl, patientRepoImpl, patientAddressRepoImpl, transactionRepoImpl, paymentRepoImpl, kimiaFarmaRequesterImpl)
-	transactionSvc := service.NewTransactionService(app.Context, app.Config, patientRepoImpl, transactionRepoImpl, paymentRepoImpl, xenditRequesterImpl)
+	paymentSvc := service.NewPaymentService(app.Context, app.Config, medicationRepoImpl, medicationRequestRepoImpl, patientRepoImpl, patientAddressRepoImpl, transactionRepoImpl, paymentRepoImpl, paymentEventRepoImpl, priceQuoteRepoImpl, kimiaFarmaRequesterImpl, paymentGatewayProviderImpl, shippingCalculatorImpl, unitOfWorkImpl)
+	refundSvcImpl := service.NewRefundService(app.Context, app.Config, paymentRepoImpl, refundRepoImpl, paymentEventRepoImpl, paymentGatewayProviderImpl, unitOfWorkImpl)
+	reconciliationSvcImpl := service.NewReconciliationService(app.Context, app.Config, transactionRepoImpl, paymentRepoImpl, reconciliationDiscrepancyRepoImpl, paymentGatewayProviderImpl, unitOfWorkImpl)
+	fulfilmentSvcImpl := service.NewFulfilmentService(app.Context, app.Config, patientRepoImpl, transactionRepoImpl, fulfilmentRepoImpl, shipmentRepoImpl, unitOfWorkImpl)
+	outboxSvcImpl := service.NewOutboxService(app.Context, app.Config, outboxRepoImpl, transactionRepoImpl, patientAddressRepoImpl, dispenseOrderRepoImpl, whatsappRequesterImpl, kimiaFarmaRequesterImpl, courierRequesterImpl, unitOfWorkImpl)
+	transactionSvc := service.NewTransactionService(app.Context, app.Config, patientRepoImpl, patientAddressRepoImpl, medicationRepoImpl, transactionRepoImpl, paymentRepoImpl, priceQuoteRepoImpl, paymentGatewayProviderImpl, kimiaFarmaRequesterImpl, shippingCalculatorImpl, unitOfWorkImpl)
 
 	// controller
 	healthCheckControllerImpl := controllerV1.NewHealthCheckController(app.Context, app.Config, healthCheckSvcImpl)
@@ -47,6 +80,9 @@ func SetupDependencyInjection(app *App) *Dependency {
 	patientAddressControllerImpl := controllerV1.NewPatientAddressController(app.Context, app.Config, patientAddressSvcImpl)
 	paymentControllerImpl := controllerV1.NewPaymentController(app.Context, app.Config, paymentSvc)
 	transactionControllerImpl := controllerV1.NewTransactionController(app.Context, app.Config, transactionSvc)
+	refundControllerImpl := controllerV1.NewRefundController(app.Context, app.Config, refundSvcImpl)
+	reconciliationControllerImpl := controllerV1.NewReconciliationController(app.Context, app.Config, reconciliationSvcImpl)
+	fulfilmentControllerImpl := controllerV1.NewFulfilmentController(app.Context, app.Config, fulfilmentSvcImpl)
 
 	return &Dependency{
 		HealthCheckController:    healthCheckControllerImpl,
@@ -55,5 +91,11 @@ func SetupDependencyInjection(app *App) *Dependency {
 		PatientAddressController: patientAddressControllerImpl,
 		PaymentController:        paymentControllerImpl,
 		TransactionController:    transactionControllerImpl,
+		ReconciliationController: reconciliationControllerImpl,
+		RefundController:         refundControllerImpl,
+		FulfilmentController:     fulfilmentControllerImpl,
+		ReconciliationService:    reconciliationSvcImpl,
+		RefundService:            refundSvcImpl,
+		OutboxService:            outboxSvcImpl,
 	}
 }
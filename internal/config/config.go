@@ -1,15 +1,37 @@
 package config
 
-import "e-resep-be/internal/helper"
+import (
+	"e-resep-be/internal/helper"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// bounding box of Indonesia, pharmacy outside of it is a misconfiguration
+const (
+	indonesiaMinLatitude  = -11.5
+	indonesiaMaxLatitude  = 6.5
+	indonesiaMinLongitude = 94.5
+	indonesiaMaxLongitude = 141.5
+)
 
 type (
 	Configuration struct {
-		Server     *Server
-		Database   *Database
-		Const      *Const
-		Whatsapp   *Whatsapp
-		KimiaFarma *KimiaFarma
-		Xendit     *Xendit
+		Server         *Server
+		Database       *Database
+		Const          *Const
+		Whatsapp       *Whatsapp
+		KimiaFarma     *KimiaFarma
+		PaymentGateway *PaymentGateway
+		Xendit         *Xendit
+		Midtrans       *Midtrans
+		Pharmacy       *Pharmacy
+		Courier        *Courier
+		Stub           *Stub
+		Reconciliation *Reconciliation
+		Outbox         *Outbox
+		Auth           *Auth
 	}
 
 	Server struct {
@@ -29,7 +51,8 @@ type (
 	}
 
 	Const struct {
-		ClientURL string
+		ClientURL     string
+		PriceQuoteTTL int
 	}
 
 	Whatsapp struct {
@@ -37,11 +60,66 @@ type (
 	}
 
 	KimiaFarma struct {
-		KimiaFarmaURL string
+		KimiaFarmaURL            string
+		KimiaFarmaAPIKey         string
+		KimiaFarmaTimeout        int
+		KimiaFarmaBatchEnabled   bool
+		KimiaFarmaMaxConcurrency int
+		KimiaFarmaCacheTTL       int
+	}
+
+	Pharmacy struct {
+		PharmacyLatitude    float64
+		PharmacyLongitude   float64
+		PharmacySubDistrict string
+		PharmacyName        string
+		PharmacyPhoneNumber string
+		PharmacyAddress     string
+	}
+
+	Courier struct {
+		CourierURL           string
+		CourierAPIKey        string
+		CourierCallbackToken string
+		CourierTimeout       int
+	}
+
+	Stub struct {
+		StubPort               int
+		StubCourierCallbackURL string
+	}
+
+	Auth struct {
+		StaffAPIToken string
+	}
+
+	Outbox struct {
+		OutboxInterval    int
+		OutboxMaxAttempts int
+	}
+
+	Reconciliation struct {
+		ReconciliationInterval    int
+		PendingTransactionTimeout int
+		ProcessPaymentTimeout     int
+	}
+
+	PaymentGateway struct {
+		PaymentGateway string
 	}
 
 	Xendit struct {
-		XenditAPIKey string
+		XenditURL             string
+		XenditAPIKey          string
+		XenditCallbackToken   string
+		XenditInvoiceDuration int
+	}
+
+	Midtrans struct {
+		MidtransURL       string
+		MidtransSnapURL   string
+		MidtransServerKey string
+		MidtransTimeout   int
 	}
 )
 
@@ -62,16 +140,64 @@ func loadConfiguration() *Configuration {
 			SslMode:  helper.GetEnvString("DB_SSL_MODE"),
 		},
 		Const: &Const{
-			ClientURL: helper.GetEnvString("CLIENT_URL"),
+			ClientURL:     helper.GetEnvString("CLIENT_URL"),
+			PriceQuoteTTL: helper.GetEnvInt("PRICE_QUOTE_TTL"),
 		},
 		Whatsapp: &Whatsapp{
 			WaBroadcastURL: helper.GetEnvString("WA_BROADCAST_URL"),
 		},
 		KimiaFarma: &KimiaFarma{
-			KimiaFarmaURL: helper.GetEnvString("KIMIA_FARMA_URL"),
+			KimiaFarmaURL:            helper.GetEnvString("KIMIA_FARMA_URL"),
+			KimiaFarmaAPIKey:         helper.GetEnvString("KIMIA_FARMA_API_KEY"),
+			KimiaFarmaTimeout:        helper.GetEnvInt("KIMIA_FARMA_TIMEOUT"),
+			KimiaFarmaBatchEnabled:   helper.GetEnvBool("KIMIA_FARMA_BATCH_ENABLED"),
+			KimiaFarmaMaxConcurrency: helper.GetEnvInt("KIMIA_FARMA_MAX_CONCURRENCY"),
+			KimiaFarmaCacheTTL:       helper.GetEnvInt("KIMIA_FARMA_CACHE_TTL"),
+		},
+		PaymentGateway: &PaymentGateway{
+			PaymentGateway: helper.GetEnvString("PAYMENT_GATEWAY"),
 		},
 		Xendit: &Xendit{
-			XenditAPIKey: helper.GetEnvString("XENDIT_API_KEY"),
+			XenditURL:             helper.GetEnvString("XENDIT_URL"),
+			XenditAPIKey:          helper.GetEnvString("XENDIT_API_KEY"),
+			XenditCallbackToken:   helper.GetEnvString("XENDIT_CALLBACK_TOKEN"),
+			XenditInvoiceDuration: helper.GetEnvInt("XENDIT_INVOICE_DURATION"),
+		},
+		Midtrans: &Midtrans{
+			MidtransURL:       helper.GetEnvString("MIDTRANS_URL"),
+			MidtransSnapURL:   helper.GetEnvString("MIDTRANS_SNAP_URL"),
+			MidtransServerKey: helper.GetEnvString("MIDTRANS_SERVER_KEY"),
+			MidtransTimeout:   helper.GetEnvInt("MIDTRANS_TIMEOUT"),
+		},
+		Pharmacy: &Pharmacy{
+			PharmacyLatitude:    helper.GetEnvFloat("PHARMACY_LATITUDE"),
+			PharmacyLongitude:   helper.GetEnvFloat("PHARMACY_LONGITUDE"),
+			PharmacySubDistrict: helper.GetEnvString("PHARMACY_SUB_DISTRICT"),
+			PharmacyName:        helper.GetEnvString("PHARMACY_NAME"),
+			PharmacyPhoneNumber: helper.GetEnvString("PHARMACY_PHONE_NUMBER"),
+			PharmacyAddress:     helper.GetEnvString("PHARMACY_ADDRESS"),
+		},
+		Courier: &Courier{
+			CourierURL:           helper.GetEnvString("COURIER_URL"),
+			CourierAPIKey:        helper.GetEnvString("COURIER_API_KEY"),
+			CourierCallbackToken: helper.GetEnvString("COURIER_CALLBACK_TOKEN"),
+			CourierTimeout:       helper.GetEnvInt("COURIER_TIMEOUT"),
+		},
+		Stub: &Stub{
+			StubPort:               helper.GetEnvInt("STUB_PORT"),
+			StubCourierCallbackURL: helper.GetEnvString("STUB_COURIER_CALLBACK_URL"),
+		},
+		Outbox: &Outbox{
+			OutboxInterval:    helper.GetEnvInt("OUTBOX_INTERVAL"),
+			OutboxMaxAttempts: helper.GetEnvInt("OUTBOX_MAX_ATTEMPTS"),
+		},
+		Auth: &Auth{
+			StaffAPIToken: helper.GetEnvString("STAFF_API_TOKEN"),
+		},
+		Reconciliation: &Reconciliation{
+			ReconciliationInterval:    helper.GetEnvInt("RECONCILIATION_INTERVAL"),
+			PendingTransactionTimeout: helper.GetEnvInt("PENDING_TRANSACTION_TIMEOUT"),
+			ProcessPaymentTimeout:     helper.GetEnvInt("PROCESS_PAYMENT_TIMEOUT"),
 		},
 	}
 }
@@ -79,3 +205,41 @@ func loadConfiguration() *Configuration {
 func NewConfig() *Configuration {
 	return loadConfiguration()
 }
+
+// Validate check configuration the app can't run correctly without, so misconfigured app fail at startup instead of serving wrong result
+func (c *Configuration) Validate() error {
+	return errors.Join(
+		c.Pharmacy.validate(),
+		c.PaymentGateway.validate(c.Midtrans),
+	)
+}
+
+// validate check pharmacy location is set and inside Indonesia, it is the origin of every shipping cost
+func (p *Pharmacy) validate() error {
+	for _, env := range []string{"PHARMACY_LATITUDE", "PHARMACY_LONGITUDE"} {
+		if _, ok := os.LookupEnv(env); !ok {
+			return fmt.Errorf("%s is not set", env)
+		}
+	}
+
+	if p.PharmacyLatitude < indonesiaMinLatitude || p.PharmacyLatitude > indonesiaMaxLatitude ||
+		p.PharmacyLongitude < indonesiaMinLongitude || p.PharmacyLongitude > indonesiaMaxLongitude {
+		return fmt.Errorf("pharmacy location %f,%f is outside Indonesia, check PHARMACY_LATITUDE and PHARMACY_LONGITUDE", p.PharmacyLatitude, p.PharmacyLongitude)
+	}
+
+	return nil
+}
+
+// validate check payment gateway selected by PAYMENT_GATEWAY is configured, midtrans notification can't be verified without server key
+func (p *PaymentGateway) validate(midtrans *Midtrans) error {
+	if strings.EqualFold(p.PaymentGateway, "midtrans") && !midtrans.Enabled() {
+		return errors.New("PAYMENT_GATEWAY is midtrans but MIDTRANS_SERVER_KEY is not set")
+	}
+
+	return nil
+}
+
+// Enabled return whether midtrans is configured, midtrans gateway and its notification are only served when server key is set
+func (m *Midtrans) Enabled() bool {
+	return m.MidtransServerKey != ""
+}
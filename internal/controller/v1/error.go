@@ -0,0 +1,30 @@
+package v1
+
+import (
+	"e-resep-be/internal/helper"
+	"e-resep-be/internal/model"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newErrorResponse return error response with status code based on error kind,
+// message of known error kind is shown to client while unknown error use the given message
+func newErrorResponse(ctx echo.Context, err error, message string) error {
+	statusCode := http.StatusInternalServerError
+
+	switch model.KindOf(err) {
+	case model.Validation, model.TypeInvalid:
+		statusCode = http.StatusBadRequest
+	case model.NotFound:
+		statusCode = http.StatusNotFound
+	case model.Conflict:
+		statusCode = http.StatusConflict
+	case model.Partner:
+		statusCode = http.StatusBadGateway
+	default:
+		return helper.NewResponses[any](ctx, statusCode, message, nil, err, nil)
+	}
+
+	return helper.NewResponses[any](ctx, statusCode, err.Error(), nil, err, nil)
+}
@@ -0,0 +1,100 @@
+package v1
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/helper"
+	"e-resep-be/internal/model"
+	"e-resep-be/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	// FulfilmentController is an interface that has all the function to be implemented inside fulfilment controller
+	FulfilmentController interface {
+		UpdateFulfilment(ctx echo.Context) error
+		GetTracking(ctx echo.Context) error
+		CourierNotification(ctx echo.Context) error
+	}
+
+	// FulfilmentControllerImpl is an app fulfilment struct that consists of all the dependencies needed for fulfilment controller
+	FulfilmentControllerImpl struct {
+		Context       context.Context
+		Config        *config.Configuration
+		FulfilmentSvc service.FulfilmentService
+	}
+)
+
+// NewFulfilmentController return new instance fulfilment controller
+func NewFulfilmentController(ctx context.Context, config *config.Configuration, fulfilmentSvc service.FulfilmentService) *FulfilmentControllerImpl {
+	return &FulfilmentControllerImpl{
+		Context:       ctx,
+		Config:        config,
+		FulfilmentSvc: fulfilmentSvc,
+	}
+}
+
+// UpdateFulfilment is used by pharmacy staff to advance fulfilment of paid transaction
+func (fc *FulfilmentControllerImpl) UpdateFulfilment(ctx echo.Context) error {
+	var fulfilmentReq model.UpdateFulfilmentRequest
+
+	transactionID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, "invalid transaction id", nil, err, nil)
+	}
+
+	if err := ctx.Bind(&fulfilmentReq); err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	err = fulfilmentReq.Validate()
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	results, err := fc.FulfilmentSvc.UpdateFulfilment(ctx.Request().Context(), transactionID, &fulfilmentReq)
+	if err != nil {
+		return newErrorResponse(ctx, err, "Error Update Fulfilment")
+	}
+
+	return helper.NewResponses[any](ctx, http.StatusOK, "Success Update Fulfilment", results, nil, nil)
+}
+
+// GetTracking is used by patient to track delivery of their transaction
+func (fc *FulfilmentControllerImpl) GetTracking(ctx echo.Context) error {
+	transactionID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, "invalid transaction id", nil, err, nil)
+	}
+
+	results, err := fc.FulfilmentSvc.GetTracking(ctx.Request().Context(), ctx.Param("ref_id"), transactionID)
+	if err != nil {
+		return newErrorResponse(ctx, err, "Error Get Tracking")
+	}
+
+	return helper.NewResponses[any](ctx, http.StatusOK, "Success Get Tracking", results, nil, nil)
+}
+
+// CourierNotification is used by courier to report shipment status change
+func (fc *FulfilmentControllerImpl) CourierNotification(ctx echo.Context) error {
+	var notificationReq model.CourierNotification
+
+	if err := ctx.Bind(&notificationReq); err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	if notificationReq.Data.ID == "" {
+		err := model.NewError(model.Validation, "shipment id is required")
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	err := fc.FulfilmentSvc.HandleCourierNotification(ctx.Request().Context(), &notificationReq)
+	if err != nil {
+		return newErrorResponse(ctx, err, "Error Courier Notification")
+	}
+
+	return helper.NewResponses[any](ctx, http.StatusOK, "Success Processed Courier Notification", nil, nil, nil)
+}
@@ -6,17 +6,21 @@ import (
 	"e-resep-be/internal/helper"
 	"e-resep-be/internal/model"
 	"e-resep-be/internal/service"
+	"io"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
-	"github.com/xendit/xendit-go/v6/invoice"
 )
 
+// HeaderWebhookID is unique id of a callback, retries of the same callback share the same id
+const HeaderWebhookID = "webhook-id"
+
 type (
 	// PaymentController is an interface that has all the function to be implemented inside payment controller
 	PaymentController interface {
 		GeneratePaymentInfo(ctx echo.Context) error
 		PaymentNotification(ctx echo.Context) error
+		MidtransNotification(ctx echo.Context) error
 	}
 
 	// PaymentControllerImpl is an app payment struct that consists of all the dependencies needed for payment controller
@@ -45,22 +49,32 @@ func (pc *PaymentControllerImpl) GeneratePaymentInfo(ctx echo.Context) error {
 
 	results, err := pc.PaymentSvc.GeneratePaymentInfo(ctx.Request().Context(), &paymentInfoReq)
 	if err != nil {
-		return helper.NewResponses[any](ctx, http.StatusInternalServerError, "Error Generate Payment Info", nil, err, nil)
+		return newErrorResponse(ctx, err, "Error Generate Payment Info")
 	}
 
 	return helper.NewResponses[any](ctx, http.StatusOK, "Success Generate Payment Info", results, nil, nil)
 }
 
+// PaymentNotification receive xendit callback of invoice and of payment charged directly with QRIS, virtual account or e-wallet
 func (pc *PaymentControllerImpl) PaymentNotification(ctx echo.Context) error {
-	var notificationReq invoice.InvoiceCallback
+	return pc.handleNotification(ctx, model.PaymentGatewayEnumXendit)
+}
 
-	if err := ctx.Bind(&notificationReq); err != nil {
+// MidtransNotification receive midtrans payment notification, it is authenticated by its signature key
+func (pc *PaymentControllerImpl) MidtransNotification(ctx echo.Context) error {
+	return pc.handleNotification(ctx, model.PaymentGatewayEnumMidtrans)
+}
+
+func (pc *PaymentControllerImpl) handleNotification(ctx echo.Context, gateway model.PaymentGatewayEnum) error {
+	// keep raw body, it is recorded as payment event payload
+	rawPayload, err := io.ReadAll(ctx.Request().Body)
+	if err != nil {
 		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
 	}
 
-	err := pc.PaymentSvc.HandleWebhookNotification(ctx.Request().Context(), notificationReq)
+	err = pc.PaymentSvc.HandleWebhookNotification(ctx.Request().Context(), gateway, ctx.Request().Header.Get(HeaderWebhookID), rawPayload)
 	if err != nil {
-		return helper.NewResponses[any](ctx, http.StatusInternalServerError, "Error Payment Notification", nil, err, nil)
+		return newErrorResponse(ctx, err, "Error Payment Notification")
 	}
 
 	return helper.NewResponses[any](ctx, http.StatusOK, "Success Processed Payment Notificaion", nil, nil, nil)
@@ -6,16 +6,23 @@ import (
 	"e-resep-be/internal/helper"
 	"e-resep-be/internal/model"
 	"e-resep-be/internal/service"
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 )
 
+// HeaderIdempotencyKey is unique key of a create request, retries of the same request share the same key
+const HeaderIdempotencyKey = "Idempotency-Key"
+
 type (
 	// PrescriptionController is an interface that has all the function to be implemented inside prescription controller
 	PrescriptionController interface {
 		Create(ctx echo.Context) error
+		CreateBundle(ctx echo.Context) error
 		GetByPrescriptionID(ctx echo.Context) error
+		PatchMedicationRequest(ctx echo.Context) error
+		UpdatePrescriptionStatus(ctx echo.Context) error
 	}
 
 	// PrescriptionControllerImpl is an app prescription struct that consists of all the dependencies needed for prescription controller
@@ -38,19 +45,52 @@ func NewPrescriptionController(ctx context.Context, config *config.Configuration
 func (pc *PrescriptionControllerImpl) Create(ctx echo.Context) error {
 	var prescriptionReq model.PrescriptionRequest
 	phoneNumber := ctx.QueryParam("phoneNumber") // TODO: temporary query param
+	email := ctx.QueryParam("email")             // TODO: temporary query param
 
 	if err := ctx.Bind(&prescriptionReq); err != nil {
 		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
 	}
 
-	err := pc.PrescriptionSvc.Create(ctx.Request().Context(), &prescriptionReq, phoneNumber)
+	// invalid FHIR resource is reported as OperationOutcome
+	err := prescriptionReq.Validate()
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusUnprocessableEntity, "Invalid Prescription", err, err, nil)
+	}
+
+	err = pc.PrescriptionSvc.Create(ctx.Request().Context(), &prescriptionReq, phoneNumber, email, ctx.Request().Header.Get(HeaderIdempotencyKey))
 	if err != nil {
-		return helper.NewResponses[any](ctx, http.StatusInternalServerError, "Error Create Prescription", nil, err, nil)
+		return newErrorResponse(ctx, err, "Error Create Prescription")
 	}
 
 	return helper.NewResponses[any](ctx, http.StatusCreated, "Success Create Prescription", nil, nil, nil)
 }
 
+// CreateBundle ingest FHIR transaction bundle of a multi-item prescription, the result of every entry is returned as transaction-response bundle
+func (pc *PrescriptionControllerImpl) CreateBundle(ctx echo.Context) error {
+	var bundleReq model.Bundle
+	phoneNumber := ctx.QueryParam("phoneNumber") // TODO: temporary query param, patient telecom inside bundle is preferred
+	email := ctx.QueryParam("email")             // TODO: temporary query param, patient telecom inside bundle is preferred
+
+	if err := ctx.Bind(&bundleReq); err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	results, err := pc.PrescriptionSvc.CreateBundle(ctx.Request().Context(), &bundleReq, phoneNumber, email)
+	if err != nil {
+		var (
+			outcome   *model.OperationOutcome
+			bundleErr *model.BundleError
+		)
+		if errors.As(err, &outcome) || errors.As(err, &bundleErr) {
+			return helper.NewResponses[any](ctx, http.StatusUnprocessableEntity, "Invalid Prescription Bundle", err, err, nil)
+		}
+
+		return newErrorResponse(ctx, err, "Error Create Prescription Bundle")
+	}
+
+	return helper.NewResponses[any](ctx, http.StatusOK, "Success Create Prescription Bundle", results, nil, nil)
+}
+
 func (pc *PrescriptionControllerImpl) GetByPrescriptionID(ctx echo.Context) error {
 	id := ctx.Param("id")
 
@@ -61,3 +101,50 @@ func (pc *PrescriptionControllerImpl) GetByPrescriptionID(ctx echo.Context) erro
 
 	return helper.NewResponses[any](ctx, http.StatusOK, "Success Get Prescription", results, nil, nil)
 }
+
+// PatchMedicationRequest apply FHIR PATCH (JSON Patch) of medication request status sent by EMR
+func (pc *PrescriptionControllerImpl) PatchMedicationRequest(ctx echo.Context) error {
+	var operations []model.JSONPatchOperation
+
+	if err := ctx.Bind(&operations); err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	statusReq, err := model.NewUpdateMedicationRequestStatusRequest(operations)
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	err = statusReq.Validate()
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	err = pc.PrescriptionSvc.UpdateMedicationRequestStatus(ctx.Request().Context(), ctx.Param("id"), statusReq)
+	if err != nil {
+		return newErrorResponse(ctx, err, "Error Update Medication Request Status")
+	}
+
+	return helper.NewResponses[any](ctx, http.StatusOK, "Success Update Medication Request Status", nil, nil, nil)
+}
+
+// UpdatePrescriptionStatus move every item of prescription into the same status, e.g. when clinic cancel the whole prescription
+func (pc *PrescriptionControllerImpl) UpdatePrescriptionStatus(ctx echo.Context) error {
+	var statusReq model.UpdateMedicationRequestStatusRequest
+
+	if err := ctx.Bind(&statusReq); err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	err := statusReq.Validate()
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	err = pc.PrescriptionSvc.UpdatePrescriptionStatus(ctx.Request().Context(), ctx.Param("id"), &statusReq)
+	if err != nil {
+		return newErrorResponse(ctx, err, "Error Update Prescription Status")
+	}
+
+	return helper.NewResponses[any](ctx, http.StatusOK, "Success Update Prescription Status", nil, nil, nil)
+}
@@ -0,0 +1,45 @@
+package v1
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/helper"
+	"e-resep-be/internal/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	// ReconciliationController is an interface that has all the function to be implemented inside reconciliation controller
+	ReconciliationController interface {
+		GetDiscrepancies(ctx echo.Context) error
+	}
+
+	// ReconciliationControllerImpl is an app reconciliation struct that consists of all the dependencies needed for reconciliation controller
+	ReconciliationControllerImpl struct {
+		Context           context.Context
+		Config            *config.Configuration
+		ReconciliationSvc service.ReconciliationService
+	}
+)
+
+// NewReconciliationController return new instance reconciliation controller
+func NewReconciliationController(ctx context.Context, config *config.Configuration, reconciliationSvc service.ReconciliationService) *ReconciliationControllerImpl {
+	return &ReconciliationControllerImpl{
+		Context:           ctx,
+		Config:            config,
+		ReconciliationSvc: reconciliationSvc,
+	}
+}
+
+func (rc *ReconciliationControllerImpl) GetDiscrepancies(ctx echo.Context) error {
+	pages := helper.NewFromRequest(ctx)
+
+	results, err := rc.ReconciliationSvc.GetDiscrepancies(ctx.Request().Context(), pages)
+	if err != nil {
+		return newErrorResponse(ctx, err, "Error Get Reconciliation Discrepancies")
+	}
+
+	return helper.NewResponses[any](ctx, http.StatusOK, "Success Get Reconciliation Discrepancies", results, nil, pages)
+}
@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/helper"
+	"e-resep-be/internal/model"
+	"e-resep-be/internal/service"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	// RefundController is an interface that has all the function to be implemented inside refund controller
+	RefundController interface {
+		CreateRefund(ctx echo.Context) error
+		RefundNotification(ctx echo.Context) error
+	}
+
+	// RefundControllerImpl is an app refund struct that consists of all the dependencies needed for refund controller
+	RefundControllerImpl struct {
+		Context   context.Context
+		Config    *config.Configuration
+		RefundSvc service.RefundService
+	}
+)
+
+// NewRefundController return new instance refund controller
+func NewRefundController(ctx context.Context, config *config.Configuration, refundSvc service.RefundService) *RefundControllerImpl {
+	return &RefundControllerImpl{
+		Context:   ctx,
+		Config:    config,
+		RefundSvc: refundSvc,
+	}
+}
+
+func (rc *RefundControllerImpl) CreateRefund(ctx echo.Context) error {
+	var refundReq model.CreateRefundRequest
+
+	transactionID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, "invalid transaction id", nil, err, nil)
+	}
+
+	if err := ctx.Bind(&refundReq); err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	err = refundReq.Validate()
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	results, err := rc.RefundSvc.CreateRefund(ctx.Request().Context(), transactionID, &refundReq)
+	if err != nil {
+		return newErrorResponse(ctx, err, "Error Create Refund")
+	}
+
+	return helper.NewResponses[any](ctx, http.StatusCreated, "Success Create Refund", results, nil, nil)
+}
+
+func (rc *RefundControllerImpl) RefundNotification(ctx echo.Context) error {
+	// keep raw body, it is recorded as payment event payload
+	rawPayload, err := io.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	err = rc.RefundSvc.HandleRefundNotification(ctx.Request().Context(), model.PaymentGatewayEnumXendit, ctx.Request().Header.Get(HeaderWebhookID), rawPayload)
+	if err != nil {
+		return newErrorResponse(ctx, err, "Error Refund Notification")
+	}
+
+	return helper.NewResponses[any](ctx, http.StatusOK, "Success Processed Refund Notification", nil, nil, nil)
+}
@@ -7,6 +7,8 @@ import (
 	"e-resep-be/internal/model"
 	"e-resep-be/internal/service"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 )
@@ -16,6 +18,8 @@ type (
 	TransactionController interface {
 		CreateTransaction(ctx echo.Context) error
 		GetTransactionByPartnerID(ctx echo.Context) error
+		CancelTransaction(ctx echo.Context) error
+		GetPatientTransactions(ctx echo.Context) error
 	}
 
 	// TransactionControllerImpl is an app transaction struct that consists of all the dependencies needed for transaction controller
@@ -49,7 +53,7 @@ func (tc *TransactionControllerImpl) CreateTransaction(ctx echo.Context) error {
 
 	results, err := tc.TransactionSvc.CreateTransaction(ctx.Request().Context(), &transactionReq)
 	if err != nil {
-		return helper.NewResponses[any](ctx, http.StatusInternalServerError, "Error Create Transaction", nil, err, nil)
+		return newErrorResponse(ctx, err, "Error Create Transaction")
 	}
 
 	return helper.NewResponses[any](ctx, http.StatusCreated, "Success Create Transaction", results, nil, nil)
@@ -63,3 +67,50 @@ func (tc *TransactionControllerImpl) GetTransactionByPartnerID(ctx echo.Context)
 
 	return helper.NewResponses[any](ctx, http.StatusOK, "Success Get Transaction By Partner ID", results, nil, nil)
 }
+
+func (tc *TransactionControllerImpl) CancelTransaction(ctx echo.Context) error {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, "invalid transaction id", nil, err, nil)
+	}
+
+	results, err := tc.TransactionSvc.CancelTransaction(ctx.Request().Context(), id)
+	if err != nil {
+		return newErrorResponse(ctx, err, "Error Cancel Transaction")
+	}
+
+	return helper.NewResponses[any](ctx, http.StatusOK, "Success Cancel Transaction", results, nil, nil)
+}
+
+func (tc *TransactionControllerImpl) GetPatientTransactions(ctx echo.Context) error {
+	var historyReq model.GetTransactionHistoryRequest
+
+	if err := ctx.Bind(&historyReq); err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	// status can be repeated or comma separated, e.g. ?status=SUCCESS,REFUNDED
+	statuses := make([]model.TransactionStatusEnum, 0, len(historyReq.Statuses))
+	for _, status := range historyReq.Statuses {
+		for _, s := range strings.Split(string(status), ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				statuses = append(statuses, model.TransactionStatusEnum(strings.ToUpper(s)))
+			}
+		}
+	}
+	historyReq.Statuses = statuses
+
+	err := historyReq.Validate()
+	if err != nil {
+		return helper.NewResponses[any](ctx, http.StatusBadRequest, err.Error(), err.Error(), err, nil)
+	}
+
+	pages := helper.NewFromRequest(ctx)
+
+	results, err := tc.TransactionSvc.GetHistory(ctx.Request().Context(), &historyReq, pages)
+	if err != nil {
+		return newErrorResponse(ctx, err, "Error Get Patient Transactions")
+	}
+
+	return helper.NewResponses[any](ctx, http.StatusOK, "Success Get Patient Transactions", results, nil, pages)
+}
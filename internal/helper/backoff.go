@@ -0,0 +1,16 @@
+package helper
+
+import "time"
+
+// ExponentialBackoff return the wait before the next attempt, base is doubled on every failed attempt up to max
+func ExponentialBackoff(base, max time.Duration, attempts int) time.Duration {
+	backoff := base
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+
+	return backoff
+}
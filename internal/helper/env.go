@@ -16,7 +16,13 @@ func GetEnvInt(e string) int {
 }
 
 func GetEnvBool(e string) bool {
-	eBoolean, _ := strconv.ParseBool(e)
+	eBoolean, _ := strconv.ParseBool(os.Getenv(e))
 
 	return eBoolean
 }
+
+func GetEnvFloat(e string) float64 {
+	eFloat, _ := strconv.ParseFloat(os.Getenv(e), 64)
+
+	return eFloat
+}
@@ -0,0 +1,21 @@
+package helper
+
+import "math"
+
+// earthRadiusKm is mean radius of the earth in kilometers
+const earthRadiusKm = 6371.0
+
+// HaversineDistance return great-circle distance in kilometers between two coordinates
+func HaversineDistance(lat1, lng1, lat2, lng2 float64) float64 {
+	toRadian := func(deg float64) float64 {
+		return deg * math.Pi / 180
+	}
+
+	dLat := toRadian(lat2 - lat1)
+	dLng := toRadian(lng2 - lng1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadian(lat1))*math.Cos(toRadian(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,20 @@
+package helper
+
+import "strings"
+
+// FormatPhoneNumberE164 format indonesian phone number into E.164, e.g. 08123456789 into +628123456789.
+// Empty string is returned as is
+func FormatPhoneNumberE164(phoneNumber string) string {
+	phoneNumber = strings.NewReplacer(" ", "", "-", "").Replace(phoneNumber)
+
+	switch {
+	case phoneNumber == "", strings.HasPrefix(phoneNumber, "+"):
+		return phoneNumber
+	case strings.HasPrefix(phoneNumber, "0"):
+		return "+62" + phoneNumber[1:]
+	case strings.HasPrefix(phoneNumber, "62"):
+		return "+" + phoneNumber
+	default:
+		return "+62" + phoneNumber
+	}
+}
@@ -2,22 +2,21 @@ package infrastructure
 
 import (
 	"e-resep-be/internal/application"
+	"e-resep-be/internal/middleware"
 
 	"github.com/labstack/echo/v4"
 )
 
 // ServeHTTP is wrapper function to start the apps infra in HTTP mode
-func ServeHTTP(app *application.App) *echo.Echo {
+func ServeHTTP(app *application.App, dep *application.Dependency) *echo.Echo {
 	// call setup router
-	setupRouter(app)
+	setupRouter(app, dep)
 
 	return app.Application
 }
 
 // setupRouter is function to manage all routings
-func setupRouter(app *application.App) {
-	var dep = application.SetupDependencyInjection(app)
-
+func setupRouter(app *application.App, dep *application.Dependency) {
 	v1 := app.Application.Group("/api/v1")
 	{
 		v1.GET("/health-check", dep.HealthCheckController.Check)
@@ -25,8 +24,11 @@ func setupRouter(app *application.App) {
 		prescription := v1.Group("/prescription")
 		{
 			prescription.POST("", dep.PrescriptionController.Create)
+			prescription.POST("/bundle", dep.PrescriptionController.CreateBundle)
 
 			prescription.GET("/:id", dep.PrescriptionController.GetByPrescriptionID)
+			prescription.PATCH("/:id/status", dep.PrescriptionController.UpdatePrescriptionStatus, middleware.VerifyStaffToken(app.Config, app.Logger))
+			prescription.PATCH("/medication-request/:id", dep.PrescriptionController.PatchMedicationRequest, middleware.VerifyStaffToken(app.Config, app.Logger))
 		}
 
 		v1.GET("/province", dep.AddressController.GetProvince)
@@ -38,18 +40,40 @@ func setupRouter(app *application.App) {
 		{
 			patient.POST("/address", dep.PatientAddressController.Create)
 			patient.PUT("/address/:id", dep.PatientAddressController.Update)
+			patient.GET("/:ref_id/transactions", dep.TransactionController.GetPatientTransactions)
+			patient.GET("/:ref_id/transactions/:id/tracking", dep.FulfilmentController.GetTracking)
 		}
 
 		payment := v1.Group("/payment")
 		{
 			payment.POST("/info", dep.PaymentController.GeneratePaymentInfo)
-			payment.POST("/notification", dep.PaymentController.PaymentNotification)
+			payment.POST("/notification", dep.PaymentController.PaymentNotification, middleware.VerifyXenditCallbackToken(app.Config, app.Logger))
+			payment.POST("/charge/notification", dep.PaymentController.PaymentNotification, middleware.VerifyXenditCallbackToken(app.Config, app.Logger))
+			payment.POST("/refund/notification", dep.RefundController.RefundNotification, middleware.VerifyXenditCallbackToken(app.Config, app.Logger))
+
+			// notification of midtrans can't be verified without server key
+			if app.Config.Midtrans.Enabled() {
+				payment.POST("/midtrans/notification", dep.PaymentController.MidtransNotification)
+			}
 		}
 
 		transaction := v1.Group("/transaction")
 		{
 			transaction.POST("", dep.TransactionController.CreateTransaction)
 			transaction.GET("/:partner_id", dep.TransactionController.GetTransactionByPartnerID)
+			transaction.POST("/:id/cancel", dep.TransactionController.CancelTransaction, middleware.VerifyStaffToken(app.Config, app.Logger))
+			transaction.POST("/:id/refund", dep.RefundController.CreateRefund, middleware.VerifyStaffToken(app.Config, app.Logger))
+			transaction.PATCH("/:id/fulfilment", dep.FulfilmentController.UpdateFulfilment, middleware.VerifyStaffToken(app.Config, app.Logger))
+		}
+
+		reconciliation := v1.Group("/reconciliation", middleware.VerifyStaffToken(app.Config, app.Logger))
+		{
+			reconciliation.GET("/discrepancies", dep.ReconciliationController.GetDiscrepancies)
+		}
+
+		courier := v1.Group("/courier")
+		{
+			courier.POST("/notification", dep.FulfilmentController.CourierNotification, middleware.VerifyCourierCallbackToken(app.Config, app.Logger))
 		}
 
 	}
@@ -0,0 +1,13 @@
+package infrastructure
+
+import (
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/stub"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ServeStub is wrapper function to start partner stub server in HTTP mode
+func ServeStub(config *config.Configuration) *echo.Echo {
+	return stub.NewServer(config)
+}
@@ -0,0 +1,41 @@
+package infrastructure
+
+import (
+	"context"
+	"e-resep-be/internal/application"
+	"e-resep-be/internal/worker"
+	"time"
+)
+
+const (
+	// defaultReconciliationInterval is used when RECONCILIATION_INTERVAL is not configured
+	defaultReconciliationInterval = time.Minute
+	// defaultOutboxInterval is used when OUTBOX_INTERVAL is not configured
+	defaultOutboxInterval = 5 * time.Second
+)
+
+// ServeWorker is wrapper function to start the apps background workers, workers run until ctx is cancelled
+func ServeWorker(ctx context.Context, app *application.App, dep *application.Dependency) []*worker.Worker {
+	reconciliationInterval := defaultReconciliationInterval
+	if app.Config.Reconciliation.ReconciliationInterval > 0 {
+		reconciliationInterval = time.Duration(app.Config.Reconciliation.ReconciliationInterval) * time.Second
+	}
+
+	outboxInterval := defaultOutboxInterval
+	if app.Config.Outbox.OutboxInterval > 0 {
+		outboxInterval = time.Duration(app.Config.Outbox.OutboxInterval) * time.Second
+	}
+
+	workers := []*worker.Worker{
+		worker.NewWorker("pending-transaction-reconciliation", reconciliationInterval, app.Logger, dep.ReconciliationService.ResolveStalePendingTransactions),
+		worker.NewWorker("invoice-reconciliation", reconciliationInterval, app.Logger, dep.ReconciliationService.ReconcileProcessPayments),
+		worker.NewWorker("refund-resubmission", reconciliationInterval, app.Logger, dep.RefundService.ResubmitUnacknowledgedRefunds),
+		worker.NewWorker("outbox-dispatcher", outboxInterval, app.Logger, dep.OutboxService.DispatchPendingMessages),
+	}
+
+	for _, w := range workers {
+		w.Start(ctx)
+	}
+
+	return workers
+}
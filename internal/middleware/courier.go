@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"e-resep-be/internal/config"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// HeaderCourierCallbackToken is header sent by courier on every webhook, its value is the token configured on courier dashboard
+const HeaderCourierCallbackToken = "x-callback-token"
+
+// VerifyCourierCallbackToken reject courier webhook with 401 when x-callback-token header doesn't match configured token,
+// every webhook is rejected while COURIER_CALLBACK_TOKEN is empty
+func VerifyCourierCallbackToken(config *config.Configuration, logger *logrus.Logger) echo.MiddlewareFunc {
+	return verifyCallbackToken(HeaderCourierCallbackToken, config.Courier.CourierCallbackToken, logger)
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"e-resep-be/internal/config"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// HeaderStaffToken is header sent by staff dashboard and EMR on routes that change prescription, transaction or fulfilment
+const HeaderStaffToken = "x-staff-token"
+
+// VerifyStaffToken reject request with 401 when x-staff-token header doesn't match configured token,
+// every request is rejected while STAFF_API_TOKEN is empty
+func VerifyStaffToken(config *config.Configuration, logger *logrus.Logger) echo.MiddlewareFunc {
+	return verifyToken("staff", HeaderStaffToken, config.Auth.StaffAPIToken, logger)
+}
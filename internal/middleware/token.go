@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"e-resep-be/internal/helper"
+	"e-resep-be/internal/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// verifyToken reject request with 401 when the given header doesn't match expected token, every request is rejected
+// while expected token is not configured. kind name the caller in the log and response, e.g. callback
+func verifyToken(kind, header, expected string, logger *logrus.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			token := ctx.Request().Header.Get(header)
+
+			reason := ""
+			switch {
+			case expected == "":
+				reason = kind + " token is not configured"
+			case token == "":
+				reason = "missing " + kind + " token"
+			case subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1:
+				reason = "invalid " + kind + " token"
+			}
+
+			if reason != "" {
+				logger.WithFields(logrus.Fields{
+					"request_id": ctx.Response().Header().Get(echo.HeaderXRequestID),
+					"remote_ip":  ctx.RealIP(),
+					"method":     ctx.Request().Method,
+					"path":       ctx.Request().URL.Path,
+					"user_agent": ctx.Request().UserAgent(),
+					"header":     header,
+					"reason":     reason,
+				}).Warn("verifyToken REJECTED ", kind)
+
+				return helper.NewResponses[any](ctx, http.StatusUnauthorized, "Unauthorized", nil, model.NewError(model.Validation, reason), nil)
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// verifyCallbackToken reject partner callback with 401 when the given header doesn't match expected token
+func verifyCallbackToken(header, expected string, logger *logrus.Logger) echo.MiddlewareFunc {
+	return verifyToken("callback", header, expected, logger)
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"e-resep-be/internal/config"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// HeaderXenditCallbackToken is header sent by xendit on every callback, its value is the verification token on xendit dashboard
+const HeaderXenditCallbackToken = "x-callback-token"
+
+// VerifyXenditCallbackToken reject xendit callback with 401 when x-callback-token header doesn't match configured token
+func VerifyXenditCallbackToken(config *config.Configuration, logger *logrus.Logger) echo.MiddlewareFunc {
+	return verifyCallbackToken(HeaderXenditCallbackToken, config.Xendit.XenditCallbackToken, logger)
+}
@@ -0,0 +1,296 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type (
+	// Bundle is FHIR R4 Bundle resource, transaction bundle is ingested and transaction-response bundle is returned
+	Bundle struct {
+		ResourceType string        `json:"resourceType"`
+		ID           string        `json:"id,omitempty"`
+		Type         string        `json:"type"`
+		Entry        []BundleEntry `json:"entry"`
+	}
+
+	BundleEntry struct {
+		FullURL  string               `json:"fullUrl,omitempty"`
+		Resource json.RawMessage      `json:"resource,omitempty"`
+		Request  *BundleEntryRequest  `json:"request,omitempty"`
+		Response *BundleEntryResponse `json:"response,omitempty"`
+	}
+
+	BundleEntryRequest struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	}
+
+	BundleEntryResponse struct {
+		Status   string            `json:"status"`
+		Location string            `json:"location,omitempty"`
+		Outcome  *OperationOutcome `json:"outcome,omitempty"`
+	}
+
+	// FHIRPatient is the part of FHIR Patient resource used to contact the patient
+	FHIRPatient struct {
+		ResourceType string         `json:"resourceType"`
+		ID           string         `json:"id"`
+		Identifier   []Identifier   `json:"identifier"`
+		Name         []HumanName    `json:"name"`
+		Telecom      []ContactPoint `json:"telecom"`
+	}
+
+	HumanName struct {
+		Text   string   `json:"text"`
+		Family string   `json:"family"`
+		Given  []string `json:"given"`
+	}
+
+	ContactPoint struct {
+		System string `json:"system"`
+		Value  string `json:"value"`
+		Use    string `json:"use"`
+	}
+
+	// BundlePrescription is a medication request with its medication resolved from transaction bundle,
+	// entry indexes point to the bundle entries it is taken from
+	BundlePrescription struct {
+		Prescription    PrescriptionRequest
+		PhoneNumber     string
+		Email           string
+		MedicationEntry int
+		RequestEntry    int
+		// PatientEntry is -1 when subject is not included in the bundle
+		PatientEntry int
+	}
+
+	// BundleError is transaction-response bundle of rejected transaction bundle, every entry keep its own outcome
+	BundleError struct {
+		Bundle
+	}
+
+	// bundleResource is used to find resource type of bundle entry before decoding it
+	bundleResource struct {
+		ResourceType string `json:"resourceType"`
+		ID           string `json:"id"`
+	}
+)
+
+const (
+	BundleTypeTransaction         = "transaction"
+	BundleTypeTransactionResponse = "transaction-response"
+)
+
+func (e *BundleError) Error() string {
+	var msgs []string
+	for i, entry := range e.Entry {
+		if entry.Response != nil && entry.Response.Outcome != nil {
+			msgs = append(msgs, fmt.Sprintf("entry[%d]: %s", i, entry.Response.Outcome.Error()))
+		}
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Phone return the first phone number of patient, empty when there is none
+func (p FHIRPatient) Phone() string {
+	return p.contact("phone")
+}
+
+// Email return the first email of patient, empty when there is none
+func (p FHIRPatient) Email() string {
+	return p.contact("email")
+}
+
+func (p FHIRPatient) contact(system string) string {
+	for _, telecom := range p.Telecom {
+		if telecom.System == system && telecom.Value != "" {
+			return telecom.Value
+		}
+	}
+
+	return ""
+}
+
+// Prescriptions resolve every MedicationRequest of transaction bundle with the Medication and Patient it reference.
+// Reference may use resource id (Medication/{id}) or entry fullUrl (urn:uuid:...), the latter is rewritten into resource id.
+// Bundle level issue is returned as *OperationOutcome and entry issues as *BundleError
+func (v Bundle) Prescriptions() ([]BundlePrescription, error) {
+	b := &outcomeBuilder{}
+	b.check("Bundle.resourceType", v.ResourceType, validation.Required, validation.In("Bundle"))
+	b.check("Bundle.type", v.Type, validation.Required, validation.In(BundleTypeTransaction))
+	b.check("Bundle.entry", v.Entry, validation.Required)
+	if err := b.err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		entryIssues = make([]*outcomeBuilder, len(v.Entry))
+		medications = map[string]int{}
+		patients    = map[string]int{}
+		requests    = []int{}
+		decoded     = make([]interface{}, len(v.Entry))
+	)
+
+	for i, entry := range v.Entry {
+		entryIssues[i] = &outcomeBuilder{}
+
+		var resource bundleResource
+		if err := json.Unmarshal(entry.Resource, &resource); err != nil {
+			entryIssues[i].add(fmt.Sprintf("Bundle.entry[%d].resource", i), "structure", "must be a FHIR resource")
+			continue
+		}
+
+		var err error
+		switch resource.ResourceType {
+		case "Medication":
+			var medication Medication
+			err = json.Unmarshal(entry.Resource, &medication)
+			decoded[i] = medication
+			indexReference(medications, "Medication", resource.ID, entry.FullURL, i)
+		case "Patient":
+			var patient FHIRPatient
+			err = json.Unmarshal(entry.Resource, &patient)
+			decoded[i] = patient
+			indexReference(patients, "Patient", resource.ID, entry.FullURL, i)
+		case "MedicationRequest":
+			var request MedicationRequest
+			err = json.Unmarshal(entry.Resource, &request)
+			decoded[i] = request
+			requests = append(requests, i)
+		default:
+			entryIssues[i].add(fmt.Sprintf("Bundle.entry[%d].resource.resourceType", i), "not-supported", "must be Patient, Medication or MedicationRequest")
+		}
+
+		if err != nil {
+			entryIssues[i].add(fmt.Sprintf("Bundle.entry[%d].resource", i), "structure", err.Error())
+		}
+	}
+
+	if len(requests) == 0 {
+		b.add("Bundle.entry", "required", "must contain at least one MedicationRequest")
+		return nil, b.err()
+	}
+
+	var (
+		prescriptions  = make([]BundlePrescription, 0, len(requests))
+		referencedMeds = map[int]bool{}
+	)
+
+	for _, i := range requests {
+		request, ok := decoded[i].(MedicationRequest)
+		if !ok || len(entryIssues[i].issues) > 0 {
+			continue
+		}
+
+		medicationEntry, ok := medications[request.MedicationReference.Reference]
+		if !ok {
+			entryIssues[i].add("MedicationRequest.medicationReference.reference", "not-found", "must reference a Medication entry of the bundle")
+			continue
+		}
+		referencedMeds[medicationEntry] = true
+
+		medication, _ := decoded[medicationEntry].(Medication)
+		request.MedicationReference.Reference = "Medication/" + medication.ID
+
+		prescription := BundlePrescription{
+			MedicationEntry: medicationEntry,
+			RequestEntry:    i,
+			PatientEntry:    -1,
+		}
+
+		// patient entry is optional, subject may reference patient that is already known
+		if patientEntry, ok := patients[request.Subject.Reference]; ok {
+			patient, _ := decoded[patientEntry].(FHIRPatient)
+			request.Subject.Reference = "Patient/" + patient.ID
+			if request.Subject.Display == "" && len(patient.Name) > 0 {
+				request.Subject.Display = patient.Name[0].Text
+			}
+
+			prescription.PhoneNumber = patient.Phone()
+			prescription.Email = patient.Email()
+			prescription.PatientEntry = patientEntry
+		}
+
+		prescription.Prescription = PrescriptionRequest{
+			Medication:        medication,
+			MedicationRequest: request,
+		}
+
+		// issues of the pair belong to the entry of the resource they are found on
+		if err := prescription.Prescription.Validate(); err != nil {
+			outcome, _ := err.(*OperationOutcome)
+			for _, issue := range outcome.Issue {
+				entry := i
+				if strings.HasPrefix(issue.Expression[0], "Medication.") {
+					entry = medicationEntry
+				}
+
+				entryIssues[entry].issues = append(entryIssues[entry].issues, issue)
+			}
+		}
+
+		prescriptions = append(prescriptions, prescription)
+	}
+
+	for i := range v.Entry {
+		if _, ok := decoded[i].(Medication); ok && !referencedMeds[i] {
+			entryIssues[i].add(fmt.Sprintf("Bundle.entry[%d].resource", i), "invariant", "must be referenced by a MedicationRequest of the bundle")
+		}
+	}
+
+	// transaction is all or nothing, entries without issue are reported as not processed
+	invalid := false
+	for _, issues := range entryIssues {
+		if len(issues.issues) > 0 {
+			invalid = true
+			break
+		}
+	}
+
+	if !invalid {
+		return prescriptions, nil
+	}
+
+	resp := &BundleError{Bundle: NewTransactionResponse(len(v.Entry))}
+	for i, issues := range entryIssues {
+		if outcome, ok := issues.err().(*OperationOutcome); ok {
+			resp.Entry[i].Response = &BundleEntryResponse{
+				Status:  fmt.Sprintf("%d %s", http.StatusUnprocessableEntity, http.StatusText(http.StatusUnprocessableEntity)),
+				Outcome: outcome,
+			}
+			continue
+		}
+
+		resp.Entry[i].Response = &BundleEntryResponse{
+			Status: fmt.Sprintf("%d %s", http.StatusFailedDependency, http.StatusText(http.StatusFailedDependency)),
+		}
+	}
+
+	return nil, resp
+}
+
+// NewTransactionResponse return transaction-response bundle with the given number of entries, entry response is filled by caller
+func NewTransactionResponse(entries int) Bundle {
+	return Bundle{
+		ResourceType: "Bundle",
+		Type:         BundleTypeTransactionResponse,
+		Entry:        make([]BundleEntry, entries),
+	}
+}
+
+// indexReference register every reference the resource can be referenced by inside the bundle
+func indexReference(index map[string]int, resourceType, id, fullURL string, entry int) {
+	if id != "" {
+		index[resourceType+"/"+id] = entry
+	}
+
+	if fullURL != "" {
+		index[fullURL] = entry
+	}
+}
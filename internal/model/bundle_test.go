@@ -0,0 +1,245 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// bundleEntry return entry of transaction bundle with the given resource
+func bundleEntry(t *testing.T, fullURL string, resource interface{}) BundleEntry {
+	t.Helper()
+
+	raw, err := json.Marshal(resource)
+	if err != nil {
+		t.Fatalf("marshal resource: %v", err)
+	}
+
+	return BundleEntry{
+		FullURL:  fullURL,
+		Resource: raw,
+		Request:  &BundleEntryRequest{Method: "POST"},
+	}
+}
+
+func TestBundlePrescriptions(t *testing.T) {
+	patient := FHIRPatient{
+		ResourceType: "Patient",
+		ID:           "P001",
+		Name:         []HumanName{{Text: "Budi"}},
+		Telecom: []ContactPoint{
+			{System: "email", Value: "budi@example.com"},
+			{System: "phone", Value: "08123456789"},
+		},
+	}
+
+	withRequest := func(modify func(req *MedicationRequest)) MedicationRequest {
+		req := validPrescriptionRequest().MedicationRequest
+		modify(&req)
+
+		return req
+	}
+
+	tests := []struct {
+		name string
+		// entries is built from the valid prescription of validPrescriptionRequest
+		entries    func(t *testing.T) []BundleEntry
+		bundleType string
+		// wantPrescriptions is checked when bundle is accepted
+		wantPrescriptions []BundlePrescription
+		// wantOutcome is expected when bundle level issue is found
+		wantOutcome bool
+		// wantEntryStatuses is expected when some entries are rejected
+		wantEntryStatuses []string
+	}{
+		{
+			name: "reference by resource id",
+			entries: func(t *testing.T) []BundleEntry {
+				req := validPrescriptionRequest()
+				return []BundleEntry{
+					bundleEntry(t, "", req.Medication),
+					bundleEntry(t, "", patient),
+					bundleEntry(t, "", req.MedicationRequest),
+				}
+			},
+			wantPrescriptions: []BundlePrescription{{
+				PhoneNumber:     "08123456789",
+				Email:           "budi@example.com",
+				MedicationEntry: 0,
+				RequestEntry:    2,
+				PatientEntry:    1,
+			}},
+		},
+		{
+			name: "reference by fullUrl is rewritten into resource id",
+			entries: func(t *testing.T) []BundleEntry {
+				req := validPrescriptionRequest()
+				request := withRequest(func(r *MedicationRequest) {
+					r.MedicationReference.Reference = "urn:uuid:med"
+					r.Subject = User{Reference: "urn:uuid:patient"}
+				})
+				return []BundleEntry{
+					bundleEntry(t, "urn:uuid:request", request),
+					bundleEntry(t, "urn:uuid:med", req.Medication),
+					bundleEntry(t, "urn:uuid:patient", patient),
+				}
+			},
+			wantPrescriptions: []BundlePrescription{{
+				PhoneNumber:     "08123456789",
+				Email:           "budi@example.com",
+				MedicationEntry: 1,
+				RequestEntry:    0,
+				PatientEntry:    2,
+			}},
+		},
+		{
+			name: "patient is not included",
+			entries: func(t *testing.T) []BundleEntry {
+				req := validPrescriptionRequest()
+				return []BundleEntry{
+					bundleEntry(t, "", req.Medication),
+					bundleEntry(t, "", req.MedicationRequest),
+				}
+			},
+			wantPrescriptions: []BundlePrescription{{
+				MedicationEntry: 0,
+				RequestEntry:    1,
+				PatientEntry:    -1,
+			}},
+		},
+		{
+			name: "not a transaction bundle",
+			entries: func(t *testing.T) []BundleEntry {
+				req := validPrescriptionRequest()
+				return []BundleEntry{
+					bundleEntry(t, "", req.Medication),
+					bundleEntry(t, "", req.MedicationRequest),
+				}
+			},
+			bundleType:  "batch",
+			wantOutcome: true,
+		},
+		{
+			name: "no medication request",
+			entries: func(t *testing.T) []BundleEntry {
+				return []BundleEntry{
+					bundleEntry(t, "", validPrescriptionRequest().Medication),
+				}
+			},
+			wantOutcome: true,
+		},
+		{
+			name: "invalid medication request",
+			entries: func(t *testing.T) []BundleEntry {
+				req := validPrescriptionRequest()
+				return []BundleEntry{
+					bundleEntry(t, "", req.Medication),
+					bundleEntry(t, "", patient),
+					bundleEntry(t, "", withRequest(func(r *MedicationRequest) { r.Intent = "" })),
+				}
+			},
+			wantEntryStatuses: []string{"424 Failed Dependency", "424 Failed Dependency", "422 Unprocessable Entity"},
+		},
+		{
+			name: "issue of medication is reported on medication entry",
+			entries: func(t *testing.T) []BundleEntry {
+				req := validPrescriptionRequest()
+				req.Medication.Status = "unknown"
+				return []BundleEntry{
+					bundleEntry(t, "", req.MedicationRequest),
+					bundleEntry(t, "", req.Medication),
+				}
+			},
+			wantEntryStatuses: []string{"424 Failed Dependency", "422 Unprocessable Entity"},
+		},
+		{
+			name: "medication is not in the bundle",
+			entries: func(t *testing.T) []BundleEntry {
+				req := validPrescriptionRequest()
+				return []BundleEntry{
+					bundleEntry(t, "", patient),
+					bundleEntry(t, "", req.MedicationRequest),
+				}
+			},
+			wantEntryStatuses: []string{"424 Failed Dependency", "422 Unprocessable Entity"},
+		},
+		{
+			name: "unreferenced medication and unsupported resource",
+			entries: func(t *testing.T) []BundleEntry {
+				req := validPrescriptionRequest()
+				other := req.Medication
+				other.ID = "med-2"
+				return []BundleEntry{
+					bundleEntry(t, "", req.Medication),
+					bundleEntry(t, "", req.MedicationRequest),
+					bundleEntry(t, "", other),
+					bundleEntry(t, "", map[string]string{"resourceType": "Encounter", "id": "E001"}),
+				}
+			},
+			wantEntryStatuses: []string{"424 Failed Dependency", "424 Failed Dependency", "422 Unprocessable Entity", "422 Unprocessable Entity"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundleType := tt.bundleType
+			if bundleType == "" {
+				bundleType = BundleTypeTransaction
+			}
+
+			bundle := Bundle{
+				ResourceType: "Bundle",
+				Type:         bundleType,
+				Entry:        tt.entries(t),
+			}
+
+			got, err := bundle.Prescriptions()
+
+			switch {
+			case tt.wantOutcome:
+				if _, ok := err.(*OperationOutcome); !ok {
+					t.Fatalf("error = %v, want *OperationOutcome", err)
+				}
+			case tt.wantEntryStatuses != nil:
+				bundleErr, ok := err.(*BundleError)
+				if !ok {
+					t.Fatalf("error = %v, want *BundleError", err)
+				}
+
+				if bundleErr.Type != BundleTypeTransactionResponse || len(bundleErr.Entry) != len(tt.wantEntryStatuses) {
+					t.Fatalf("response = %+v, want %d entries of transaction-response", bundleErr.Bundle, len(tt.wantEntryStatuses))
+				}
+
+				for i, entry := range bundleErr.Entry {
+					if entry.Response == nil || entry.Response.Status != tt.wantEntryStatuses[i] {
+						t.Errorf("entry[%d] response = %+v, want status %s", i, entry.Response, tt.wantEntryStatuses[i])
+					}
+				}
+			default:
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if len(got) != len(tt.wantPrescriptions) {
+					t.Fatalf("got %d prescriptions, want %d", len(got), len(tt.wantPrescriptions))
+				}
+
+				for i, want := range tt.wantPrescriptions {
+					p := got[i]
+					if p.PhoneNumber != want.PhoneNumber || p.Email != want.Email ||
+						p.MedicationEntry != want.MedicationEntry || p.RequestEntry != want.RequestEntry || p.PatientEntry != want.PatientEntry {
+						t.Errorf("prescription[%d] = %+v, want %+v", i, p, want)
+					}
+
+					request := p.Prescription.MedicationRequest
+					if request.MedicationReference.Reference != "Medication/med-1" {
+						t.Errorf("medication reference = %s, want Medication/med-1", request.MedicationReference.Reference)
+					}
+
+					if want.PatientEntry >= 0 && (request.Subject.Reference != "Patient/P001" || request.Subject.Display != "Budi") {
+						t.Errorf("subject = %+v, want Patient/P001 Budi", request.Subject)
+					}
+				}
+			}
+		})
+	}
+}
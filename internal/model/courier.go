@@ -0,0 +1,74 @@
+package model
+
+import "time"
+
+type (
+	CourierStatusEnum string
+
+	// CourierShipmentRequest is the body sent to courier create shipment endpoint
+	CourierShipmentRequest struct {
+		ReferenceID string          `json:"reference_id"`
+		ServiceType string          `json:"service_type"`
+		Origin      CourierLocation `json:"origin"`
+		Destination CourierLocation `json:"destination"`
+		Items       []CourierItem   `json:"items"`
+	}
+
+	CourierLocation struct {
+		Name        string  `json:"name"`
+		PhoneNumber string  `json:"phone_number"`
+		Address     string  `json:"address"`
+		Latitude    float64 `json:"latitude"`
+		Longitude   float64 `json:"longitude"`
+		Notes       string  `json:"notes,omitempty"`
+	}
+
+	CourierItem struct {
+		Name     string `json:"name"`
+		Quantity int    `json:"quantity"`
+	}
+
+	// CourierShipment is a shipment booked on courier, it is returned by courier and sent inside courier webhook
+	CourierShipment struct {
+		ID             string            `json:"id"`
+		ReferenceID    string            `json:"reference_id"`
+		TrackingNumber string            `json:"tracking_number"`
+		TrackingURL    string            `json:"tracking_url"`
+		Status         CourierStatusEnum `json:"status"`
+		Price          int               `json:"price"`
+		UpdatedAt      time.Time         `json:"updated_at"`
+	}
+
+	// CourierShipmentResponse is the success envelope returned by courier shipment endpoints
+	CourierShipmentResponse struct {
+		Data CourierShipment `json:"data"`
+	}
+
+	// CourierErrorResponse is the error envelope returned by courier on non-2xx responses
+	CourierErrorResponse struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	// CourierNotification is shipment status change sent by courier webhook, retries of the same event share the same event id
+	CourierNotification struct {
+		EventID string          `json:"event_id"`
+		Data    CourierShipment `json:"data"`
+	}
+)
+
+const (
+	CourierStatusEnumAllocating CourierStatusEnum = "ALLOCATING"
+	CourierStatusEnumPickingUp  CourierStatusEnum = "PICKING_UP"
+	CourierStatusEnumOnDelivery CourierStatusEnum = "ON_DELIVERY"
+	CourierStatusEnumDelivered  CourierStatusEnum = "DELIVERED"
+	// CourierStatusEnumCancelled is shipment that is cancelled before pickup, e.g. no driver is found
+	CourierStatusEnumCancelled CourierStatusEnum = "CANCELLED"
+	// CourierStatusEnumReturned is shipment that couldn't be delivered and is sent back to pharmacy
+	CourierStatusEnumReturned CourierStatusEnum = "RETURNED"
+)
+
+// CourierServiceTypeInstant is courier service that deliver right after pickup
+const CourierServiceTypeInstant = "instant"
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+type (
+	// DispenseOrder is the order of paid transaction accepted by kimia farma, it is pushed by outbox dispatcher
+	DispenseOrder struct {
+		ID            int        `db:"id" json:"id"`
+		TransactionID int        `db:"transaction_id" json:"transaction_id"`
+		PartnerID     string     `db:"partner_id" json:"partner_id"`
+		SubmittedAt   time.Time  `db:"submitted_at" json:"submitted_at"`
+		CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+		UpdatedAt     *time.Time `db:"updated_at" json:"updated_at"`
+	}
+
+	// DispenseOrderItem is transaction item with the medication request it is prescribed by
+	DispenseOrderItem struct {
+		MedicationID        int                 `db:"medication_id" json:"medication_id"`
+		KFACode             string              `db:"code" json:"kfa_code"`
+		Name                string              `db:"medication_name" json:"name"`
+		Price               int                 `db:"price" json:"price"`
+		MedicationRequestID string              `db:"ref_id" json:"medication_request_id"`
+		DosageInstructions  []DosageInstruction `db:"dosage_instructions" json:"dosage_instructions"`
+		DispenseRequest     DispenseRequest     `db:"dispense_request" json:"dispense_request"`
+	}
+)
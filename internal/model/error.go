@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -10,10 +11,61 @@ const (
 	Validation  ErrorKind = "Validation Error"
 	TypeInvalid ErrorKind = "Type Error"
 	NotFound    ErrorKind = "Not Found"
+	Partner     ErrorKind = "Partner Error"
+	Conflict    ErrorKind = "Conflict"
 	Unknown     ErrorKind = "Unknown Error"
 )
 
+// Error is a dynamic error that keep its kind, so callers can decide how to handle it
+type Error struct {
+	kind ErrorKind
+	msg  string
+}
+
 // NewError return wrapped dynamic errors
 func NewError(kind ErrorKind, msg string) error {
-	return fmt.Errorf("%s: %s", string(kind), msg)
+	return &Error{
+		kind: kind,
+		msg:  msg,
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", string(e.kind), e.msg)
+}
+
+// Kind return kind of the error
+func (e *Error) Kind() ErrorKind {
+	return e.kind
+}
+
+// KindOf return kind of the first dynamic error found in err chain, Unknown when there is none
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.kind
+	}
+
+	return Unknown
+}
+
+// rejectedError mark error of request that partner definitely refused, nothing is created on partner side
+type rejectedError struct {
+	error
+}
+
+func (e *rejectedError) Unwrap() error {
+	return e.error
+}
+
+// Rejected mark err as a definite rejection of partner, its message and kind are kept
+func Rejected(err error) error {
+	return &rejectedError{err}
+}
+
+// IsRejected tell partner definitely refused the request from an error of unknown outcome, e.g. timeout or 5xx
+func IsRejected(err error) bool {
+	var e *rejectedError
+
+	return errors.As(err, &e)
 }
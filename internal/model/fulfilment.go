@@ -0,0 +1,127 @@
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type (
+	FulfilmentStatusEnum string
+
+	// Fulfilment is the delivery progress of paid transaction, every step keep the time it is reached
+	Fulfilment struct {
+		ID            int                  `db:"id" json:"id"`
+		TransactionID int                  `db:"transaction_id" json:"transaction_id"`
+		Status        FulfilmentStatusEnum `db:"status" json:"status"`
+		PaidAt        time.Time            `db:"paid_at" json:"paid_at"`
+		PreparingAt   *time.Time           `db:"preparing_at" json:"preparing_at"`
+		ShippedAt     *time.Time           `db:"shipped_at" json:"shipped_at"`
+		DeliveredAt   *time.Time           `db:"delivered_at" json:"delivered_at"`
+		ReturnedAt    *time.Time           `db:"returned_at" json:"returned_at"`
+		Note          *string              `db:"note" json:"note"`
+		CreatedAt     time.Time            `db:"created_at" json:"created_at"`
+		UpdatedAt     *time.Time           `db:"updated_at" json:"updated_at"`
+	}
+
+	// UpdateFulfilmentRequest advance fulfilment into the next status
+	UpdateFulfilmentRequest struct {
+		Status FulfilmentStatusEnum `json:"status"`
+		Note   string               `json:"note"`
+	}
+
+	// FulfilmentTracking is the patient view of fulfilment, steps are ordered and only reached steps have time
+	FulfilmentTracking struct {
+		TransactionID int                  `json:"transaction_id"`
+		Status        FulfilmentStatusEnum `json:"status"`
+		Note          *string              `json:"note"`
+		Steps         []FulfilmentStep     `json:"steps"`
+		Shipment      *Shipment            `json:"shipment"`
+	}
+
+	FulfilmentStep struct {
+		Status  FulfilmentStatusEnum `json:"status"`
+		Reached bool                 `json:"reached"`
+		At      *time.Time           `json:"at"`
+	}
+)
+
+const (
+	FulfilmentStatusEnumPaid      FulfilmentStatusEnum = "PAID"
+	FulfilmentStatusEnumPreparing FulfilmentStatusEnum = "PREPARING"
+	FulfilmentStatusEnumShipped   FulfilmentStatusEnum = "SHIPPED"
+	FulfilmentStatusEnumDelivered FulfilmentStatusEnum = "DELIVERED"
+	// FulfilmentStatusEnumReturned is shipment that is sent back to pharmacy instead of delivered
+	FulfilmentStatusEnumReturned FulfilmentStatusEnum = "RETURNED"
+)
+
+// fulfilmentStatusTransitions list the legal next status of each fulfilment status, final status has no next status
+var fulfilmentStatusTransitions = map[FulfilmentStatusEnum][]FulfilmentStatusEnum{
+	FulfilmentStatusEnumPaid:      {FulfilmentStatusEnumPreparing},
+	FulfilmentStatusEnumPreparing: {FulfilmentStatusEnumShipped},
+	FulfilmentStatusEnumShipped:   {FulfilmentStatusEnumDelivered, FulfilmentStatusEnumReturned},
+}
+
+// CanTransitionTo check whether fulfilment status is allowed to move into next status
+func (s FulfilmentStatusEnum) CanTransitionTo(next FulfilmentStatusEnum) bool {
+	for _, status := range fulfilmentStatusTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReachedAt return the time fulfilment reach the given status, nil when it is not reached
+func (f Fulfilment) ReachedAt(status FulfilmentStatusEnum) *time.Time {
+	switch status {
+	case FulfilmentStatusEnumPaid:
+		return &f.PaidAt
+	case FulfilmentStatusEnumPreparing:
+		return f.PreparingAt
+	case FulfilmentStatusEnumShipped:
+		return f.ShippedAt
+	case FulfilmentStatusEnumDelivered:
+		return f.DeliveredAt
+	case FulfilmentStatusEnumReturned:
+		return f.ReturnedAt
+	default:
+		return nil
+	}
+}
+
+// Tracking return patient view of the fulfilment, returned step replace delivered step once shipment is returned
+func (f Fulfilment) Tracking() FulfilmentTracking {
+	lastStatus := FulfilmentStatusEnumDelivered
+	if f.Status == FulfilmentStatusEnumReturned {
+		lastStatus = FulfilmentStatusEnumReturned
+	}
+
+	tracking := FulfilmentTracking{
+		TransactionID: f.TransactionID,
+		Status:        f.Status,
+		Note:          f.Note,
+	}
+
+	for _, status := range []FulfilmentStatusEnum{FulfilmentStatusEnumPaid, FulfilmentStatusEnumPreparing, FulfilmentStatusEnumShipped, lastStatus} {
+		at := f.ReachedAt(status)
+		tracking.Steps = append(tracking.Steps, FulfilmentStep{
+			Status:  status,
+			Reached: at != nil,
+			At:      at,
+		})
+	}
+
+	return tracking
+}
+
+func (v UpdateFulfilmentRequest) Validate() error {
+	if err := validation.ValidateStruct(&v,
+		validation.Field(&v.Status, validation.Required, validation.In(FulfilmentStatusEnumPreparing, FulfilmentStatusEnumShipped, FulfilmentStatusEnumDelivered, FulfilmentStatusEnumReturned)),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
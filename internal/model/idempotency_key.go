@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+type (
+	// IdempotencyKey is Idempotency-Key header of a processed request, retry with the same key must carry the same request
+	IdempotencyKey struct {
+		ID          int       `db:"id" json:"id"`
+		Scope       string    `db:"scope" json:"scope"`
+		Key         string    `db:"key" json:"key"`
+		RequestHash string    `db:"request_hash" json:"request_hash"`
+		CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	}
+)
+
+// IdempotencyScopePrescription is scope of Idempotency-Key sent on create prescription
+const IdempotencyScopePrescription = "prescription"
@@ -1,9 +1,122 @@
 package model
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
 type (
-	// TODO : temporary response mapping
 	CheckAvailabilityResponse struct {
-		IsAvailable bool `json:"is_available"`
-		Price       int  `json:"price"`
+		KFACode     string `json:"kfa_code"`
+		IsAvailable bool   `json:"is_available"`
+		Price       int    `json:"price"`
+		Stock       int    `json:"stock"`
+	}
+
+	// KimiaFarmaAvailabilityResponse is the success envelope returned by kimia farma availability endpoint
+	KimiaFarmaAvailabilityResponse struct {
+		Data CheckAvailabilityResponse `json:"data"`
+	}
+
+	// CheckAvailabilityBatchResponse hold availability per kfa code, codes that couldn't be resolved are reported inside Errors
+	CheckAvailabilityBatchResponse struct {
+		Items  map[string]CheckAvailabilityResponse
+		Errors map[string]error
+	}
+
+	// KimiaFarmaBatchAvailabilityRequest is the body sent to kimia farma batch availability endpoint
+	KimiaFarmaBatchAvailabilityRequest struct {
+		KFACodes []string `json:"kfa_codes"`
+	}
+
+	// KimiaFarmaBatchAvailabilityResponse is the success envelope returned by kimia farma batch availability endpoint
+	KimiaFarmaBatchAvailabilityResponse struct {
+		Data   []CheckAvailabilityResponse `json:"data"`
+		Errors []KimiaFarmaBatchError      `json:"errors"`
+	}
+
+	KimiaFarmaBatchError struct {
+		KFACode string `json:"kfa_code"`
+		KimiaFarmaError
+	}
+
+	// KimiaFarmaErrorResponse is the error envelope returned by kimia farma on non-2xx responses
+	KimiaFarmaErrorResponse struct {
+		Error KimiaFarmaError `json:"error"`
+	}
+
+	KimiaFarmaError struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+
+	// KimiaFarmaDispenseOrderRequest is the body sent to kimia farma create dispense order endpoint
+	KimiaFarmaDispenseOrderRequest struct {
+		ReferenceID string                    `json:"reference_id"`
+		Items       []KimiaFarmaDispenseItem  `json:"items"`
+		Delivery    KimiaFarmaDeliveryAddress `json:"delivery"`
+	}
+
+	KimiaFarmaDispenseItem struct {
+		KFACode             string              `json:"kfa_code"`
+		Name                string              `json:"name"`
+		Quantity            int                 `json:"quantity"`
+		Price               int                 `json:"price"`
+		MedicationRequestID string              `json:"medication_request_id"`
+		DosageInstructions  []DosageInstruction `json:"dosage_instructions"`
+	}
+
+	KimiaFarmaDeliveryAddress struct {
+		RecipientName string  `json:"recipient_name"`
+		PhoneNumber   string  `json:"phone_number"`
+		Address       string  `json:"address"`
+		SubDistrict   string  `json:"sub_district"`
+		District      string  `json:"district"`
+		City          string  `json:"city"`
+		Province      string  `json:"province"`
+		PostalCode    string  `json:"postal_code"`
+		Latitude      float64 `json:"latitude"`
+		Longitude     float64 `json:"longitude"`
+		Notes         string  `json:"notes,omitempty"`
+	}
+
+	KimiaFarmaDispenseOrder struct {
+		OrderID     string `json:"order_id"`
+		ReferenceID string `json:"reference_id"`
+		Status      string `json:"status"`
+	}
+
+	// KimiaFarmaDispenseOrderResponse is the success envelope returned by kimia farma create dispense order endpoint
+	KimiaFarmaDispenseOrderResponse struct {
+		Data KimiaFarmaDispenseOrder `json:"data"`
 	}
 )
+
+// NewCheckAvailabilityBatchResponse return empty batch response ready to be filled
+func NewCheckAvailabilityBatchResponse() *CheckAvailabilityBatchResponse {
+	return &CheckAvailabilityBatchResponse{
+		Items:  map[string]CheckAvailabilityResponse{},
+		Errors: map[string]error{},
+	}
+}
+
+// Err return joined error of every unresolved kfa code, nil when all codes are resolved
+func (r *CheckAvailabilityBatchResponse) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+
+	codes := make([]string, 0, len(r.Errors))
+	for code := range r.Errors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	errs := make([]error, 0, len(codes))
+	for _, code := range codes {
+		errs = append(errs, fmt.Errorf("kfa code %s: %w", code, r.Errors[code]))
+	}
+
+	return errors.Join(errs...)
+}
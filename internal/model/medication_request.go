@@ -1,7 +1,11 @@
 package model
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 )
 
 type Category struct {
@@ -141,3 +145,162 @@ type MedicationRequest struct {
 	Insurance           interface{}         `json:"insurance"`
 	Substitution        interface{}         `json:"substitution"`
 }
+
+type (
+	// MedicationRequestStatusEnum is FHIR status code of medication request
+	MedicationRequestStatusEnum string
+
+	// MedicationRequestDB is stored medication request, only the columns used after ingestion are read
+	MedicationRequestDB struct {
+		ID                 int                         `db:"id" json:"id"`
+		RefID              string                      `db:"ref_id" json:"ref_id"`
+		MedicationID       int                         `db:"medication_id" json:"medication_id"`
+		PatientID          int                         `db:"patient_id" json:"patient_id"`
+		PrescriptionID     string                      `db:"prescription_id" json:"prescription_id"`
+		PrescriptionItemID string                      `db:"prescription_item_id" json:"prescription_item_id"`
+		Status             MedicationRequestStatusEnum `db:"status" json:"status"`
+		StatusReason       *string                     `db:"status_reason" json:"status_reason"`
+		StatusChangedAt    *time.Time                  `db:"status_changed_at" json:"status_changed_at"`
+		DispenseRequest    DispenseRequest             `db:"dispense_request" json:"dispense_request"`
+	}
+
+	// UpdateMedicationRequestStatusRequest move medication request into the given status, reason is kept as statusReason
+	UpdateMedicationRequestStatusRequest struct {
+		Status       MedicationRequestStatusEnum `json:"status"`
+		StatusReason string                      `json:"statusReason"`
+	}
+
+	// JSONPatchOperation is one operation of JSON Patch document sent on FHIR PATCH
+	JSONPatchOperation struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	}
+)
+
+const (
+	MedicationRequestStatusEnumActive         MedicationRequestStatusEnum = "active"
+	MedicationRequestStatusEnumOnHold         MedicationRequestStatusEnum = "on-hold"
+	MedicationRequestStatusEnumCancelled      MedicationRequestStatusEnum = "cancelled"
+	MedicationRequestStatusEnumCompleted      MedicationRequestStatusEnum = "completed"
+	MedicationRequestStatusEnumEnteredInError MedicationRequestStatusEnum = "entered-in-error"
+	MedicationRequestStatusEnumStopped        MedicationRequestStatusEnum = "stopped"
+	MedicationRequestStatusEnumDraft          MedicationRequestStatusEnum = "draft"
+	MedicationRequestStatusEnumUnknown        MedicationRequestStatusEnum = "unknown"
+)
+
+// medicationRequestStatusTransitions list the legal next status of each medication request status, final status has no next status
+var medicationRequestStatusTransitions = map[MedicationRequestStatusEnum][]MedicationRequestStatusEnum{
+	MedicationRequestStatusEnumDraft:   {MedicationRequestStatusEnumActive, MedicationRequestStatusEnumCancelled, MedicationRequestStatusEnumEnteredInError},
+	MedicationRequestStatusEnumActive:  {MedicationRequestStatusEnumOnHold, MedicationRequestStatusEnumCancelled, MedicationRequestStatusEnumCompleted, MedicationRequestStatusEnumStopped, MedicationRequestStatusEnumEnteredInError},
+	MedicationRequestStatusEnumOnHold:  {MedicationRequestStatusEnumActive, MedicationRequestStatusEnumCancelled, MedicationRequestStatusEnumStopped, MedicationRequestStatusEnumEnteredInError},
+	MedicationRequestStatusEnumUnknown: {MedicationRequestStatusEnumActive, MedicationRequestStatusEnumOnHold, MedicationRequestStatusEnumCancelled, MedicationRequestStatusEnumCompleted, MedicationRequestStatusEnumStopped, MedicationRequestStatusEnumEnteredInError},
+}
+
+// CanTransitionTo check whether medication request status is allowed to move into next status
+func (s MedicationRequestStatusEnum) CanTransitionTo(next MedicationRequestStatusEnum) bool {
+	for _, status := range medicationRequestStatusTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowedDispenses return how many times medication request can be dispensed, the first dispense plus the allowed repeats
+func (m MedicationRequestDB) AllowedDispenses() int {
+	return 1 + m.DispenseRequest.NumberOfRepeatsAllowed
+}
+
+// DispensableAt check whether medication request can be dispensed again at t after it is dispensed the given times
+func (m MedicationRequestDB) DispensableAt(t time.Time, dispensed int) error {
+	if m.Status != MedicationRequestStatusEnumActive {
+		return fmt.Errorf("prescription is %s", m.Status)
+	}
+
+	if err := m.DispenseRequest.ValidityPeriod.Check(t); err != nil {
+		return err
+	}
+
+	if dispensed >= m.AllowedDispenses() {
+		return fmt.Errorf("prescription is already dispensed %d of %d times", dispensed, m.AllowedDispenses())
+	}
+
+	return nil
+}
+
+// Check return error when t is outside validity period, partial date is read in the location of t and end date include its whole period,
+// e.g. end 2024-05-01 is valid until the end of that day
+func (v ValidityPeriod) Check(t time.Time) error {
+	if v.Start != "" {
+		start, _, err := parseFHIRPeriod(v.Start, t.Location())
+		if err != nil {
+			return fmt.Errorf("prescription has invalid validity period start %q", v.Start)
+		}
+
+		if t.Before(start) {
+			return fmt.Errorf("prescription is not valid until %s", v.Start)
+		}
+	}
+
+	if v.End != "" {
+		_, end, err := parseFHIRPeriod(v.End, t.Location())
+		if err != nil {
+			return fmt.Errorf("prescription has invalid validity period end %q", v.End)
+		}
+
+		if !t.Before(end) {
+			return fmt.Errorf("prescription is expired since %s", v.End)
+		}
+	}
+
+	return nil
+}
+
+func (v UpdateMedicationRequestStatusRequest) Validate() error {
+	if err := validation.ValidateStruct(&v,
+		validation.Field(&v.Status, validation.Required, validation.In(MedicationRequestStatusEnumActive, MedicationRequestStatusEnumOnHold, MedicationRequestStatusEnumCancelled, MedicationRequestStatusEnumCompleted, MedicationRequestStatusEnumStopped, MedicationRequestStatusEnumEnteredInError)),
+		validation.Field(&v.StatusReason, validation.Length(0, 255)),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// NewUpdateMedicationRequestStatusRequest read FHIR PATCH of medication request, only status and statusReason can be replaced.
+// statusReason is CodeableConcept, its text or the display of its first coding is kept
+func NewUpdateMedicationRequestStatusRequest(operations []JSONPatchOperation) (*UpdateMedicationRequestStatusRequest, error) {
+	req := UpdateMedicationRequestStatusRequest{}
+
+	for i, operation := range operations {
+		if operation.Op != "replace" && operation.Op != "add" {
+			return nil, NewError(Validation, fmt.Sprintf("operation %d: op %q is not supported, use replace", i, operation.Op))
+		}
+
+		switch operation.Path {
+		case "/status":
+			if err := json.Unmarshal(operation.Value, &req.Status); err != nil {
+				return nil, NewError(Validation, fmt.Sprintf("operation %d: status must be a code", i))
+			}
+		case "/statusReason":
+			var reason struct {
+				Text   string   `json:"text"`
+				Coding []Coding `json:"coding"`
+			}
+			if err := json.Unmarshal(operation.Value, &reason); err != nil {
+				return nil, NewError(Validation, fmt.Sprintf("operation %d: statusReason must be a CodeableConcept", i))
+			}
+
+			req.StatusReason = reason.Text
+			if req.StatusReason == "" && len(reason.Coding) > 0 {
+				req.StatusReason = reason.Coding[0].Display
+			}
+		default:
+			return nil, NewError(Validation, fmt.Sprintf("operation %d: path %q can't be patched, only /status and /statusReason are supported", i, operation.Path))
+		}
+	}
+
+	return &req, nil
+}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMedicationRequestStatusEnumCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		name string
+		from MedicationRequestStatusEnum
+		to   MedicationRequestStatusEnum
+		want bool
+	}{
+		{name: "draft to active", from: MedicationRequestStatusEnumDraft, to: MedicationRequestStatusEnumActive, want: true},
+		{name: "active to on-hold", from: MedicationRequestStatusEnumActive, to: MedicationRequestStatusEnumOnHold, want: true},
+		{name: "active to cancelled", from: MedicationRequestStatusEnumActive, to: MedicationRequestStatusEnumCancelled, want: true},
+		{name: "on-hold to active", from: MedicationRequestStatusEnumOnHold, to: MedicationRequestStatusEnumActive, want: true},
+		{name: "unknown to completed", from: MedicationRequestStatusEnumUnknown, to: MedicationRequestStatusEnumCompleted, want: true},
+		{name: "active to entered-in-error", from: MedicationRequestStatusEnumActive, to: MedicationRequestStatusEnumEnteredInError, want: true},
+		{name: "draft to completed", from: MedicationRequestStatusEnumDraft, to: MedicationRequestStatusEnumCompleted, want: false},
+		{name: "on-hold to completed", from: MedicationRequestStatusEnumOnHold, to: MedicationRequestStatusEnumCompleted, want: false},
+		{name: "cancelled to active", from: MedicationRequestStatusEnumCancelled, to: MedicationRequestStatusEnumActive, want: false},
+		{name: "completed to active", from: MedicationRequestStatusEnumCompleted, to: MedicationRequestStatusEnumActive, want: false},
+		{name: "stopped to on-hold", from: MedicationRequestStatusEnumStopped, to: MedicationRequestStatusEnumOnHold, want: false},
+		{name: "entered-in-error to active", from: MedicationRequestStatusEnumEnteredInError, to: MedicationRequestStatusEnumActive, want: false},
+		{name: "same status", from: MedicationRequestStatusEnumActive, to: MedicationRequestStatusEnumActive, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewUpdateMedicationRequestStatusRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations []JSONPatchOperation
+		want       UpdateMedicationRequestStatusRequest
+		wantErr    bool
+	}{
+		{
+			name:       "replace status",
+			operations: []JSONPatchOperation{{Op: "replace", Path: "/status", Value: json.RawMessage(`"cancelled"`)}},
+			want:       UpdateMedicationRequestStatusRequest{Status: MedicationRequestStatusEnumCancelled},
+		},
+		{
+			name: "status with reason text",
+			operations: []JSONPatchOperation{
+				{Op: "replace", Path: "/status", Value: json.RawMessage(`"stopped"`)},
+				{Op: "add", Path: "/statusReason", Value: json.RawMessage(`{"text":"alergi","coding":[{"display":"Allergy"}]}`)},
+			},
+			want: UpdateMedicationRequestStatusRequest{Status: MedicationRequestStatusEnumStopped, StatusReason: "alergi"},
+		},
+		{
+			name: "reason fallback to coding display",
+			operations: []JSONPatchOperation{
+				{Op: "replace", Path: "/status", Value: json.RawMessage(`"on-hold"`)},
+				{Op: "replace", Path: "/statusReason", Value: json.RawMessage(`{"coding":[{"code":"drughigh","display":"Drug level too high"}]}`)},
+			},
+			want: UpdateMedicationRequestStatusRequest{Status: MedicationRequestStatusEnumOnHold, StatusReason: "Drug level too high"},
+		},
+		{
+			name:       "unsupported op",
+			operations: []JSONPatchOperation{{Op: "remove", Path: "/status"}},
+			wantErr:    true,
+		},
+		{
+			name:       "unsupported path",
+			operations: []JSONPatchOperation{{Op: "replace", Path: "/intent", Value: json.RawMessage(`"order"`)}},
+			wantErr:    true,
+		},
+		{
+			name:       "status is not a code",
+			operations: []JSONPatchOperation{{Op: "replace", Path: "/status", Value: json.RawMessage(`{"code":"active"}`)}},
+			wantErr:    true,
+		},
+		{
+			name:       "reason is not a CodeableConcept",
+			operations: []JSONPatchOperation{{Op: "replace", Path: "/statusReason", Value: json.RawMessage(`"alergi"`)}},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewUpdateMedicationRequestStatusRequest(tt.operations)
+			if tt.wantErr {
+				if KindOf(err) != Validation {
+					t.Fatalf("error = %v, want validation error", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidityPeriodCheck(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+
+	tests := []struct {
+		name    string
+		period  ValidityPeriod
+		at      time.Time
+		wantErr bool
+	}{
+		{name: "no period", period: ValidityPeriod{}, at: time.Date(2024, 5, 1, 12, 0, 0, 0, jakarta)},
+		{name: "inside period", period: ValidityPeriod{Start: "2024-05-01", End: "2024-05-31"}, at: time.Date(2024, 5, 15, 12, 0, 0, 0, jakarta)},
+		{name: "at start", period: ValidityPeriod{Start: "2024-05-01"}, at: time.Date(2024, 5, 1, 0, 0, 0, 0, jakarta)},
+		{name: "before start", period: ValidityPeriod{Start: "2024-05-01"}, at: time.Date(2024, 4, 30, 23, 59, 59, 0, jakarta), wantErr: true},
+		{name: "end date include the whole day", period: ValidityPeriod{End: "2024-05-31"}, at: time.Date(2024, 5, 31, 23, 59, 59, 0, jakarta)},
+		{name: "after end date", period: ValidityPeriod{End: "2024-05-31"}, at: time.Date(2024, 6, 1, 0, 0, 0, 0, jakarta), wantErr: true},
+		{name: "end month include the whole month", period: ValidityPeriod{End: "2024-05"}, at: time.Date(2024, 5, 31, 20, 0, 0, 0, jakarta)},
+		{name: "end year include the whole year", period: ValidityPeriod{End: "2024"}, at: time.Date(2024, 12, 31, 20, 0, 0, 0, jakarta)},
+		{name: "end dateTime is exclusive", period: ValidityPeriod{End: "2024-05-31T10:00:00+07:00"}, at: time.Date(2024, 5, 31, 10, 0, 0, 0, jakarta), wantErr: true},
+		{name: "end dateTime in other timezone", period: ValidityPeriod{End: "2024-05-31T10:00:00Z"}, at: time.Date(2024, 5, 31, 16, 59, 0, 0, jakarta)},
+		{name: "partial date is read in location of t", period: ValidityPeriod{End: "2024-05-31"}, at: time.Date(2024, 5, 31, 17, 30, 0, 0, time.UTC)},
+		{name: "same instant after end in jakarta", period: ValidityPeriod{End: "2024-05-31"}, at: time.Date(2024, 5, 31, 17, 30, 0, 0, time.UTC).In(jakarta), wantErr: true},
+		{name: "invalid start", period: ValidityPeriod{Start: "01-05-2024"}, at: time.Date(2024, 5, 15, 12, 0, 0, 0, jakarta), wantErr: true},
+		{name: "invalid end", period: ValidityPeriod{End: "tomorrow"}, at: time.Date(2024, 5, 15, 12, 0, 0, 0, jakarta), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.period.Check(tt.at)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check(%s) error = %v, wantErr %v", tt.at, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package model
+
+type (
+	// MidtransSnapRequest is the body sent to midtrans snap create transaction endpoint
+	MidtransSnapRequest struct {
+		TransactionDetails MidtransTransactionDetails `json:"transaction_details"`
+		ItemDetails        []MidtransItemDetail       `json:"item_details,omitempty"`
+		CustomerDetails    *MidtransCustomerDetails   `json:"customer_details,omitempty"`
+		EnabledPayments    []string                   `json:"enabled_payments,omitempty"`
+		Callbacks          *MidtransCallbacks         `json:"callbacks,omitempty"`
+		Expiry             *MidtransExpiry            `json:"expiry,omitempty"`
+	}
+
+	MidtransTransactionDetails struct {
+		OrderID     string `json:"order_id"`
+		GrossAmount int    `json:"gross_amount"`
+	}
+
+	MidtransItemDetail struct {
+		ID       string `json:"id"`
+		Price    int    `json:"price"`
+		Quantity int    `json:"quantity"`
+		Name     string `json:"name"`
+	}
+
+	MidtransCustomerDetails struct {
+		FirstName string `json:"first_name"`
+		Email     string `json:"email,omitempty"`
+		Phone     string `json:"phone,omitempty"`
+	}
+
+	MidtransCallbacks struct {
+		Finish string `json:"finish"`
+	}
+
+	MidtransExpiry struct {
+		Unit     string `json:"unit"`
+		Duration int    `json:"duration"`
+	}
+
+	// MidtransSnapResponse is returned by midtrans snap create transaction endpoint
+	MidtransSnapResponse struct {
+		Token       string `json:"token"`
+		RedirectURL string `json:"redirect_url"`
+	}
+
+	// MidtransTransactionStatus is returned by midtrans status, expire and refund endpoints and sent as midtrans notification
+	MidtransTransactionStatus struct {
+		StatusCode        string             `json:"status_code"`
+		StatusMessage     string             `json:"status_message"`
+		TransactionID     string             `json:"transaction_id"`
+		OrderID           string             `json:"order_id"`
+		GrossAmount       string             `json:"gross_amount"`
+		PaymentType       string             `json:"payment_type"`
+		TransactionStatus string             `json:"transaction_status"`
+		FraudStatus       string             `json:"fraud_status"`
+		TransactionTime   string             `json:"transaction_time"`
+		SettlementTime    string             `json:"settlement_time"`
+		ExpiryTime        string             `json:"expiry_time"`
+		SignatureKey      string             `json:"signature_key"`
+		VANumbers         []MidtransVANumber `json:"va_numbers"`
+		PermataVANumber   string             `json:"permata_va_number"`
+		RefundKey         string             `json:"refund_key"`
+	}
+
+	MidtransVANumber struct {
+		Bank     string `json:"bank"`
+		VANumber string `json:"va_number"`
+	}
+
+	// MidtransRefundRequest is the body sent to midtrans refund endpoint
+	MidtransRefundRequest struct {
+		RefundKey string `json:"refund_key"`
+		Amount    int    `json:"amount"`
+		Reason    string `json:"reason"`
+	}
+
+	// MidtransErrorResponse is the error envelope returned by midtrans snap on non-2xx responses
+	MidtransErrorResponse struct {
+		ErrorMessages []string `json:"error_messages"`
+	}
+)
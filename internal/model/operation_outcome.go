@@ -0,0 +1,95 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type (
+	// OperationOutcome is FHIR R4 OperationOutcome resource, it is returned when FHIR resource in request is invalid
+	OperationOutcome struct {
+		ResourceType string                  `json:"resourceType"`
+		Issue        []OperationOutcomeIssue `json:"issue"`
+	}
+
+	OperationOutcomeIssue struct {
+		Severity    string   `json:"severity"`
+		Code        string   `json:"code"`
+		Diagnostics string   `json:"diagnostics"`
+		Expression  []string `json:"expression"`
+	}
+)
+
+// outcomeIssueCodes map ozzo validation error code into FHIR issue type, unlisted code is reported as value
+var outcomeIssueCodes = map[string]string{
+	validation.ErrRequired.Code():         "required",
+	validation.ErrNilOrNotEmpty.Code():    "required",
+	validation.ErrInInvalid.Code():        "code-invalid",
+	validation.ErrLengthTooShort.Code():   "structure",
+	validation.ErrLengthTooLong.Code():    "structure",
+	validation.ErrLengthOutOfRange.Code(): "structure",
+}
+
+func (o *OperationOutcome) Error() string {
+	if len(o.Issue) == 0 {
+		return "operation outcome has no issue"
+	}
+
+	first := o.Issue[0]
+	msg := fmt.Sprintf("%s: %s", strings.Join(first.Expression, ", "), first.Diagnostics)
+	if len(o.Issue) > 1 {
+		msg = fmt.Sprintf("%s (and %d more issues)", msg, len(o.Issue)-1)
+	}
+
+	return msg
+}
+
+// outcomeBuilder collect issues of FHIR resource validation, every issue keep the FHIRPath of the invalid element
+type outcomeBuilder struct {
+	issues []OperationOutcomeIssue
+}
+
+// check validate value of element at path with ozzo rules and record the first failing rule as issue.
+// It return false when value is invalid, so caller can skip checks that depend on it
+func (b *outcomeBuilder) check(path string, value interface{}, rules ...validation.Rule) bool {
+	err := validation.Validate(value, rules...)
+	if err == nil {
+		return true
+	}
+
+	code := "value"
+	var ve validation.Error
+	if errors.As(err, &ve) {
+		if c, ok := outcomeIssueCodes[ve.Code()]; ok {
+			code = c
+		}
+	}
+
+	b.add(path, code, err.Error())
+
+	return false
+}
+
+func (b *outcomeBuilder) add(path, code, diagnostics string) {
+	b.issues = append(b.issues, OperationOutcomeIssue{
+		Severity:    "error",
+		Code:        code,
+		Diagnostics: diagnostics,
+		Expression:  []string{path},
+	})
+}
+
+// err return OperationOutcome holding every recorded issue, nil when there is none
+func (b *outcomeBuilder) err() error {
+	if len(b.issues) == 0 {
+		return nil
+	}
+
+	return &OperationOutcome{
+		ResourceType: "OperationOutcome",
+		Issue:        b.issues,
+	}
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type (
+	OutboxStatusEnum    string
+	OutboxEventTypeEnum string
+
+	// OutboxMessage is side effect of a state change, it is written in the same database transaction as the change
+	// and dispatched to partner by outbox dispatcher
+	OutboxMessage struct {
+		ID            int                 `db:"id" json:"id"`
+		EventType     OutboxEventTypeEnum `db:"event_type" json:"event_type"`
+		AggregateID   string              `db:"aggregate_id" json:"aggregate_id"`
+		Payload       json.RawMessage     `db:"payload" json:"payload"`
+		Status        OutboxStatusEnum    `db:"status" json:"status"`
+		Attempts      int                 `db:"attempts" json:"attempts"`
+		NextAttemptAt time.Time           `db:"next_attempt_at" json:"next_attempt_at"`
+		LastError     *string             `db:"last_error" json:"last_error"`
+		DispatchedAt  *time.Time          `db:"dispatched_at" json:"dispatched_at"`
+		CreatedAt     time.Time           `db:"created_at" json:"created_at"`
+		UpdatedAt     *time.Time          `db:"updated_at" json:"updated_at"`
+	}
+
+	// WhatsappMessagePayload is payload of whatsapp message outbox event
+	WhatsappMessagePayload struct {
+		PatientName  string       `json:"patient_name"`
+		PatientID    string       `json:"patient_id"`
+		Destination  string       `json:"destination"`
+		TemplateName TemplateName `json:"template_name"`
+	}
+
+	// DispenseOrderPayload is payload of dispense order outbox event, paid transaction is pushed to kimia farma
+	DispenseOrderPayload struct {
+		TransactionID int `json:"transaction_id"`
+	}
+
+	// ShipmentBookingPayload is payload of shipment booking outbox event, shipped transaction is booked on courier
+	ShipmentBookingPayload struct {
+		TransactionID int `json:"transaction_id"`
+	}
+)
+
+const (
+	OutboxStatusEnumPending    OutboxStatusEnum = "PENDING"
+	OutboxStatusEnumDispatched OutboxStatusEnum = "DISPATCHED"
+	// OutboxStatusEnumDead is message that is rejected or run out of attempts, it is kept for manual handling
+	OutboxStatusEnumDead OutboxStatusEnum = "DEAD"
+)
+
+const (
+	OutboxEventTypeEnumWhatsappMessage OutboxEventTypeEnum = "WHATSAPP_MESSAGE"
+	OutboxEventTypeEnumDispenseOrder   OutboxEventTypeEnum = "DISPENSE_ORDER"
+	OutboxEventTypeEnumShipmentBooking OutboxEventTypeEnum = "SHIPMENT_BOOKING"
+)
+
+// NewOutboxMessage return pending outbox message with payload encoded as json, it is dispatched right away
+func NewOutboxMessage(eventType OutboxEventTypeEnum, aggregateID string, payload interface{}) (*OutboxMessage, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxMessage{
+		EventType:     eventType,
+		AggregateID:   aggregateID,
+		Payload:       b,
+		Status:        OutboxStatusEnumPending,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
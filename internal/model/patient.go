@@ -8,6 +8,7 @@ type (
 		RefID       string    `db:"ref_id" json:"ref_id"`
 		Name        string    `db:"name" json:"name"`
 		PhoneNumber string    `db:"phone_number" json:"phone_number"`
+		Email       *string   `db:"email" json:"email"`
 		CreatedAt   time.Time `db:"created_at" json:"created_at"`
 	}
 )
@@ -4,10 +4,12 @@ import "time"
 
 type (
 	PaymentStatusEnum string
+	PaymentMethodEnum string
 
 	GeneratePaymentInfoRequest struct {
 		SelectedMedications []SelectedMedication `json:"selected_medications"`
 		PatientID           string               `json:"patient_id"`
+		PatientAddressID    int                  `json:"patient_address_id"`
 	}
 
 	SelectedMedication struct {
@@ -18,36 +20,91 @@ type (
 		ID    int    `json:"id"`
 		Name  string `json:"name"`
 		Price int    `json:"price"`
+		// MedicationRequestID is medication request redeemed by the item, it is chosen on server side
+		MedicationRequestID *int `json:"-"`
 	}
 
 	PaymentInfo struct {
-		PatientAddress *[]PatientAddress `json:"patient_address"`
-		Items          []Item            `json:"items"`
-		ShippingCost   int               `json:"shipping_cost"`
-		TotalPrice     int               `json:"total_price"`
+		PatientAddress   *[]PatientAddress `json:"patient_address"`
+		PatientAddressID int               `json:"patient_address_id"`
+		Items            []Item            `json:"items"`
+		ShippingCost     int               `json:"shipping_cost"`
+		TotalPrice       int               `json:"total_price"`
+		QuoteID          int               `json:"quote_id"`
+		QuoteExpiresAt   time.Time         `json:"quote_expires_at"`
 	}
 
 	CreatePaymentRequest struct {
-		TransactionID int `db:"transaction_id" json:"transaction_id"`
-		FinalPrice    int `db:"final_price" json:"final_price"`
+		TransactionID int                `db:"transaction_id" json:"transaction_id"`
+		FinalPrice    int                `db:"final_price" json:"final_price"`
+		Method        PaymentMethodEnum  `db:"method" json:"method"`
+		ChannelCode   string             `db:"channel_code" json:"channel_code"`
+		Gateway       PaymentGatewayEnum `db:"gateway" json:"gateway"`
 	}
 
 	Payment struct {
-		ID            int               `db:"id" json:"id"`
-		TransactionID int               `db:"transaction_id" json:"transaction_id"`
-		PartnerID     string            `db:"partner_id" json:"partner_id"`
-		CompletedAt   *time.Time        `db:"completed_at" json:"completed_at"`
-		Status        PaymentStatusEnum `db:"status" json:"status"`
-		FinalPrice    int               `db:"final_price" json:"final_price"`
-		CreatedAt     time.Time         `db:"created_at" json:"created_at"`
-		UpdatedAt     *time.Time        `db:"updated_at" json:"updated_at"`
+		ID            int                `db:"id" json:"id"`
+		TransactionID int                `db:"transaction_id" json:"transaction_id"`
+		PartnerID     string             `db:"partner_id" json:"partner_id"`
+		CompletedAt   *time.Time         `db:"completed_at" json:"completed_at"`
+		Status        PaymentStatusEnum  `db:"status" json:"status"`
+		FinalPrice    int                `db:"final_price" json:"final_price"`
+		Method        PaymentMethodEnum  `db:"method" json:"method"`
+		ChannelCode   *string            `db:"channel_code" json:"channel_code"`
+		Gateway       PaymentGatewayEnum `db:"gateway" json:"gateway"`
+		ExpiresAt     *time.Time         `db:"expires_at" json:"expires_at"`
+		CreatedAt     time.Time          `db:"created_at" json:"created_at"`
+		UpdatedAt     *time.Time         `db:"updated_at" json:"updated_at"`
 	}
 )
 
 const (
-	PaymentStatusEnumPending PaymentStatusEnum = "PENDING"
-	PaymentStatusEnumProcess PaymentStatusEnum = "PROCESS"
-	PaymentStatusEnumSuccess PaymentStatusEnum = "SUCCESS"
-	PaymentStatusEnumFailed  PaymentStatusEnum = "FAILED"
-	PaymentStatusEnumExpired PaymentStatusEnum = "EXPIRED"
+	PaymentStatusEnumPending   PaymentStatusEnum = "PENDING"
+	PaymentStatusEnumProcess   PaymentStatusEnum = "PROCESS"
+	PaymentStatusEnumSuccess   PaymentStatusEnum = "SUCCESS"
+	PaymentStatusEnumFailed    PaymentStatusEnum = "FAILED"
+	PaymentStatusEnumExpired   PaymentStatusEnum = "EXPIRED"
+	PaymentStatusEnumCancelled PaymentStatusEnum = "CANCELLED"
+	// PaymentStatusEnumPartiallyRefunded is payment which some of the amount is refunded
+	PaymentStatusEnumPartiallyRefunded PaymentStatusEnum = "PARTIALLY_REFUNDED"
+	PaymentStatusEnumRefunded          PaymentStatusEnum = "REFUNDED"
 )
+
+const (
+	// PaymentMethodEnumInvoice let patient choose the payment method on xendit invoice page
+	PaymentMethodEnumInvoice        PaymentMethodEnum = "INVOICE"
+	PaymentMethodEnumQRIS           PaymentMethodEnum = "QRIS"
+	PaymentMethodEnumVirtualAccount PaymentMethodEnum = "VIRTUAL_ACCOUNT"
+	PaymentMethodEnumEwallet        PaymentMethodEnum = "EWALLET"
+)
+
+var (
+	// VirtualAccountChannelCodes list supported virtual account banks
+	VirtualAccountChannelCodes = []interface{}{"BCA", "BNI", "BRI", "MANDIRI", "PERMATA", "BSI", "CIMB", "BJB"}
+	// EwalletChannelCodes list supported e-wallets
+	EwalletChannelCodes = []interface{}{"OVO", "DANA", "SHOPEEPAY", "LINKAJA", "ASTRAPAY"}
+)
+
+// IsDirectCharge check whether payment is charged directly with the chosen method instead of through invoice
+func (m PaymentMethodEnum) IsDirectCharge() bool {
+	return m != "" && m != PaymentMethodEnumInvoice
+}
+
+// paymentStatusTransitions list the legal next status of each payment status, final status has no next status
+var paymentStatusTransitions = map[PaymentStatusEnum][]PaymentStatusEnum{
+	PaymentStatusEnumPending:           {PaymentStatusEnumProcess, PaymentStatusEnumSuccess, PaymentStatusEnumFailed, PaymentStatusEnumExpired},
+	PaymentStatusEnumProcess:           {PaymentStatusEnumSuccess, PaymentStatusEnumFailed, PaymentStatusEnumExpired, PaymentStatusEnumCancelled},
+	PaymentStatusEnumSuccess:           {PaymentStatusEnumPartiallyRefunded, PaymentStatusEnumRefunded},
+	PaymentStatusEnumPartiallyRefunded: {PaymentStatusEnumRefunded},
+}
+
+// CanTransitionTo check whether payment status is allowed to move into next status
+func (s PaymentStatusEnum) CanTransitionTo(next PaymentStatusEnum) bool {
+	for _, status := range paymentStatusTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type (
+	PaymentEventResultEnum string
+
+	// PaymentEvent is a log of every payment callback received from payment gateway
+	PaymentEvent struct {
+		ID          int                     `db:"id" json:"id"`
+		CallbackID  string                  `db:"callback_id" json:"callback_id"`
+		InvoiceID   string                  `db:"invoice_id" json:"invoice_id"`
+		ExternalID  string                  `db:"external_id" json:"external_id"`
+		Status      string                  `db:"status" json:"status"`
+		RawPayload  json.RawMessage         `db:"raw_payload" json:"raw_payload"`
+		Result      *PaymentEventResultEnum `db:"result" json:"result"`
+		Note        *string                 `db:"note" json:"note"`
+		ReceivedAt  time.Time               `db:"received_at" json:"received_at"`
+		ProcessedAt *time.Time              `db:"processed_at" json:"processed_at"`
+	}
+)
+
+const (
+	PaymentEventResultEnumApplied PaymentEventResultEnum = "APPLIED"
+	PaymentEventResultEnumIgnored PaymentEventResultEnum = "IGNORED"
+)
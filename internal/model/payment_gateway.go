@@ -0,0 +1,106 @@
+package model
+
+import "time"
+
+type (
+	PaymentGatewayEnum string
+	ChargeStatusEnum   string
+
+	// ChargeRequest is a provider neutral request to charge patient, either through hosted checkout page or directly
+	// with the chosen payment method
+	ChargeRequest struct {
+		// ReferenceID is our payment id, it is sent to payment gateway as external or order id
+		ReferenceID        string
+		Amount             int
+		Description        string
+		Method             PaymentMethodEnum
+		ChannelCode        string
+		MobileNumber       string
+		Customer           ChargeCustomer
+		Items              []ChargeItem
+		ShippingCost       int
+		Duration           time.Duration
+		SuccessRedirectURL string
+		FailureRedirectURL string
+	}
+
+	ChargeCustomer struct {
+		ID          string
+		Name        string
+		Email       string
+		PhoneNumber string
+	}
+
+	ChargeItem struct {
+		ReferenceID string
+		Name        string
+		Price       int
+		Quantity    int
+	}
+
+	// Charge is a payment on payment gateway together with the instruction to pay it, only the instruction of chosen
+	// payment method is filled
+	Charge struct {
+		// Gateway is the payment gateway that report the charge
+		Gateway     PaymentGatewayEnum
+		PartnerID   string
+		ReferenceID string
+		Status      ChargeStatusEnum
+		// PartnerStatus is the raw status reported by payment gateway
+		PartnerStatus string
+		// Amount is the charged amount reported by payment gateway, charge without amount is never applied to a payment
+		Amount               int
+		CheckoutURL          string
+		VirtualAccountNumber string
+		QRString             string
+		DeeplinkURL          string
+		PaidAt               *time.Time
+		ExpiresAt            *time.Time
+		UpdatedAt            time.Time
+	}
+
+	// ChargeNotification is a charge status reported by payment gateway webhook
+	ChargeNotification struct {
+		// CallbackID identify a callback, retries of the same callback share the same id
+		CallbackID string
+		Charge     Charge
+	}
+
+	// RefundChargeRequest is a provider neutral request to refund part or all of a paid charge
+	RefundChargeRequest struct {
+		// ReferenceID is our refund id, it is also used as idempotency key
+		ReferenceID string
+		Amount      int
+		Reason      string
+		Metadata    map[string]interface{}
+	}
+
+	// ChargeRefund is a refund on payment gateway, payment gateway that refund synchronously return the final status right away
+	ChargeRefund struct {
+		PartnerID       string
+		ReferenceID     string
+		ChargePartnerID string
+		Status          RefundStatusEnum
+		PartnerStatus   string
+		FailureCode     string
+	}
+
+	// RefundNotification is a refund status reported by payment gateway webhook
+	RefundNotification struct {
+		CallbackID string
+		Refund     ChargeRefund
+	}
+)
+
+const (
+	PaymentGatewayEnumXendit   PaymentGatewayEnum = "XENDIT"
+	PaymentGatewayEnumMidtrans PaymentGatewayEnum = "MIDTRANS"
+)
+
+const (
+	// ChargeStatusEnumPending is charge that is still waiting for payment
+	ChargeStatusEnumPending ChargeStatusEnum = "PENDING"
+	ChargeStatusEnumPaid    ChargeStatusEnum = "PAID"
+	ChargeStatusEnumExpired ChargeStatusEnum = "EXPIRED"
+	ChargeStatusEnumFailed  ChargeStatusEnum = "FAILED"
+)
@@ -0,0 +1,34 @@
+package model
+
+import "testing"
+
+func TestPaymentStatusEnumCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		name string
+		from PaymentStatusEnum
+		to   PaymentStatusEnum
+		want bool
+	}{
+		{name: "pending to process", from: PaymentStatusEnumPending, to: PaymentStatusEnumProcess, want: true},
+		{name: "pending to success", from: PaymentStatusEnumPending, to: PaymentStatusEnumSuccess, want: true},
+		{name: "process to success", from: PaymentStatusEnumProcess, to: PaymentStatusEnumSuccess, want: true},
+		{name: "process to cancelled", from: PaymentStatusEnumProcess, to: PaymentStatusEnumCancelled, want: true},
+		{name: "success to partially refunded", from: PaymentStatusEnumSuccess, to: PaymentStatusEnumPartiallyRefunded, want: true},
+		{name: "partially refunded to refunded", from: PaymentStatusEnumPartiallyRefunded, to: PaymentStatusEnumRefunded, want: true},
+		{name: "pending to cancelled", from: PaymentStatusEnumPending, to: PaymentStatusEnumCancelled, want: false},
+		{name: "success back to process", from: PaymentStatusEnumSuccess, to: PaymentStatusEnumProcess, want: false},
+		{name: "success to expired", from: PaymentStatusEnumSuccess, to: PaymentStatusEnumExpired, want: false},
+		{name: "expired to success", from: PaymentStatusEnumExpired, to: PaymentStatusEnumSuccess, want: false},
+		{name: "failed to success", from: PaymentStatusEnumFailed, to: PaymentStatusEnumSuccess, want: false},
+		{name: "refunded to partially refunded", from: PaymentStatusEnumRefunded, to: PaymentStatusEnumPartiallyRefunded, want: false},
+		{name: "same status", from: PaymentStatusEnumProcess, to: PaymentStatusEnumProcess, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,13 @@
 package model
 
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
 type (
 	PrescriptionRequest struct {
 		Medication        Medication        `json:"medication"`
@@ -7,11 +15,215 @@ type (
 	}
 
 	Prescription struct {
-		ID          int    `db:"id" json:"id"`
-		Display     string `db:"display" json:"display"`
-		Code        string `db:"code" json:"code"`
-		PatientID   string `db:"patient_id" json:"patient_id"`
-		Price       int    `json:"price"`
-		IsAvailable bool   `json:"isAvailable"`
+		ID                int    `db:"id" json:"id"`
+		Display           string `db:"display" json:"display"`
+		Code              string `db:"code" json:"code"`
+		PatientID         string `db:"patient_id" json:"patient_id"`
+		Price             int    `json:"price"`
+		IsAvailable       bool   `json:"isAvailable"`
+		AvailabilityError string `json:"availabilityError,omitempty"`
 	}
+
+	// PrescriptionUpsertResultEnum is what happened to stored prescription item when it is posted
+	PrescriptionUpsertResultEnum string
+)
+
+const (
+	PrescriptionUpsertResultEnumCreated   PrescriptionUpsertResultEnum = "CREATED"
+	PrescriptionUpsertResultEnumUpdated   PrescriptionUpsertResultEnum = "UPDATED"
+	PrescriptionUpsertResultEnumUnchanged PrescriptionUpsertResultEnum = "UNCHANGED"
+)
+
+const (
+	// KFACodeSystem is code system of kamus farmasi dan alat kesehatan, medication code is used to check kimia farma availability
+	KFACodeSystem = "http://sys-ids.kemkes.go.id/kfa"
+	// MedicationFormCodeSystem is code system of medication form on SATUSEHAT
+	MedicationFormCodeSystem = "http://terminology.kemkes.go.id/CodeSystem/medication-form"
+	// MedicationRequestCategoryCodeSystem is code system of medication request category
+	MedicationRequestCategoryCodeSystem = "http://terminology.hl7.org/CodeSystem/medicationrequest-category"
+	// PrescriptionIdentifierSystem is prefix of prescription identifier system, followed by organization id
+	PrescriptionIdentifierSystem = "http://sys-ids.kemkes.go.id/prescription/"
+	// PrescriptionItemIdentifierSystem is prefix of prescription item identifier system, followed by organization id
+	PrescriptionItemIdentifierSystem = "http://sys-ids.kemkes.go.id/prescription-item/"
 )
+
+var (
+	medicationStatuses        = []interface{}{"active", "inactive", "entered-in-error"}
+	medicationRequestStatuses = []interface{}{"active", "on-hold", "cancelled", "completed", "entered-in-error", "stopped", "draft", "unknown"}
+	medicationRequestIntents  = []interface{}{"proposal", "plan", "order", "original-order", "reflex-order", "filler-order", "instance-order", "option"}
+	medicationRequestPriority = []interface{}{"routine", "urgent", "asap", "stat"}
+	timingPeriodUnits         = []interface{}{"s", "min", "h", "d", "wk", "mo", "a"}
+
+	// fhirDateTimeLayouts are the precisions allowed by FHIR dateTime
+	fhirDateTimeLayouts = []string{"2006", "2006-01", "2006-01-02", time.RFC3339}
+)
+
+// Validate check FHIR elements of prescription that are stored or used later, the returned error is *OperationOutcome
+func (v PrescriptionRequest) Validate() error {
+	b := &outcomeBuilder{}
+
+	v.Medication.validate(b, "Medication")
+	v.MedicationRequest.validate(b, "MedicationRequest")
+
+	if v.Medication.ID != "" && v.MedicationRequest.MedicationReference.Reference != "" {
+		b.check("MedicationRequest.medicationReference.reference", v.MedicationRequest.MedicationReference.Reference,
+			validation.In("Medication/"+v.Medication.ID).Error("must reference the medication in the same request"))
+	}
+
+	return b.err()
+}
+
+func (v Medication) validate(b *outcomeBuilder, path string) {
+	b.check(path+".resourceType", v.ResourceType, validation.Required, validation.In("Medication"))
+	b.check(path+".id", v.ID, validation.Required)
+	b.check(path+".status", v.Status, validation.Required, validation.In(medicationStatuses...))
+
+	if b.check(path+".identifier", v.Identifier, validation.Required) {
+		b.check(path+".identifier[0].value", v.Identifier[0].Value, validation.Required)
+	}
+
+	validateCoding(b, path+".code.coding", v.Code.Coding, KFACodeSystem, true)
+	validateCoding(b, path+".form.coding", v.Form.Coding, MedicationFormCodeSystem, true)
+
+	if b.check(path+".ingredient", v.Ingredient, validation.Required) {
+		for i, ingredient := range v.Ingredient {
+			ingredientPath := fmt.Sprintf("%s.ingredient[%d]", path, i)
+
+			validateCoding(b, ingredientPath+".itemCodeableConcept.coding", ingredient.ItemCodeableConcept.Coding, KFACodeSystem, true)
+			b.check(ingredientPath+".strength.numerator.value", ingredient.Strength.Numerator.Value, validation.Min(0.0))
+			b.check(ingredientPath+".strength.denominator.value", ingredient.Strength.Denominator.Value, validation.Min(0.0))
+		}
+	}
+}
+
+func (v MedicationRequest) validate(b *outcomeBuilder, path string) {
+	b.check(path+".resourceType", v.ResourceType, validation.Required, validation.In("MedicationRequest"))
+	b.check(path+".id", v.ID, validation.Required)
+	b.check(path+".status", v.Status, validation.Required, validation.In(medicationRequestStatuses...))
+	b.check(path+".intent", v.Intent, validation.Required, validation.In(medicationRequestIntents...))
+	b.check(path+".priority", v.Priority, validation.In(medicationRequestPriority...))
+	b.check(path+".authoredOn", v.AuthoredOn, validation.By(fhirDateTime))
+
+	// prescription id is taken from the first identifier and prescription item id from the second one
+	if b.check(path+".identifier", v.Identifier, validation.Required, validation.Length(2, 0)) {
+		b.check(path+".identifier[0].system", v.Identifier[0].System, validation.Required, validation.Match(systemPrefix(PrescriptionIdentifierSystem)).Error("must start with "+PrescriptionIdentifierSystem))
+		b.check(path+".identifier[0].value", v.Identifier[0].Value, validation.Required)
+		b.check(path+".identifier[1].system", v.Identifier[1].System, validation.Required, validation.Match(systemPrefix(PrescriptionItemIdentifierSystem)).Error("must start with "+PrescriptionItemIdentifierSystem))
+		b.check(path+".identifier[1].value", v.Identifier[1].Value, validation.Required)
+	}
+
+	if b.check(path+".category", v.Category, validation.Required) {
+		validateCoding(b, path+".category[0].coding", v.Category[0].Coding, MedicationRequestCategoryCodeSystem, false)
+	}
+
+	if b.check(path+".reasonCode", v.ReasonCode, validation.Required) {
+		validateCoding(b, path+".reasonCode[0].coding", v.ReasonCode[0].Coding, "", true)
+	}
+
+	validateCoding(b, path+".courseOfTherapyType.coding", v.CourseOfTherapyType.Coding, "", false)
+
+	b.check(path+".medicationReference.reference", v.MedicationReference.Reference, validation.Required)
+	b.check(path+".subject.reference", v.Subject.Reference, validation.Required, validation.Match(systemPrefix("Patient/")).Error("must be a Patient reference"))
+	b.check(path+".subject.display", v.Subject.Display, validation.Required)
+	b.check(path+".encounter.reference", v.Encounter.Reference, validation.Required, validation.Match(systemPrefix("Encounter/")).Error("must be an Encounter reference"))
+	b.check(path+".requester.reference", v.Requester.Reference, validation.Required, validation.Match(systemPrefix("Practitioner/")).Error("must be a Practitioner reference"))
+
+	if b.check(path+".dosageInstruction", v.DosageInstruction, validation.Required) {
+		for i, dosage := range v.DosageInstruction {
+			dosagePath := fmt.Sprintf("%s.dosageInstruction[%d]", path, i)
+
+			b.check(dosagePath+".timing.repeat.frequency", dosage.Timing.Repeat.Frequency, validation.Min(0))
+			b.check(dosagePath+".timing.repeat.periodUnit", dosage.Timing.Repeat.PeriodUnit, validation.In(timingPeriodUnits...))
+
+			for j, doseAndRate := range dosage.DoseAndRate {
+				b.check(fmt.Sprintf("%s.doseAndRate[%d].doseQuantity.value", dosagePath, j), doseAndRate.DoseQuantity.Value, validation.Min(0.0))
+			}
+		}
+	}
+
+	dispensePath := path + ".dispenseRequest"
+	b.check(dispensePath+".quantity.value", v.DispenseRequest.Quantity.Value, validation.Min(0.0))
+	b.check(dispensePath+".numberOfRepeatsAllowed", v.DispenseRequest.NumberOfRepeatsAllowed, validation.Min(0))
+
+	validStart := b.check(dispensePath+".validityPeriod.start", v.DispenseRequest.ValidityPeriod.Start, validation.By(fhirDateTime))
+	validEnd := b.check(dispensePath+".validityPeriod.end", v.DispenseRequest.ValidityPeriod.End, validation.By(fhirDateTime))
+	if validStart && validEnd && v.DispenseRequest.ValidityPeriod.Start != "" && v.DispenseRequest.ValidityPeriod.End != "" {
+		start, _ := parseFHIRDateTime(v.DispenseRequest.ValidityPeriod.Start)
+		end, _ := parseFHIRDateTime(v.DispenseRequest.ValidityPeriod.End)
+		if end.Before(start) {
+			b.add(dispensePath+".validityPeriod.end", "invariant", "must not be before start")
+		}
+	}
+}
+
+// validateCoding check the first coding that is stored, system is checked only when it is not empty
+func validateCoding(b *outcomeBuilder, path string, coding []Coding, system string, requireDisplay bool) {
+	if !b.check(path, coding, validation.Required) {
+		return
+	}
+
+	if system != "" {
+		b.check(path+"[0].system", coding[0].System, validation.Required, validation.In(system).Error("must be "+system))
+	}
+
+	b.check(path+"[0].code", coding[0].Code, validation.Required)
+
+	if requireDisplay {
+		b.check(path+"[0].display", coding[0].Display, validation.Required)
+	}
+}
+
+// systemPrefix return pattern matching value that start with prefix
+func systemPrefix(prefix string) *regexp.Regexp {
+	return regexp.MustCompile("^" + regexp.QuoteMeta(prefix))
+}
+
+// fhirDateTime is ozzo rule of FHIR dateTime, empty value is valid
+func fhirDateTime(value interface{}) error {
+	s, _ := value.(string)
+	if s == "" {
+		return nil
+	}
+
+	if _, err := parseFHIRDateTime(s); err != nil {
+		return validation.NewError("validation_fhir_date_time", "must be a valid FHIR dateTime")
+	}
+
+	return nil
+}
+
+// parseFHIRDateTime parse FHIR dateTime of any allowed precision, partial date start at the beginning of its period
+func parseFHIRDateTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range fhirDateTimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, err
+}
+
+// parseFHIRPeriod return the period covered by FHIR dateTime as [start, end), partial date without timezone is read in loc
+func parseFHIRPeriod(s string, loc *time.Location) (time.Time, time.Time, error) {
+	for _, layout := range fhirDateTimeLayouts {
+		t, err := time.ParseInLocation(layout, s, loc)
+		if err != nil {
+			continue
+		}
+
+		switch layout {
+		case "2006":
+			return t, t.AddDate(1, 0, 0), nil
+		case "2006-01":
+			return t, t.AddDate(0, 1, 0), nil
+		case "2006-01-02":
+			return t, t.AddDate(0, 0, 1), nil
+		default:
+			return t, t, nil
+		}
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("%q is not a valid FHIR dateTime", s)
+}
@@ -0,0 +1,237 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+// validPrescriptionRequest return prescription that pass validation, each test case break one element of it
+func validPrescriptionRequest() PrescriptionRequest {
+	medication := Medication{
+		ResourceType: "Medication",
+		ID:           "med-1",
+		Status:       "active",
+		Identifier:   []Identifier{{System: "http://sys-ids.kemkes.go.id/medication/100", Value: "123456789"}},
+		Ingredient: []Ingredient{{
+			IsActive: true,
+			ItemCodeableConcept: ItemCodeableConcept{
+				Coding: []Coding{{System: KFACodeSystem, Code: "91000330", Display: "Rifampin"}},
+			},
+			Strength: Strength{
+				Numerator:   Quantity{Value: 150},
+				Denominator: Quantity{Value: 1},
+			},
+		}},
+	}
+	medication.Code.Coding = []Coding{{System: KFACodeSystem, Code: "93001019", Display: "Rifampicin 150 mg"}}
+	medication.Form.Coding = []Coding{{System: MedicationFormCodeSystem, Code: "BS066", Display: "Tablet"}}
+
+	return PrescriptionRequest{
+		Medication: medication,
+		MedicationRequest: MedicationRequest{
+			ResourceType: "MedicationRequest",
+			ID:           "mr-1",
+			Status:       "active",
+			Intent:       "order",
+			Priority:     "routine",
+			AuthoredOn:   "2024-05-01T08:00:00+07:00",
+			Identifier: []Identifier{
+				{System: PrescriptionIdentifierSystem + "100", Value: "123456788"},
+				{System: PrescriptionItemIdentifierSystem + "100", Value: "123456788-1"},
+			},
+			Category:            []Category{{Coding: []Coding{{System: MedicationRequestCategoryCodeSystem, Code: "outpatient"}}}},
+			ReasonCode:          []ReasonCode{{Coding: []Coding{{Code: "A15.0", Display: "Tuberculosis of lung"}}}},
+			CourseOfTherapyType: CourseOfTherapyType{Coding: []Coding{{Code: "continuous"}}},
+			MedicationReference: MedicationReference{Reference: "Medication/med-1"},
+			Subject:             User{Reference: "Patient/P001", Display: "Budi"},
+			Encounter:           Encounter{Reference: "Encounter/E001"},
+			Requester:           User{Reference: "Practitioner/N001"},
+			DosageInstruction: []DosageInstruction{{
+				Timing: Timing{Repeat: TimingRepeat{Frequency: 1, Period: 1, PeriodUnit: "d"}},
+			}},
+			DispenseRequest: DispenseRequest{
+				NumberOfRepeatsAllowed: 1,
+				Quantity:               Quantity{Value: 30},
+				ValidityPeriod:         ValidityPeriod{Start: "2024-05-01", End: "2024-07-31"},
+			},
+		},
+	}
+}
+
+func TestPrescriptionRequestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		modify     func(req *PrescriptionRequest)
+		wantIssues []string
+	}{
+		{
+			name:   "valid",
+			modify: func(req *PrescriptionRequest) {},
+		},
+		{
+			name: "missing medication id",
+			modify: func(req *PrescriptionRequest) {
+				req.Medication.ID = ""
+			},
+			wantIssues: []string{"Medication.id"},
+		},
+		{
+			name: "medication code is not KFA",
+			modify: func(req *PrescriptionRequest) {
+				req.Medication.Code.Coding[0].System = "http://snomed.info/sct"
+			},
+			wantIssues: []string{"Medication.code.coding[0].system"},
+		},
+		{
+			name: "unknown medication request status",
+			modify: func(req *PrescriptionRequest) {
+				req.MedicationRequest.Status = "paid"
+			},
+			wantIssues: []string{"MedicationRequest.status"},
+		},
+		{
+			name: "prescription item identifier is missing",
+			modify: func(req *PrescriptionRequest) {
+				req.MedicationRequest.Identifier = req.MedicationRequest.Identifier[:1]
+			},
+			wantIssues: []string{"MedicationRequest.identifier"},
+		},
+		{
+			name: "subject is not a patient",
+			modify: func(req *PrescriptionRequest) {
+				req.MedicationRequest.Subject.Reference = "Practitioner/N001"
+			},
+			wantIssues: []string{"MedicationRequest.subject.reference"},
+		},
+		{
+			name: "reference other medication",
+			modify: func(req *PrescriptionRequest) {
+				req.MedicationRequest.MedicationReference.Reference = "Medication/med-2"
+			},
+			wantIssues: []string{"MedicationRequest.medicationReference.reference"},
+		},
+		{
+			name: "negative repeats",
+			modify: func(req *PrescriptionRequest) {
+				req.MedicationRequest.DispenseRequest.NumberOfRepeatsAllowed = -1
+			},
+			wantIssues: []string{"MedicationRequest.dispenseRequest.numberOfRepeatsAllowed"},
+		},
+		{
+			name: "validity period end before start",
+			modify: func(req *PrescriptionRequest) {
+				req.MedicationRequest.DispenseRequest.ValidityPeriod = ValidityPeriod{Start: "2024-05-01", End: "2024-04-30"}
+			},
+			wantIssues: []string{"MedicationRequest.dispenseRequest.validityPeriod.end"},
+		},
+		{
+			name: "invalid validity period",
+			modify: func(req *PrescriptionRequest) {
+				req.MedicationRequest.DispenseRequest.ValidityPeriod.Start = "01/05/2024"
+			},
+			wantIssues: []string{"MedicationRequest.dispenseRequest.validityPeriod.start"},
+		},
+		{
+			name: "every issue is reported",
+			modify: func(req *PrescriptionRequest) {
+				req.Medication.Status = ""
+				req.MedicationRequest.Intent = ""
+			},
+			wantIssues: []string{"Medication.status", "MedicationRequest.intent"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validPrescriptionRequest()
+			tt.modify(&req)
+
+			err := req.Validate()
+			if len(tt.wantIssues) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			outcome, ok := err.(*OperationOutcome)
+			if !ok {
+				t.Fatalf("error = %v, want *OperationOutcome", err)
+			}
+
+			var got []string
+			for _, issue := range outcome.Issue {
+				got = append(got, issue.Expression...)
+			}
+
+			if len(got) != len(tt.wantIssues) {
+				t.Fatalf("issues = %v, want %v", got, tt.wantIssues)
+			}
+
+			for i := range got {
+				if got[i] != tt.wantIssues[i] {
+					t.Errorf("issues = %v, want %v", got, tt.wantIssues)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestParseFHIRPeriod(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+
+	tests := []struct {
+		name      string
+		value     string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "year",
+			value:     "2024",
+			wantStart: time.Date(2024, 1, 1, 0, 0, 0, 0, jakarta),
+			wantEnd:   time.Date(2025, 1, 1, 0, 0, 0, 0, jakarta),
+		},
+		{
+			name:      "month",
+			value:     "2024-02",
+			wantStart: time.Date(2024, 2, 1, 0, 0, 0, 0, jakarta),
+			wantEnd:   time.Date(2024, 3, 1, 0, 0, 0, 0, jakarta),
+		},
+		{
+			name:      "date",
+			value:     "2024-12-31",
+			wantStart: time.Date(2024, 12, 31, 0, 0, 0, 0, jakarta),
+			wantEnd:   time.Date(2025, 1, 1, 0, 0, 0, 0, jakarta),
+		},
+		{
+			name:      "dateTime keep its own timezone",
+			value:     "2024-05-01T10:30:00Z",
+			wantStart: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC),
+		},
+		{name: "empty", value: "", wantErr: true},
+		{name: "day first", value: "01-05-2024", wantErr: true},
+		{name: "dateTime without timezone", value: "2024-05-01T10:30:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := parseFHIRPeriod(tt.value, jakarta)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFHIRPeriod(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("parseFHIRPeriod(%q) = [%s, %s), want [%s, %s)", tt.value, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
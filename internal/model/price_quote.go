@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+type (
+	// PriceQuote lock medication prices from payment info until it expires, so transaction charge the same price patient has seen
+	PriceQuote struct {
+		ID               int       `db:"id" json:"id"`
+		PatientID        int       `db:"patient_id" json:"patient_id"`
+		PatientAddressID *int      `db:"patient_address_id" json:"patient_address_id"`
+		Items            []Item    `db:"items" json:"items"`
+		ShippingCost     int       `db:"shipping_cost" json:"shipping_cost"`
+		TotalPrice       int       `db:"total_price" json:"total_price"`
+		ExpiresAt        time.Time `db:"expires_at" json:"expires_at"`
+		// TransactionID and ConsumedAt are set once the quote back a transaction, quote can only be used once
+		TransactionID *int       `db:"transaction_id" json:"transaction_id"`
+		ConsumedAt    *time.Time `db:"consumed_at" json:"consumed_at"`
+		CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	}
+)
+
+// IsExpired check whether quote can't be used anymore
+func (q PriceQuote) IsExpired() bool {
+	return time.Now().After(q.ExpiresAt)
+}
+
+// IsConsumed check whether quote already back a transaction
+func (q PriceQuote) IsConsumed() bool {
+	return q.ConsumedAt != nil
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+type (
+	// ReconciliationDiscrepancy is a payment which local status differ from its invoice status on payment gateway,
+	// usually caused by missed webhook
+	ReconciliationDiscrepancy struct {
+		ID            int                    `db:"id" json:"id"`
+		PaymentID     int                    `db:"payment_id" json:"payment_id"`
+		TransactionID int                    `db:"transaction_id" json:"transaction_id"`
+		InvoiceID     string                 `db:"invoice_id" json:"invoice_id"`
+		PaymentStatus PaymentStatusEnum      `db:"payment_status" json:"payment_status"`
+		InvoiceStatus string                 `db:"invoice_status" json:"invoice_status"`
+		Result        PaymentEventResultEnum `db:"result" json:"result"`
+		Note          *string                `db:"note" json:"note"`
+		CreatedAt     time.Time              `db:"created_at" json:"created_at"`
+	}
+)
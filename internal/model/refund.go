@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type (
+	RefundStatusEnum string
+
+	// CreateRefundRequest refund the given transaction details, empty transaction details refund every item that is not refunded yet
+	CreateRefundRequest struct {
+		TransactionDetailIDs []int  `json:"transaction_detail_ids"`
+		Reason               string `json:"reason"`
+	}
+
+	Refund struct {
+		ID            int              `db:"id" json:"id"`
+		TransactionID int              `db:"transaction_id" json:"transaction_id"`
+		PaymentID     int              `db:"payment_id" json:"payment_id"`
+		PartnerID     *string          `db:"partner_id" json:"partner_id"`
+		Amount        int              `db:"amount" json:"amount"`
+		Reason        string           `db:"reason" json:"reason"`
+		Status        RefundStatusEnum `db:"status" json:"status"`
+		FailureCode   *string          `db:"failure_code" json:"failure_code"`
+		Details       []RefundDetail   `json:"details"`
+		CreatedAt     time.Time        `db:"created_at" json:"created_at"`
+		UpdatedAt     *time.Time       `db:"updated_at" json:"updated_at"`
+	}
+
+	RefundDetail struct {
+		ID                  int       `db:"id" json:"id"`
+		RefundID            int       `db:"refund_id" json:"refund_id"`
+		TransactionDetailID int       `db:"transaction_detail_id" json:"transaction_detail_id"`
+		Amount              int       `db:"amount" json:"amount"`
+		CreatedAt           time.Time `db:"created_at" json:"created_at"`
+	}
+)
+
+const (
+	RefundStatusEnumPending   RefundStatusEnum = "PENDING"
+	RefundStatusEnumSucceeded RefundStatusEnum = "SUCCEEDED"
+	RefundStatusEnumFailed    RefundStatusEnum = "FAILED"
+
+	// RefundFailureCodeRejected is failure code of refund that payment gateway refuse to create
+	RefundFailureCodeRejected = "REJECTED_BY_PARTNER"
+)
+
+func (v CreateRefundRequest) Validate() error {
+	if err := validation.ValidateStruct(&v,
+		validation.Field(&v.Reason, validation.Required),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+type (
+	// Shipment is courier delivery of a transaction from pharmacy to patient address
+	Shipment struct {
+		ID             int               `db:"id" json:"id"`
+		TransactionID  int               `db:"transaction_id" json:"transaction_id"`
+		PartnerID      string            `db:"partner_id" json:"partner_id"`
+		TrackingNumber string            `db:"tracking_number" json:"tracking_number"`
+		TrackingURL    *string           `db:"tracking_url" json:"tracking_url"`
+		CourierStatus  CourierStatusEnum `db:"courier_status" json:"courier_status"`
+		Price          int               `db:"price" json:"price"`
+		// CourierUpdatedAt is the time of the latest courier status, older webhook is ignored
+		CourierUpdatedAt time.Time  `db:"courier_updated_at" json:"courier_updated_at"`
+		CreatedAt        time.Time  `db:"created_at" json:"created_at"`
+		UpdatedAt        *time.Time `db:"updated_at" json:"updated_at"`
+	}
+)
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+type (
+	// Location is a point used to calculate shipping cost
+	Location struct {
+		Latitude    float64 `json:"latitude"`
+		Longitude   float64 `json:"longitude"`
+		SubDistrict string  `json:"sub_district"`
+	}
+
+	ShippingCostRequest struct {
+		Origin      Location `json:"origin"`
+		Destination Location `json:"destination"`
+	}
+
+	ShippingCost struct {
+		DistanceKm float64 `json:"distance_km"`
+		Price      int     `json:"price"`
+	}
+
+	ShippingTariff struct {
+		ID            int       `db:"id" json:"id"`
+		SubDistrict   *string   `db:"sub_district" json:"sub_district"`
+		MinDistanceKm float64   `db:"min_distance_km" json:"min_distance_km"`
+		MaxDistanceKm float64   `db:"max_distance_km" json:"max_distance_km"`
+		Price         int       `db:"price" json:"price"`
+		CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	}
+)
@@ -15,6 +15,12 @@ type (
 		Items            []Item `json:"items"`
 		AdditionalPrice  int    `db:"additional_price" json:"additional_price"`
 		TotalPrice       int    `db:"total_price" json:"total_price"`
+		QuoteID          int    `json:"quote_id"`
+		// PaymentMethod is empty or INVOICE to pay through xendit invoice page
+		PaymentMethod PaymentMethodEnum `json:"payment_method"`
+		ChannelCode   string            `json:"channel_code"`
+		// MobileNumber is the e-wallet account to be charged, required by OVO
+		MobileNumber string `json:"mobile_number"`
 	}
 
 	TransactionDetail struct {
@@ -38,30 +44,132 @@ type (
 	}
 
 	CreateTransactionResponse struct {
-		ID         string `json:"id"`
-		InvoiceURL string `json:"invoice_url"`
+		ID            string            `json:"id"`
+		InvoiceURL    string            `json:"invoice_url"`
+		PaymentMethod PaymentMethodEnum `json:"payment_method"`
+		ChannelCode   string            `json:"channel_code,omitempty"`
+		// payment instructions of direct charge, only the one of chosen payment method is filled
+		VirtualAccountNumber string `json:"virtual_account_number,omitempty"`
+		QRString             string `json:"qr_string,omitempty"`
+		DeeplinkURL          string `json:"deeplink_url,omitempty"`
+		CheckoutURL          string `json:"checkout_url,omitempty"`
+		// ExpiresAt is payment deadline, so patient can be shown a countdown
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
 	}
 
 	CheckStatusTransactionResponse struct {
 		Transaction *Transaction         `json:"transaction"`
+		Payment     *Payment             `json:"payment"`
 		Items       *[]TransactionDetail `json:"items"`
 	}
+
+	// GetTransactionHistoryRequest filter transaction history of patient, start and end date are inclusive
+	GetTransactionHistoryRequest struct {
+		PatientRefID string                  `param:"ref_id" json:"ref_id"`
+		Statuses     []TransactionStatusEnum `query:"status" json:"status"`
+		StartDate    string                  `query:"start_date" json:"start_date"`
+		EndDate      string                  `query:"end_date" json:"end_date"`
+	}
+
+	// TransactionHistoryFilter is transaction history filter used by repository, created time range is [CreatedFrom, CreatedUntil)
+	TransactionHistoryFilter struct {
+		PatientID    int
+		Statuses     []TransactionStatusEnum
+		CreatedFrom  *time.Time
+		CreatedUntil *time.Time
+	}
+
+	TransactionHistory struct {
+		Transaction
+		PaymentStatus PaymentStatusEnum   `db:"payment_status" json:"payment_status"`
+		PaymentMethod PaymentMethodEnum   `db:"payment_method" json:"payment_method"`
+		Items         []TransactionDetail `json:"items"`
+	}
 )
 
+// TransactionHistoryDateLayout is date layout of transaction history start and end date
+const TransactionHistoryDateLayout = "2006-01-02"
+
 const (
-	TransactionStatusEnumPending TransactionStatusEnum = "PENDING"
-	TransactionStatusEnumProcess TransactionStatusEnum = "PROCESS"
-	TransactionStatusEnumSuccess TransactionStatusEnum = "SUCCESS"
-	TransactionStatusEnumFailed  TransactionStatusEnum = "FAILED"
-	TransactionStatusEnumExpired TransactionStatusEnum = "EXPIRED"
+	TransactionStatusEnumPending   TransactionStatusEnum = "PENDING"
+	TransactionStatusEnumProcess   TransactionStatusEnum = "PROCESS"
+	TransactionStatusEnumSuccess   TransactionStatusEnum = "SUCCESS"
+	TransactionStatusEnumFailed    TransactionStatusEnum = "FAILED"
+	TransactionStatusEnumExpired   TransactionStatusEnum = "EXPIRED"
+	TransactionStatusEnumCancelled TransactionStatusEnum = "CANCELLED"
+	// TransactionStatusEnumPartiallyRefunded is transaction which some of the items are refunded
+	TransactionStatusEnumPartiallyRefunded TransactionStatusEnum = "PARTIALLY_REFUNDED"
+	TransactionStatusEnumRefunded          TransactionStatusEnum = "REFUNDED"
 )
 
+// DispensingTransactionStatuses are statuses of transaction whose items are counted as dispensed, pending payment hold the item
+// so the same repeat can't be paid twice. Refunded transaction give every repeat back, refunded item of partially refunded
+// transaction is excluded one by one
+var DispensingTransactionStatuses = []TransactionStatusEnum{
+	TransactionStatusEnumPending,
+	TransactionStatusEnumProcess,
+	TransactionStatusEnumSuccess,
+	TransactionStatusEnumPartiallyRefunded,
+}
+
+// transactionStatusTransitions list the legal next status of each transaction status, final status has no next status
+var transactionStatusTransitions = map[TransactionStatusEnum][]TransactionStatusEnum{
+	TransactionStatusEnumPending:           {TransactionStatusEnumProcess, TransactionStatusEnumSuccess, TransactionStatusEnumFailed, TransactionStatusEnumExpired},
+	TransactionStatusEnumProcess:           {TransactionStatusEnumSuccess, TransactionStatusEnumFailed, TransactionStatusEnumExpired, TransactionStatusEnumCancelled},
+	TransactionStatusEnumSuccess:           {TransactionStatusEnumPartiallyRefunded, TransactionStatusEnumRefunded},
+	TransactionStatusEnumPartiallyRefunded: {TransactionStatusEnumRefunded},
+}
+
+// CanTransitionTo check whether transaction status is allowed to move into next status
+func (s TransactionStatusEnum) CanTransitionTo(next TransactionStatusEnum) bool {
+	for _, status := range transactionStatusTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v GetTransactionHistoryRequest) Validate() error {
+	if err := validation.ValidateStruct(&v,
+		validation.Field(&v.PatientRefID, validation.Required),
+		validation.Field(&v.Statuses, validation.Each(validation.In(
+			TransactionStatusEnumPending,
+			TransactionStatusEnumProcess,
+			TransactionStatusEnumSuccess,
+			TransactionStatusEnumFailed,
+			TransactionStatusEnumExpired,
+			TransactionStatusEnumCancelled,
+			TransactionStatusEnumPartiallyRefunded,
+			TransactionStatusEnumRefunded,
+		))),
+		validation.Field(&v.StartDate, validation.Date(TransactionHistoryDateLayout)),
+		validation.Field(&v.EndDate, validation.Date(TransactionHistoryDateLayout)),
+	); err != nil {
+		return err
+	}
+
+	// date layout sort the same way as the date itself
+	if v.StartDate != "" && v.EndDate != "" && v.EndDate < v.StartDate {
+		return validation.Errors{"end_date": validation.NewError("validation_date_range", "must not be before start date")}
+	}
+
+	return nil
+}
+
 func (v CreateTransactionRequest) Validate() error {
 	if err := validation.ValidateStruct(&v,
 		validation.Field(&v.PatientID, validation.Required),
 		validation.Field(&v.PatientAddressID, validation.Required),
 		validation.Field(&v.Items, validation.Required),
 		validation.Field(&v.TotalPrice, validation.Required),
+		validation.Field(&v.PaymentMethod, validation.In(PaymentMethodEnumInvoice, PaymentMethodEnumQRIS, PaymentMethodEnumVirtualAccount, PaymentMethodEnumEwallet)),
+		validation.Field(&v.ChannelCode,
+			validation.When(v.PaymentMethod == PaymentMethodEnumVirtualAccount, validation.Required, validation.In(VirtualAccountChannelCodes...)),
+			validation.When(v.PaymentMethod == PaymentMethodEnumEwallet, validation.Required, validation.In(EwalletChannelCodes...)),
+		),
+		validation.Field(&v.MobileNumber, validation.When(v.PaymentMethod == PaymentMethodEnumEwallet && v.ChannelCode == "OVO", validation.Required)),
 	); err != nil {
 		return err
 	}
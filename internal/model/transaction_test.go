@@ -0,0 +1,33 @@
+package model
+
+import "testing"
+
+func TestTransactionStatusEnumCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		name string
+		from TransactionStatusEnum
+		to   TransactionStatusEnum
+		want bool
+	}{
+		{name: "pending to process", from: TransactionStatusEnumPending, to: TransactionStatusEnumProcess, want: true},
+		{name: "pending to expired", from: TransactionStatusEnumPending, to: TransactionStatusEnumExpired, want: true},
+		{name: "process to success", from: TransactionStatusEnumProcess, to: TransactionStatusEnumSuccess, want: true},
+		{name: "process to cancelled", from: TransactionStatusEnumProcess, to: TransactionStatusEnumCancelled, want: true},
+		{name: "success to refunded", from: TransactionStatusEnumSuccess, to: TransactionStatusEnumRefunded, want: true},
+		{name: "partially refunded to refunded", from: TransactionStatusEnumPartiallyRefunded, to: TransactionStatusEnumRefunded, want: true},
+		{name: "pending to cancelled", from: TransactionStatusEnumPending, to: TransactionStatusEnumCancelled, want: false},
+		{name: "success to failed", from: TransactionStatusEnumSuccess, to: TransactionStatusEnumFailed, want: false},
+		{name: "cancelled to success", from: TransactionStatusEnumCancelled, to: TransactionStatusEnumSuccess, want: false},
+		{name: "expired to process", from: TransactionStatusEnumExpired, to: TransactionStatusEnumProcess, want: false},
+		{name: "refunded to success", from: TransactionStatusEnumRefunded, to: TransactionStatusEnumSuccess, want: false},
+		{name: "partially refunded to success", from: TransactionStatusEnumPartiallyRefunded, to: TransactionStatusEnumSuccess, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
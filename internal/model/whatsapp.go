@@ -9,5 +9,6 @@ type SendMessageRequest struct {
 }
 
 const (
-	TemplateSendPrescription TemplateName = "SEND_PRESCRIPTION"
+	TemplateSendPrescription   TemplateName = "SEND_PRESCRIPTION"
+	TemplateCancelPrescription TemplateName = "CANCEL_PRESCRIPTION"
 )
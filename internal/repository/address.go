@@ -5,7 +5,6 @@ import (
 	"e-resep-be/internal/config"
 	"e-resep-be/internal/model"
 
-	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
@@ -23,12 +22,12 @@ type (
 		Context context.Context
 		Config  *config.Configuration
 		Logger  *logrus.Logger
-		DB      *pgxpool.Pool
+		DB      DBTX
 	}
 )
 
 // NewAddressRepository return new instances address repository
-func NewAddressRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db *pgxpool.Pool) *AddressRepositoryImpl {
+func NewAddressRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *AddressRepositoryImpl {
 	return &AddressRepositoryImpl{
 		Context: ctx,
 		Config:  config,
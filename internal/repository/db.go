@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// DBTX is database handle used by repositories, it is satisfied by both connection pool and transaction,
+// so the same repository can run standalone or inside a unit of work
+type DBTX interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// uniqueViolationCode is postgres error code of unique constraint violation
+const uniqueViolationCode = "23505"
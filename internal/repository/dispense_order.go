@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"encoding/json"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// DispenseOrderRepository is an interface that has all the function to be implemented inside dispense order repository
+	DispenseOrderRepository interface {
+		Insert(ctx context.Context, req *model.DispenseOrder) error
+		GetItemsByTransactionID(ctx context.Context, transactionID int) ([]model.DispenseOrderItem, error)
+	}
+
+	// DispenseOrderRepositoryImpl is an app dispense order struct that consists of all the dependencies needed for dispense order repository
+	DispenseOrderRepositoryImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      DBTX
+	}
+)
+
+// NewDispenseOrderRepository return new instances dispense order repository
+func NewDispenseOrderRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *DispenseOrderRepositoryImpl {
+	return &DispenseOrderRepositoryImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+// Insert insert dispense order accepted by kimia farma, transaction that already has dispense order is kept as is
+func (dr *DispenseOrderRepositoryImpl) Insert(ctx context.Context, req *model.DispenseOrder) error {
+	q := `
+		INSERT INTO dispense_order (transaction_id, partner_id, submitted_at) VALUES ($1,$2,$3) ON CONFLICT (transaction_id) DO NOTHING
+	`
+
+	_, err := dr.DB.Exec(ctx, q, req.TransactionID, req.PartnerID, req.SubmittedAt)
+	if err != nil {
+		dr.Logger.Error("DispenseOrderRepositoryImpl.Insert Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// GetItemsByTransactionID return transaction items with kfa code and the latest medication request of the patient prescribing them
+func (dr *DispenseOrderRepositoryImpl) GetItemsByTransactionID(ctx context.Context, transactionID int) ([]model.DispenseOrderItem, error) {
+	q := `
+		SELECT
+			td.medication_id,
+			COALESCE(m.code, ''),
+			td.medication_name,
+			td.price,
+			mr.ref_id,
+			mr.dosage_instructions,
+			mr.dispense_request
+		FROM
+			transaction_detail td
+		JOIN
+			transaction t ON t.id = td.transaction_id
+		JOIN
+			medication m ON m.id = td.medication_id
+		JOIN LATERAL (
+			SELECT
+				ref_id,
+				dosage_instructions,
+				dispense_request
+			FROM
+				medication_request
+			WHERE
+				id = td.medication_request_id
+			OR (
+				td.medication_request_id IS NULL
+				AND medication_id = td.medication_id
+				AND patient_id = t.patient_id
+			)
+			ORDER BY
+				created_at DESC
+			LIMIT 1
+		) mr ON TRUE
+		WHERE
+			td.transaction_id = $1
+		ORDER BY
+			td.id ASC
+	`
+
+	items := []model.DispenseOrderItem{}
+
+	rows, err := dr.DB.Query(ctx, q, transactionID)
+	if err != nil {
+		dr.Logger.Error("DispenseOrderRepositoryImpl.GetItemsByTransactionID Query ERROR", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			item                   = model.DispenseOrderItem{}
+			dosageInstructionsData []byte
+			dispenseRequestData    []byte
+		)
+
+		err := rows.Scan(
+			&item.MedicationID,
+			&item.KFACode,
+			&item.Name,
+			&item.Price,
+			&item.MedicationRequestID,
+			&dosageInstructionsData,
+			&dispenseRequestData,
+		)
+		if err != nil {
+			dr.Logger.Error("DispenseOrderRepositoryImpl.GetItemsByTransactionID rows Scan ERROR", err)
+
+			return nil, err
+		}
+
+		err = json.Unmarshal(dosageInstructionsData, &item.DosageInstructions)
+		if err != nil {
+			dr.Logger.Error("DispenseOrderRepositoryImpl.GetItemsByTransactionID dosage instructions json unmarshal ERROR", err)
+
+			return nil, err
+		}
+
+		err = json.Unmarshal(dispenseRequestData, &item.DispenseRequest)
+		if err != nil {
+			dr.Logger.Error("DispenseOrderRepositoryImpl.GetItemsByTransactionID dispense request json unmarshal ERROR", err)
+
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// FulfilmentRepository is an interface that has all the function to be implemented inside fulfilment repository
+	FulfilmentRepository interface {
+		Insert(ctx context.Context, req *model.Fulfilment) error
+		GetByTransactionID(ctx context.Context, transactionID int) (*model.Fulfilment, error)
+		GetByTransactionIDForUpdate(ctx context.Context, transactionID int) (*model.Fulfilment, error)
+		UpdateStatusByID(ctx context.Context, status model.FulfilmentStatusEnum, note string, at time.Time, id int) error
+	}
+
+	// FulfilmentRepositoryImpl is an app fulfilment struct that consists of all the dependencies needed for fulfilment repository
+	FulfilmentRepositoryImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      DBTX
+	}
+)
+
+// fulfilmentStatusColumns is the column keeping the time each fulfilment status is reached
+var fulfilmentStatusColumns = map[model.FulfilmentStatusEnum]string{
+	model.FulfilmentStatusEnumPreparing: "preparing_at",
+	model.FulfilmentStatusEnumShipped:   "shipped_at",
+	model.FulfilmentStatusEnumDelivered: "delivered_at",
+	model.FulfilmentStatusEnumReturned:  "returned_at",
+}
+
+// NewFulfilmentRepository return new instances fulfilment repository
+func NewFulfilmentRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *FulfilmentRepositoryImpl {
+	return &FulfilmentRepositoryImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+// Insert insert fulfilment of paid transaction, transaction that already has fulfilment is kept as is
+func (fr *FulfilmentRepositoryImpl) Insert(ctx context.Context, req *model.Fulfilment) error {
+	q := `
+		INSERT INTO fulfilment (transaction_id, status, paid_at) VALUES ($1,$2,$3) ON CONFLICT (transaction_id) DO NOTHING
+	`
+
+	_, err := fr.DB.Exec(ctx, q, req.TransactionID, req.Status, req.PaidAt)
+	if err != nil {
+		fr.Logger.Error("FulfilmentRepositoryImpl.Insert Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (fr *FulfilmentRepositoryImpl) GetByTransactionID(ctx context.Context, transactionID int) (*model.Fulfilment, error) {
+	q := `
+		SELECT
+			id,
+			transaction_id,
+			status,
+			paid_at,
+			preparing_at,
+			shipped_at,
+			delivered_at,
+			returned_at,
+			note,
+			created_at,
+			updated_at
+		FROM
+			fulfilment
+		WHERE
+			transaction_id = $1
+	`
+
+	fulfilment := model.Fulfilment{}
+	row := fr.DB.QueryRow(ctx, q, transactionID)
+	err := row.Scan(
+		&fulfilment.ID,
+		&fulfilment.TransactionID,
+		&fulfilment.Status,
+		&fulfilment.PaidAt,
+		&fulfilment.PreparingAt,
+		&fulfilment.ShippedAt,
+		&fulfilment.DeliveredAt,
+		&fulfilment.ReturnedAt,
+		&fulfilment.Note,
+		&fulfilment.CreatedAt,
+		&fulfilment.UpdatedAt,
+	)
+	if err != nil {
+		fr.Logger.Error("FulfilmentRepositoryImpl.GetByTransactionID QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return &fulfilment, nil
+}
+
+// GetByTransactionIDForUpdate get fulfilment by transaction id and lock the row until the running transaction end, must be called inside unit of work
+func (fr *FulfilmentRepositoryImpl) GetByTransactionIDForUpdate(ctx context.Context, transactionID int) (*model.Fulfilment, error) {
+	q := `
+		SELECT
+			id,
+			transaction_id,
+			status,
+			paid_at,
+			preparing_at,
+			shipped_at,
+			delivered_at,
+			returned_at,
+			note,
+			created_at,
+			updated_at
+		FROM
+			fulfilment
+		WHERE
+			transaction_id = $1
+		FOR UPDATE
+	`
+
+	fulfilment := model.Fulfilment{}
+	row := fr.DB.QueryRow(ctx, q, transactionID)
+	err := row.Scan(
+		&fulfilment.ID,
+		&fulfilment.TransactionID,
+		&fulfilment.Status,
+		&fulfilment.PaidAt,
+		&fulfilment.PreparingAt,
+		&fulfilment.ShippedAt,
+		&fulfilment.DeliveredAt,
+		&fulfilment.ReturnedAt,
+		&fulfilment.Note,
+		&fulfilment.CreatedAt,
+		&fulfilment.UpdatedAt,
+	)
+	if err != nil {
+		fr.Logger.Error("FulfilmentRepositoryImpl.GetByTransactionIDForUpdate QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return &fulfilment, nil
+}
+
+// UpdateStatusByID move fulfilment into the given status and record the time it is reached, empty note keep the current note
+func (fr *FulfilmentRepositoryImpl) UpdateStatusByID(ctx context.Context, status model.FulfilmentStatusEnum, note string, at time.Time, id int) error {
+	column, ok := fulfilmentStatusColumns[status]
+	if !ok {
+		return fmt.Errorf("fulfilment status %s doesn't have time column", status)
+	}
+
+	q := fmt.Sprintf(`
+		UPDATE fulfilment SET status = $1, %s = $2, note = COALESCE(NULLIF($3, ''), note), updated_at = NOW() WHERE id = $4
+	`, column)
+
+	_, err := fr.DB.Exec(ctx, q, status, at, note, id)
+	if err != nil {
+		fr.Logger.Error("FulfilmentRepositoryImpl.UpdateStatusByID Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// IdempotencyKeyRepository is an interface that has all the function to be implemented inside idempotency key repository
+	IdempotencyKeyRepository interface {
+		InsertOrGet(ctx context.Context, req *model.IdempotencyKey) (*model.IdempotencyKey, bool, error)
+	}
+
+	// IdempotencyKeyRepositoryImpl is an app idempotency key struct that consists of all the dependencies needed for idempotency key repository
+	IdempotencyKeyRepositoryImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      DBTX
+	}
+)
+
+// NewIdempotencyKeyRepository return new instances idempotency key repository
+func NewIdempotencyKeyRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *IdempotencyKeyRepositoryImpl {
+	return &IdempotencyKeyRepositoryImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+// InsertOrGet insert idempotency key, the key that is already stored is returned instead with false.
+// Inside unit of work, concurrent insert of the same key wait until the first transaction end
+func (ir *IdempotencyKeyRepositoryImpl) InsertOrGet(ctx context.Context, req *model.IdempotencyKey) (*model.IdempotencyKey, bool, error) {
+	qInsert := `
+		INSERT INTO idempotency_key (scope, key, request_hash) VALUES ($1,$2,$3)
+		ON CONFLICT (scope, key) DO NOTHING
+		RETURNING id, created_at
+	`
+
+	key := *req
+	row := ir.DB.QueryRow(ctx, qInsert, req.Scope, req.Key, req.RequestHash)
+	err := row.Scan(
+		&key.ID,
+		&key.CreatedAt,
+	)
+	if err == nil {
+		return &key, true, nil
+	}
+
+	if err.Error() != pgx.ErrNoRows.Error() {
+		ir.Logger.Error("IdempotencyKeyRepositoryImpl.InsertOrGet Insert QueryRow Scan ERROR", err)
+
+		return nil, false, err
+	}
+
+	q := `
+		SELECT
+			id,
+			scope,
+			key,
+			request_hash,
+			created_at
+		FROM
+			idempotency_key
+		WHERE
+			scope = $1
+		AND
+			key = $2
+	`
+
+	row = ir.DB.QueryRow(ctx, q, req.Scope, req.Key)
+	err = row.Scan(
+		&key.ID,
+		&key.Scope,
+		&key.Key,
+		&key.RequestHash,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		ir.Logger.Error("IdempotencyKeyRepositoryImpl.InsertOrGet QueryRow.Scan ERROR", err)
+
+		return nil, false, err
+	}
+
+	return &key, false, nil
+}
@@ -5,7 +5,6 @@ import (
 	"e-resep-be/internal/config"
 	"e-resep-be/internal/model"
 
-	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
@@ -13,6 +12,7 @@ type (
 	// MedicationRepository is an interface that has all the function to be implemented inside medication repository
 	MedicationRepository interface {
 		GetByID(ctx context.Context, id int) (*model.MedicationDB, error)
+		GetByIDsAndPatientID(ctx context.Context, ids []int, patientID int) ([]model.MedicationDB, error)
 	}
 
 	// MedicationRepositoryImpl is an app medication struct that consists of all the dependencies needed for medication repository
@@ -20,12 +20,12 @@ type (
 		Context context.Context
 		Config  *config.Configuration
 		Logger  *logrus.Logger
-		DB      *pgxpool.Pool
+		DB      DBTX
 	}
 )
 
 // NewMedicationRepository return new instances medication repository
-func NewMedicationRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db *pgxpool.Pool) *MedicationRepositoryImpl {
+func NewMedicationRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *MedicationRepositoryImpl {
 	return &MedicationRepositoryImpl{
 		Context: ctx,
 		Config:  config,
@@ -62,3 +62,53 @@ func (mr *MedicationRepositoryImpl) GetByID(ctx context.Context, id int) (*model
 
 	return &medication, nil
 }
+
+// GetByIDsAndPatientID return medications that are requested for the patient, medication of other patient is excluded
+func (mr *MedicationRepositoryImpl) GetByIDsAndPatientID(ctx context.Context, ids []int, patientID int) ([]model.MedicationDB, error) {
+	q := `
+		SELECT DISTINCT
+			m.id,
+			m.ref_id,
+			m.code,
+			m.code_display AS display
+		FROM
+			medication m
+		JOIN
+			medication_request mr
+		ON
+			mr.medication_id = m.id
+		WHERE
+			m.id = ANY($1)
+		AND
+			mr.patient_id = $2
+	`
+
+	medications := []model.MedicationDB{}
+
+	rows, err := mr.DB.Query(ctx, q, ids, patientID)
+	if err != nil {
+		mr.Logger.Error("MedicationRepositoryImpl.GetByIDsAndPatientID Query ERROR", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		medication := model.MedicationDB{}
+		err := rows.Scan(
+			&medication.ID,
+			&medication.RefID,
+			&medication.Code,
+			&medication.Display,
+		)
+		if err != nil {
+			mr.Logger.Error("MedicationRepositoryImpl.GetByIDsAndPatientID rows Scan ERROR", err)
+
+			return nil, err
+		}
+
+		medications = append(medications, medication)
+	}
+
+	return medications, nil
+}
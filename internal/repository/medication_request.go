@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// MedicationRequestRepository is an interface that has all the function to be implemented inside medication request repository
+	MedicationRequestRepository interface {
+		GetByRefIDForUpdate(ctx context.Context, refID string) (*model.MedicationRequestDB, error)
+		GetByPrescriptionIDForUpdate(ctx context.Context, prescriptionID string) ([]model.MedicationRequestDB, error)
+		GetByMedicationIDsAndPatientID(ctx context.Context, medicationIDs []int, patientID int) ([]model.MedicationRequestDB, error)
+		GetByMedicationIDsAndPatientIDForUpdate(ctx context.Context, medicationIDs []int, patientID int) ([]model.MedicationRequestDB, error)
+		GetDispenseCountsByIDs(ctx context.Context, ids []int) (map[int]int, error)
+		UpdateStatusByIDs(ctx context.Context, status model.MedicationRequestStatusEnum, reason string, ids []int) error
+	}
+
+	// MedicationRequestRepositoryImpl is an app medication request struct that consists of all the dependencies needed for medication request repository
+	MedicationRequestRepositoryImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      DBTX
+	}
+)
+
+// NewMedicationRequestRepository return new instances medication request repository
+func NewMedicationRequestRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *MedicationRequestRepositoryImpl {
+	return &MedicationRequestRepositoryImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+// GetByRefIDForUpdate get medication request by FHIR id and lock the row until the running transaction end, must be called inside unit of work
+func (mr *MedicationRequestRepositoryImpl) GetByRefIDForUpdate(ctx context.Context, refID string) (*model.MedicationRequestDB, error) {
+	q := `
+		SELECT
+			id,
+			ref_id,
+			medication_id,
+			patient_id,
+			prescription_id,
+			prescription_item_id,
+			status,
+			status_reason,
+			status_changed_at,
+			dispense_request
+		FROM
+			medication_request
+		WHERE
+			ref_id = $1
+		FOR UPDATE
+	`
+
+	row := mr.DB.QueryRow(ctx, q, refID)
+	medicationRequest, err := scanMedicationRequest(row)
+	if err != nil {
+		mr.Logger.Error("MedicationRequestRepositoryImpl.GetByRefIDForUpdate QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return &medicationRequest, nil
+}
+
+// GetByPrescriptionIDForUpdate get every item of prescription and lock the rows until the running transaction end, must be called inside unit of work
+func (mr *MedicationRequestRepositoryImpl) GetByPrescriptionIDForUpdate(ctx context.Context, prescriptionID string) ([]model.MedicationRequestDB, error) {
+	q := `
+		SELECT
+			id,
+			ref_id,
+			medication_id,
+			patient_id,
+			prescription_id,
+			prescription_item_id,
+			status,
+			status_reason,
+			status_changed_at,
+			dispense_request
+		FROM
+			medication_request
+		WHERE
+			prescription_id = $1
+		ORDER BY
+			id
+		FOR UPDATE
+	`
+
+	rows, err := mr.DB.Query(ctx, q, prescriptionID)
+	if err != nil {
+		mr.Logger.Error("MedicationRequestRepositoryImpl.GetByPrescriptionIDForUpdate Query ERROR", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	medicationRequests := []model.MedicationRequestDB{}
+	for rows.Next() {
+		medicationRequest, err := scanMedicationRequest(rows)
+		if err != nil {
+			mr.Logger.Error("MedicationRequestRepositoryImpl.GetByPrescriptionIDForUpdate rows Scan ERROR", err)
+
+			return nil, err
+		}
+
+		medicationRequests = append(medicationRequests, medicationRequest)
+	}
+
+	return medicationRequests, nil
+}
+
+// GetByMedicationIDsAndPatientID return medication requests of the patient for the given medications, newest first
+func (mr *MedicationRequestRepositoryImpl) GetByMedicationIDsAndPatientID(ctx context.Context, medicationIDs []int, patientID int) ([]model.MedicationRequestDB, error) {
+	q := `
+		SELECT
+			id,
+			ref_id,
+			medication_id,
+			patient_id,
+			prescription_id,
+			prescription_item_id,
+			status,
+			status_reason,
+			status_changed_at,
+			dispense_request
+		FROM
+			medication_request
+		WHERE
+			medication_id = ANY($1)
+		AND
+			patient_id = $2
+		ORDER BY
+			id DESC
+	`
+
+	rows, err := mr.DB.Query(ctx, q, medicationIDs, patientID)
+	if err != nil {
+		mr.Logger.Error("MedicationRequestRepositoryImpl.GetByMedicationIDsAndPatientID Query ERROR", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	medicationRequests := []model.MedicationRequestDB{}
+	for rows.Next() {
+		medicationRequest, err := scanMedicationRequest(rows)
+		if err != nil {
+			mr.Logger.Error("MedicationRequestRepositoryImpl.GetByMedicationIDsAndPatientID rows Scan ERROR", err)
+
+			return nil, err
+		}
+
+		medicationRequests = append(medicationRequests, medicationRequest)
+	}
+
+	return medicationRequests, nil
+}
+
+// GetByMedicationIDsAndPatientIDForUpdate return medication requests of the patient for the given medications, newest first,
+// and lock the rows until the running transaction end, must be called inside unit of work
+func (mr *MedicationRequestRepositoryImpl) GetByMedicationIDsAndPatientIDForUpdate(ctx context.Context, medicationIDs []int, patientID int) ([]model.MedicationRequestDB, error) {
+	q := `
+		SELECT
+			id,
+			ref_id,
+			medication_id,
+			patient_id,
+			prescription_id,
+			prescription_item_id,
+			status,
+			status_reason,
+			status_changed_at,
+			dispense_request
+		FROM
+			medication_request
+		WHERE
+			medication_id = ANY($1)
+		AND
+			patient_id = $2
+		ORDER BY
+			id DESC
+		FOR UPDATE
+	`
+
+	rows, err := mr.DB.Query(ctx, q, medicationIDs, patientID)
+	if err != nil {
+		mr.Logger.Error("MedicationRequestRepositoryImpl.GetByMedicationIDsAndPatientIDForUpdate Query ERROR", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	medicationRequests := []model.MedicationRequestDB{}
+	for rows.Next() {
+		medicationRequest, err := scanMedicationRequest(rows)
+		if err != nil {
+			mr.Logger.Error("MedicationRequestRepositoryImpl.GetByMedicationIDsAndPatientIDForUpdate rows Scan ERROR", err)
+
+			return nil, err
+		}
+
+		medicationRequests = append(medicationRequests, medicationRequest)
+	}
+
+	return medicationRequests, nil
+}
+
+// GetDispenseCountsByIDs return how many times each medication request is dispensed, item of transaction that is
+// waiting for payment or paid count as dispensed unless its transaction or the item itself is refunded. Medication
+// request that is never dispensed is absent
+func (mr *MedicationRequestRepositoryImpl) GetDispenseCountsByIDs(ctx context.Context, ids []int) (map[int]int, error) {
+	q := `
+		SELECT
+			td.medication_request_id,
+			COUNT(td.id)
+		FROM
+			transaction_detail td
+		JOIN
+			transaction t ON t.id = td.transaction_id
+		WHERE
+			td.medication_request_id = ANY($1)
+		AND
+			t.status = ANY($2)
+		AND NOT EXISTS (
+			SELECT
+				1
+			FROM
+				refund_detail rd
+			JOIN
+				refund r ON r.id = rd.refund_id
+			WHERE
+				rd.transaction_detail_id = td.id
+			AND
+				r.status = $3
+		)
+		GROUP BY
+			td.medication_request_id
+	`
+
+	statusArgs := make([]string, 0, len(model.DispensingTransactionStatuses))
+	for _, status := range model.DispensingTransactionStatuses {
+		statusArgs = append(statusArgs, string(status))
+	}
+
+	rows, err := mr.DB.Query(ctx, q, ids, statusArgs, model.RefundStatusEnumSucceeded)
+	if err != nil {
+		mr.Logger.Error("MedicationRequestRepositoryImpl.GetDispenseCountsByIDs Query ERROR", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[int]int{}
+	for rows.Next() {
+		var id, count int
+		err := rows.Scan(&id, &count)
+		if err != nil {
+			mr.Logger.Error("MedicationRequestRepositoryImpl.GetDispenseCountsByIDs rows Scan ERROR", err)
+
+			return nil, err
+		}
+
+		counts[id] = count
+	}
+
+	return counts, nil
+}
+
+// UpdateStatusByIDs move medication requests into the given status, status inside the stored FHIR resource is kept in sync
+func (mr *MedicationRequestRepositoryImpl) UpdateStatusByIDs(ctx context.Context, status model.MedicationRequestStatusEnum, reason string, ids []int) error {
+	q := `
+		UPDATE medication_request
+		SET
+			status = $1,
+			status_reason = NULLIF($2, ''),
+			status_changed_at = NOW(),
+			raw_request = jsonb_set(COALESCE(raw_request, '{}'::jsonb), '{status}', to_jsonb($1::text)),
+			updated_at = NOW()
+		WHERE
+			id = ANY($3)
+	`
+
+	_, err := mr.DB.Exec(ctx, q, status, reason, ids)
+	if err != nil {
+		mr.Logger.Error("MedicationRequestRepositoryImpl.UpdateStatusByIDs Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// scanMedicationRequest scan medication request row selected with dispense request as the last column
+func scanMedicationRequest(row pgx.Row) (model.MedicationRequestDB, error) {
+	var (
+		medicationRequest   = model.MedicationRequestDB{}
+		dispenseRequestData []byte
+	)
+
+	err := row.Scan(
+		&medicationRequest.ID,
+		&medicationRequest.RefID,
+		&medicationRequest.MedicationID,
+		&medicationRequest.PatientID,
+		&medicationRequest.PrescriptionID,
+		&medicationRequest.PrescriptionItemID,
+		&medicationRequest.Status,
+		&medicationRequest.StatusReason,
+		&medicationRequest.StatusChangedAt,
+		&dispenseRequestData,
+	)
+	if err != nil {
+		return medicationRequest, err
+	}
+
+	err = json.Unmarshal(dispenseRequestData, &medicationRequest.DispenseRequest)
+	if err != nil {
+		return medicationRequest, err
+	}
+
+	return medicationRequest, nil
+}
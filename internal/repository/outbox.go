@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// OutboxRepository is an interface that has all the function to be implemented inside outbox repository
+	OutboxRepository interface {
+		Insert(ctx context.Context, req *model.OutboxMessage) (int, error)
+		GetDue(ctx context.Context, now time.Time, limit int) ([]model.OutboxMessage, error)
+		GetByIDForUpdate(ctx context.Context, id int) (*model.OutboxMessage, error)
+		UpdateNextAttemptAtByID(ctx context.Context, nextAttemptAt time.Time, id int) error
+		UpdateDispatchedByID(ctx context.Context, dispatchedAt time.Time, id int) error
+		UpdateAttemptByID(ctx context.Context, status model.OutboxStatusEnum, lastError string, nextAttemptAt time.Time, id int) error
+	}
+
+	// OutboxRepositoryImpl is an app outbox struct that consists of all the dependencies needed for outbox repository
+	OutboxRepositoryImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      DBTX
+	}
+)
+
+// NewOutboxRepository return new instances outbox repository
+func NewOutboxRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *OutboxRepositoryImpl {
+	return &OutboxRepositoryImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+// Insert insert outbox message, must be called inside unit of work of the state change that trigger it
+func (or *OutboxRepositoryImpl) Insert(ctx context.Context, req *model.OutboxMessage) (int, error) {
+	q := `
+		INSERT INTO outbox (event_type, aggregate_id, payload, status, next_attempt_at) VALUES ($1,$2,$3,$4,$5) RETURNING id
+	`
+
+	var messageID int
+	row := or.DB.QueryRow(ctx, q, req.EventType, req.AggregateID, string(req.Payload), req.Status, req.NextAttemptAt)
+	err := row.Scan(
+		&messageID,
+	)
+	if err != nil {
+		or.Logger.Error("OutboxRepositoryImpl.Insert QueryRow Scan ERROR", err)
+
+		return 0, err
+	}
+
+	return messageID, nil
+}
+
+// GetDue return pending outbox messages which next attempt is due, the oldest first
+func (or *OutboxRepositoryImpl) GetDue(ctx context.Context, now time.Time, limit int) ([]model.OutboxMessage, error) {
+	q := `
+		SELECT
+			id,
+			event_type,
+			aggregate_id,
+			payload,
+			status,
+			attempts,
+			next_attempt_at,
+			last_error,
+			dispatched_at,
+			created_at,
+			updated_at
+		FROM
+			outbox
+		WHERE
+			status = $1
+		AND
+			next_attempt_at <= $2
+		ORDER BY
+			id ASC
+		LIMIT $3
+	`
+
+	messages := []model.OutboxMessage{}
+
+	rows, err := or.DB.Query(ctx, q, model.OutboxStatusEnumPending, now, limit)
+	if err != nil {
+		or.Logger.Error("OutboxRepositoryImpl.GetDue Query ERROR", err)
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			message     = model.OutboxMessage{}
+			payloadData []byte
+		)
+
+		err := rows.Scan(
+			&message.ID,
+			&message.EventType,
+			&message.AggregateID,
+			&payloadData,
+			&message.Status,
+			&message.Attempts,
+			&message.NextAttemptAt,
+			&message.LastError,
+			&message.DispatchedAt,
+			&message.CreatedAt,
+			&message.UpdatedAt,
+		)
+		if err != nil {
+			or.Logger.Error("OutboxRepositoryImpl.GetDue rows Scan ERROR", err)
+
+			return nil, err
+		}
+
+		message.Payload = payloadData
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// GetByIDForUpdate get outbox message by id and lock the row until the running transaction end, must be called inside unit of work
+func (or *OutboxRepositoryImpl) GetByIDForUpdate(ctx context.Context, id int) (*model.OutboxMessage, error) {
+	q := `
+		SELECT
+			id,
+			event_type,
+			aggregate_id,
+			payload,
+			status,
+			attempts,
+			next_attempt_at,
+			last_error,
+			dispatched_at,
+			created_at,
+			updated_at
+		FROM
+			outbox
+		WHERE
+			id = $1
+		FOR UPDATE
+	`
+
+	var (
+		message     = model.OutboxMessage{}
+		payloadData []byte
+	)
+
+	row := or.DB.QueryRow(ctx, q, id)
+	err := row.Scan(
+		&message.ID,
+		&message.EventType,
+		&message.AggregateID,
+		&payloadData,
+		&message.Status,
+		&message.Attempts,
+		&message.NextAttemptAt,
+		&message.LastError,
+		&message.DispatchedAt,
+		&message.CreatedAt,
+		&message.UpdatedAt,
+	)
+	if err != nil {
+		or.Logger.Error("OutboxRepositoryImpl.GetByIDForUpdate QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	message.Payload = payloadData
+
+	return &message, nil
+}
+
+// UpdateNextAttemptAtByID move next attempt of outbox message without counting an attempt, it is used to claim the message
+func (or *OutboxRepositoryImpl) UpdateNextAttemptAtByID(ctx context.Context, nextAttemptAt time.Time, id int) error {
+	q := `
+		UPDATE outbox SET next_attempt_at = $1, updated_at = NOW() WHERE id = $2
+	`
+
+	_, err := or.DB.Exec(ctx, q, nextAttemptAt, id)
+	if err != nil {
+		or.Logger.Error("OutboxRepositoryImpl.UpdateNextAttemptAtByID Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (or *OutboxRepositoryImpl) UpdateDispatchedByID(ctx context.Context, dispatchedAt time.Time, id int) error {
+	q := `
+		UPDATE outbox SET status = $1, dispatched_at = $2, attempts = attempts + 1, last_error = NULL, updated_at = NOW() WHERE id = $3
+	`
+
+	_, err := or.DB.Exec(ctx, q, model.OutboxStatusEnumDispatched, dispatchedAt, id)
+	if err != nil {
+		or.Logger.Error("OutboxRepositoryImpl.UpdateDispatchedByID Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// UpdateAttemptByID record failed attempt of outbox message and schedule the next one
+func (or *OutboxRepositoryImpl) UpdateAttemptByID(ctx context.Context, status model.OutboxStatusEnum, lastError string, nextAttemptAt time.Time, id int) error {
+	q := `
+		UPDATE outbox SET status = $1, last_error = $2, next_attempt_at = $3, attempts = attempts + 1, updated_at = NOW() WHERE id = $4
+	`
+
+	_, err := or.DB.Exec(ctx, q, status, lastError, nextAttemptAt, id)
+	if err != nil {
+		or.Logger.Error("OutboxRepositoryImpl.UpdateAttemptByID Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
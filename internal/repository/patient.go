@@ -5,7 +5,6 @@ import (
 	"e-resep-be/internal/config"
 	"e-resep-be/internal/model"
 
-	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
@@ -21,12 +20,12 @@ type (
 		Context context.Context
 		Config  *config.Configuration
 		Logger  *logrus.Logger
-		DB      *pgxpool.Pool
+		DB      DBTX
 	}
 )
 
 // NewPatientRepository return new instances patient repository
-func NewPatientRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db *pgxpool.Pool) *PatientRepositoryImpl {
+func NewPatientRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *PatientRepositoryImpl {
 	return &PatientRepositoryImpl{
 		Context: ctx,
 		Config:  config,
@@ -42,6 +41,7 @@ func (pr *PatientRepositoryImpl) GetByRefID(ctx context.Context, refID string) (
 			ref_id,
 			name,
 			phone_number,
+			email,
 			created_at
 		FROM
 			patient
@@ -56,6 +56,7 @@ func (pr *PatientRepositoryImpl) GetByRefID(ctx context.Context, refID string) (
 		&patient.RefID,
 		&patient.Name,
 		&patient.PhoneNumber,
+		&patient.Email,
 		&patient.CreatedAt,
 	)
 	if err != nil {
@@ -74,6 +75,7 @@ func (pr *PatientRepositoryImpl) GetByID(ctx context.Context, id int) (*model.Pa
 			ref_id,
 			name,
 			phone_number,
+			email,
 			created_at
 		FROM
 			patient
@@ -88,6 +90,7 @@ func (pr *PatientRepositoryImpl) GetByID(ctx context.Context, id int) (*model.Pa
 		&patient.RefID,
 		&patient.Name,
 		&patient.PhoneNumber,
+		&patient.Email,
 		&patient.CreatedAt,
 	)
 	if err != nil {
@@ -5,7 +5,6 @@ import (
 	"e-resep-be/internal/config"
 	"e-resep-be/internal/model"
 
-	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,6 +14,7 @@ type (
 		Insert(ctx context.Context, req *model.CreateOrUpdatePatientAddressRequest, patientID int) error
 		UpdateByID(ctx context.Context, req *model.CreateOrUpdatePatientAddressRequest, id int) error
 		GetByPatientID(ctx context.Context, patientID int) (*[]model.PatientAddress, error)
+		GetByID(ctx context.Context, id int) (*model.PatientAddress, error)
 	}
 
 	// AddressRepositoryImpl is an app address struct that consists of all the dependencies needed for patient address repository
@@ -22,12 +22,12 @@ type (
 		Context context.Context
 		Config  *config.Configuration
 		Logger  *logrus.Logger
-		DB      *pgxpool.Pool
+		DB      DBTX
 	}
 )
 
 // NewPatientAddressRepository return new instances patient address repository
-func NewPatientAddressRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db *pgxpool.Pool) *PatientAddressRepositoryImpl {
+func NewPatientAddressRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *PatientAddressRepositoryImpl {
 	return &PatientAddressRepositoryImpl{
 		Context: ctx,
 		Config:  config,
@@ -127,3 +127,53 @@ func (pr *PatientAddressRepositoryImpl) GetByPatientID(ctx context.Context, pati
 
 	return &patientAddress, nil
 }
+
+func (pr *PatientAddressRepositoryImpl) GetByID(ctx context.Context, id int) (*model.PatientAddress, error) {
+	q := `
+		SELECT
+			id,
+			patient_id,
+			address,
+			sub_district,
+			district,
+			city,
+			province,
+			postal_code,
+			SPLIT_PART(TRIM(coordinates ::TEXT, '()'), ',', 1)::FLOAT AS latitude,
+			SPLIT_PART(TRIM(coordinates ::TEXT, '()'), ',', 2)::FLOAT AS longitude,
+			recipent_name,
+			recipent_phone_number,
+			additional_notes,
+			created_at
+		FROM
+			patient_address
+		WHERE
+			id = $1
+	`
+
+	pAddress := model.PatientAddress{}
+	row := pr.DB.QueryRow(ctx, q, id)
+	err := row.Scan(
+		&pAddress.ID,
+		&pAddress.PatientID,
+		&pAddress.Address,
+		&pAddress.SubDistrict,
+		&pAddress.District,
+		&pAddress.City,
+		&pAddress.Province,
+		&pAddress.PostalCode,
+		&pAddress.Latitude,
+		&pAddress.Longitude,
+		&pAddress.RecipentName,
+		&pAddress.RecipentPhoneNumber,
+		&pAddress.AdditionalNotes,
+		&pAddress.CreatedAt,
+	)
+	if err != nil {
+		pr.Logger.Error("PatientAddressRepositoryImpl.GetByID QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return &pAddress, nil
+}
@@ -7,8 +7,8 @@ import (
 	"e-resep-be/internal/model"
 	"fmt"
 	"reflect"
+	"time"
 
-	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
@@ -18,7 +18,11 @@ type (
 		Insert(ctx context.Context, req *model.CreatePaymentRequest) (int, error)
 		UpdateByID(ctx context.Context, req model.Payment, id int) error
 		GetByID(ctx context.Context, id int) (*model.Payment, error)
+		GetByIDForUpdate(ctx context.Context, id int) (*model.Payment, error)
 		GetByPartnerID(ctx context.Context, partnerID string) (*model.Payment, error)
+		GetByTransactionID(ctx context.Context, transactionID int) (*model.Payment, error)
+		GetByStatusUpdatedBefore(ctx context.Context, status model.PaymentStatusEnum, updatedBefore time.Time, limit int) ([]model.Payment, error)
+		UpdateReconciledAtByID(ctx context.Context, at time.Time, id int) error
 	}
 
 	// TransactionRepositoryImpl is an app payment struct that consists of all the dependencies needed for payment repository
@@ -26,12 +30,12 @@ type (
 		Context context.Context
 		Config  *config.Configuration
 		Logger  *logrus.Logger
-		DB      *pgxpool.Pool
+		DB      DBTX
 	}
 )
 
 // NewPaymentRepository return new instances payment repository
-func NewPaymentRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db *pgxpool.Pool) *PaymentRepositoryImpl {
+func NewPaymentRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *PaymentRepositoryImpl {
 	return &PaymentRepositoryImpl{
 		Context: ctx,
 		Config:  config,
@@ -42,11 +46,21 @@ func NewPaymentRepository(ctx context.Context, config *config.Configuration, log
 
 func (pr *PaymentRepositoryImpl) Insert(ctx context.Context, req *model.CreatePaymentRequest) (int, error) {
 	q := `
-		INSERT INTO payment (transaction_id, status, final_price) VALUES ($1,$2,$3) RETURNING id
+		INSERT INTO payment (transaction_id, status, final_price, method, channel_code, gateway) VALUES ($1,$2,$3,$4,NULLIF($5, ''),$6) RETURNING id
 	`
 
+	method := req.Method
+	if method == "" {
+		method = model.PaymentMethodEnumInvoice
+	}
+
+	gateway := req.Gateway
+	if gateway == "" {
+		gateway = model.PaymentGatewayEnumXendit
+	}
+
 	var paymentID int
-	row := pr.DB.QueryRow(ctx, q, req.TransactionID, model.PaymentStatusEnumPending, req.FinalPrice)
+	row := pr.DB.QueryRow(ctx, q, req.TransactionID, model.PaymentStatusEnumPending, req.FinalPrice, method, req.ChannelCode, gateway)
 	err := row.Scan(
 		&paymentID,
 	)
@@ -74,9 +88,9 @@ func (pr *PaymentRepositoryImpl) UpdateByID(ctx context.Context, req model.Payme
 
 		if value.Interface() != reflect.Zero(field.Type).Interface() {
 			fieldName := field.Tag.Get("db")
-			if fieldName == "completed_at" {
-				formattedPaidAt := req.CompletedAt.In(helper.TimezoneJakarta).Format("2006-01-02 15:04:05.999999-07:00")
-				q += fmt.Sprintf(`, "%s"='%v'`, fieldName, formattedPaidAt)
+			if t, ok := value.Interface().(*time.Time); ok {
+				formattedTime := t.In(helper.TimezoneJakarta).Format("2006-01-02 15:04:05.999999-07:00")
+				q += fmt.Sprintf(`, "%s"='%v'`, fieldName, formattedTime)
 			} else {
 				q += fmt.Sprintf(`, "%s"='%v'`, fieldName, value.Interface())
 			}
@@ -105,6 +119,10 @@ func (pr *PaymentRepositoryImpl) GetByID(ctx context.Context, id int) (*model.Pa
 			completed_at,
 			status,
 			final_price,
+			method,
+			channel_code,
+			gateway,
+			expires_at,
 			created_at,
 			updated_at
 		FROM
@@ -122,6 +140,10 @@ func (pr *PaymentRepositoryImpl) GetByID(ctx context.Context, id int) (*model.Pa
 		&payment.CompletedAt,
 		&payment.Status,
 		&payment.FinalPrice,
+		&payment.Method,
+		&payment.ChannelCode,
+		&payment.Gateway,
+		&payment.ExpiresAt,
 		&payment.CreatedAt,
 		&payment.UpdatedAt,
 	)
@@ -134,6 +156,54 @@ func (pr *PaymentRepositoryImpl) GetByID(ctx context.Context, id int) (*model.Pa
 	return &payment, nil
 }
 
+// GetByIDForUpdate get payment by id and lock the row until the running transaction end, must be called inside unit of work
+func (pr *PaymentRepositoryImpl) GetByIDForUpdate(ctx context.Context, id int) (*model.Payment, error) {
+	q := `
+		SELECT
+			id,
+			transaction_id,
+			COALESCE(partner_id, ''),
+			completed_at,
+			status,
+			final_price,
+			method,
+			channel_code,
+			gateway,
+			expires_at,
+			created_at,
+			updated_at
+		FROM
+			payment
+		WHERE
+			id = $1
+		FOR UPDATE
+	`
+
+	payment := model.Payment{}
+	row := pr.DB.QueryRow(ctx, q, id)
+	err := row.Scan(
+		&payment.ID,
+		&payment.TransactionID,
+		&payment.PartnerID,
+		&payment.CompletedAt,
+		&payment.Status,
+		&payment.FinalPrice,
+		&payment.Method,
+		&payment.ChannelCode,
+		&payment.Gateway,
+		&payment.ExpiresAt,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+	if err != nil {
+		pr.Logger.Error("PaymentRepositoryImpl.GetByIDForUpdate QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
 func (pr *PaymentRepositoryImpl) GetByPartnerID(ctx context.Context, partnerID string) (*model.Payment, error) {
 	q := `
 		SELECT
@@ -143,6 +213,10 @@ func (pr *PaymentRepositoryImpl) GetByPartnerID(ctx context.Context, partnerID s
 			completed_at,
 			status,
 			final_price,
+			method,
+			channel_code,
+			gateway,
+			expires_at,
 			created_at,
 			updated_at
 		FROM
@@ -160,6 +234,10 @@ func (pr *PaymentRepositoryImpl) GetByPartnerID(ctx context.Context, partnerID s
 		&payment.CompletedAt,
 		&payment.Status,
 		&payment.FinalPrice,
+		&payment.Method,
+		&payment.ChannelCode,
+		&payment.Gateway,
+		&payment.ExpiresAt,
 		&payment.CreatedAt,
 		&payment.UpdatedAt,
 	)
@@ -171,3 +249,130 @@ func (pr *PaymentRepositoryImpl) GetByPartnerID(ctx context.Context, partnerID s
 
 	return &payment, nil
 }
+
+// GetByTransactionID get payment of transaction, partner id is empty when invoice is not created yet
+func (pr *PaymentRepositoryImpl) GetByTransactionID(ctx context.Context, transactionID int) (*model.Payment, error) {
+	q := `
+		SELECT
+			id,
+			transaction_id,
+			COALESCE(partner_id, ''),
+			completed_at,
+			status,
+			final_price,
+			method,
+			channel_code,
+			gateway,
+			expires_at,
+			created_at,
+			updated_at
+		FROM
+			payment
+		WHERE
+			transaction_id = $1
+	`
+
+	payment := model.Payment{}
+	row := pr.DB.QueryRow(ctx, q, transactionID)
+	err := row.Scan(
+		&payment.ID,
+		&payment.TransactionID,
+		&payment.PartnerID,
+		&payment.CompletedAt,
+		&payment.Status,
+		&payment.FinalPrice,
+		&payment.Method,
+		&payment.ChannelCode,
+		&payment.Gateway,
+		&payment.ExpiresAt,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+	if err != nil {
+		pr.Logger.Error("PaymentRepositoryImpl.GetByTransactionID QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// GetByStatusUpdatedBefore get payments with given status that is not updated since the given time, the payment that is never
+// reconciled or reconciled longest ago come first, so payments still open on partner don't keep the rest out of the batch
+func (pr *PaymentRepositoryImpl) GetByStatusUpdatedBefore(ctx context.Context, status model.PaymentStatusEnum, updatedBefore time.Time, limit int) ([]model.Payment, error) {
+	q := `
+		SELECT
+			id,
+			transaction_id,
+			COALESCE(partner_id, ''),
+			completed_at,
+			status,
+			final_price,
+			method,
+			channel_code,
+			gateway,
+			expires_at,
+			created_at,
+			updated_at
+		FROM
+			payment
+		WHERE
+			status = $1 AND COALESCE(updated_at, created_at) < $2
+		ORDER BY
+			last_reconciled_at NULLS FIRST, COALESCE(updated_at, created_at)
+		LIMIT $3
+	`
+
+	payments := []model.Payment{}
+
+	rows, err := pr.DB.Query(ctx, q, status, updatedBefore, limit)
+	if err != nil {
+		pr.Logger.Error("PaymentRepositoryImpl.GetByStatusUpdatedBefore Query ERROR", err)
+
+		return []model.Payment{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		payment := model.Payment{}
+		err := rows.Scan(
+			&payment.ID,
+			&payment.TransactionID,
+			&payment.PartnerID,
+			&payment.CompletedAt,
+			&payment.Status,
+			&payment.FinalPrice,
+			&payment.Method,
+			&payment.ChannelCode,
+			&payment.Gateway,
+			&payment.ExpiresAt,
+			&payment.CreatedAt,
+			&payment.UpdatedAt,
+		)
+		if err != nil {
+			pr.Logger.Error("PaymentRepositoryImpl.GetByStatusUpdatedBefore rows Scan ERROR", err)
+
+			return []model.Payment{}, err
+		}
+
+		payments = append(payments, payment)
+	}
+
+	return payments, nil
+}
+
+// UpdateReconciledAtByID record the time payment is polled by reconciliation, updated_at is kept because it is the staleness of payment
+func (pr *PaymentRepositoryImpl) UpdateReconciledAtByID(ctx context.Context, at time.Time, id int) error {
+	q := `
+		UPDATE payment SET last_reconciled_at = $1 WHERE id = $2
+	`
+
+	_, err := pr.DB.Exec(ctx, q, at, id)
+	if err != nil {
+		pr.Logger.Error("PaymentRepositoryImpl.UpdateReconciledAtByID Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// PaymentEventRepository is an interface that has all the function to be implemented inside payment event repository
+	PaymentEventRepository interface {
+		InsertOrGet(ctx context.Context, req *model.PaymentEvent) (*model.PaymentEvent, error)
+		UpdateResultByID(ctx context.Context, result model.PaymentEventResultEnum, note string, id int) error
+	}
+
+	// PaymentEventRepositoryImpl is an app payment event struct that consists of all the dependencies needed for payment event repository
+	PaymentEventRepositoryImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      DBTX
+	}
+)
+
+// NewPaymentEventRepository return new instances payment event repository
+func NewPaymentEventRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *PaymentEventRepositoryImpl {
+	return &PaymentEventRepositoryImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+// InsertOrGet insert payment event, when callback id is already recorded the existing event is returned instead
+func (pr *PaymentEventRepositoryImpl) InsertOrGet(ctx context.Context, req *model.PaymentEvent) (*model.PaymentEvent, error) {
+	qInsert := `
+		INSERT INTO payment_event (callback_id, invoice_id, external_id, status, raw_payload) VALUES ($1,$2,$3,$4,$5)
+		ON CONFLICT (callback_id) DO NOTHING
+		RETURNING id, received_at
+	`
+
+	event := *req
+	row := pr.DB.QueryRow(ctx, qInsert, req.CallbackID, req.InvoiceID, req.ExternalID, req.Status, string(req.RawPayload))
+	err := row.Scan(
+		&event.ID,
+		&event.ReceivedAt,
+	)
+	if err == nil {
+		return &event, nil
+	}
+
+	if err.Error() != pgx.ErrNoRows.Error() {
+		pr.Logger.Error("PaymentEventRepositoryImpl.InsertOrGet Insert QueryRow Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return pr.getByCallbackID(ctx, req.CallbackID)
+}
+
+func (pr *PaymentEventRepositoryImpl) getByCallbackID(ctx context.Context, callbackID string) (*model.PaymentEvent, error) {
+	q := `
+		SELECT
+			id,
+			callback_id,
+			invoice_id,
+			external_id,
+			status,
+			raw_payload,
+			result,
+			note,
+			received_at,
+			processed_at
+		FROM
+			payment_event
+		WHERE
+			callback_id = $1
+	`
+
+	event := model.PaymentEvent{}
+	row := pr.DB.QueryRow(ctx, q, callbackID)
+	err := row.Scan(
+		&event.ID,
+		&event.CallbackID,
+		&event.InvoiceID,
+		&event.ExternalID,
+		&event.Status,
+		&event.RawPayload,
+		&event.Result,
+		&event.Note,
+		&event.ReceivedAt,
+		&event.ProcessedAt,
+	)
+	if err != nil {
+		pr.Logger.Error("PaymentEventRepositoryImpl.getByCallbackID QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// UpdateResultByID mark payment event as processed with its result, event that is already processed is left untouched
+func (pr *PaymentEventRepositoryImpl) UpdateResultByID(ctx context.Context, result model.PaymentEventResultEnum, note string, id int) error {
+	q := `
+		UPDATE payment_event SET result = $1, note = NULLIF($2, ''), processed_at = NOW() WHERE id = $3 AND processed_at IS NULL
+	`
+
+	_, err := pr.DB.Exec(ctx, q, result, note, id)
+	if err != nil {
+		pr.Logger.Error("PaymentEventRepositoryImpl.UpdateResultByID Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
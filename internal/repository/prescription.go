@@ -2,22 +2,26 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
 	"e-resep-be/internal/config"
 	"e-resep-be/internal/helper"
 	"e-resep-be/internal/model"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
 type (
 	// PrescriptionRepository is an interface that has all the function to be implemented inside health check repository
 	PrescriptionRepository interface {
-		Insert(ctx context.Context, req *model.PrescriptionRequest, phoneNumber string) error
+		Upsert(ctx context.Context, req *model.PrescriptionRequest, phoneNumber, email string) (model.PrescriptionUpsertResultEnum, error)
 		GetByPrescriptionID(ctx context.Context, id string) ([]model.Prescription, error)
 	}
 
@@ -26,12 +30,12 @@ type (
 		Context context.Context
 		Config  *config.Configuration
 		Logger  *logrus.Logger
-		DB      *pgxpool.Pool
+		DB      DBTX
 	}
 )
 
 // NewPrescriptionRepository return new instances prescription repository
-func NewPrescriptionRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db *pgxpool.Pool) *PrescriptionRepositoryImpl {
+func NewPrescriptionRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *PrescriptionRepositoryImpl {
 	return &PrescriptionRepositoryImpl{
 		Context: ctx,
 		Config:  config,
@@ -40,33 +44,94 @@ func NewPrescriptionRepository(ctx context.Context, config *config.Configuration
 	}
 }
 
-func (pr *PrescriptionRepositoryImpl) Insert(ctx context.Context, req *model.PrescriptionRequest, phoneNumber string) error {
-	qInsertMedication := `
-		INSERT INTO medication (ref_id,identifier,code,code_display,form_code,form_value,amount,status,manufacturer,extension,batch) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING id
+// Upsert store prescription item keyed on FHIR id of medication and medication request.
+// Stored resource is only overwritten when meta.versionId differ and meta.lastUpdated is not older, resource without
+// meta.versionId is compared by its content instead, so posting the same version or content again leave it unchanged. Medication request of other FHIR id with the same prescription item id is a conflict
+func (pr *PrescriptionRepositoryImpl) Upsert(ctx context.Context, req *model.PrescriptionRequest, phoneNumber, email string) (model.PrescriptionUpsertResultEnum, error) {
+	qUpsertMedication := `
+		INSERT INTO medication (ref_id,identifier,code,code_display,form_code,form_value,amount,status,manufacturer,extension,batch,version_id,last_updated,content_hash) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,NULLIF($12, ''),$13,$14)
+		ON CONFLICT (ref_id) DO UPDATE SET
+			identifier = EXCLUDED.identifier,
+			code = EXCLUDED.code,
+			code_display = EXCLUDED.code_display,
+			form_code = EXCLUDED.form_code,
+			form_value = EXCLUDED.form_value,
+			amount = EXCLUDED.amount,
+			status = EXCLUDED.status,
+			manufacturer = EXCLUDED.manufacturer,
+			extension = EXCLUDED.extension,
+			batch = EXCLUDED.batch,
+			version_id = EXCLUDED.version_id,
+			last_updated = EXCLUDED.last_updated,
+			content_hash = EXCLUDED.content_hash,
+			updated_at = NOW()
+		WHERE
+			(
+				medication.version_id IS DISTINCT FROM EXCLUDED.version_id
+			OR
+				(EXCLUDED.version_id IS NULL AND medication.content_hash IS DISTINCT FROM EXCLUDED.content_hash)
+			)
+		AND
+			(medication.last_updated IS NULL OR EXCLUDED.last_updated IS NULL OR EXCLUDED.last_updated >= medication.last_updated)
+		RETURNING id, (xmax = 0) AS inserted
 	`
-	qInsertMedicationIngredients := `
-		INSERT INTO medication_ingredient (
-			medication_id,code,display,is_active,strength_denominator,strength_numerator
-		) VALUES %s
+	qGetMedicationID := `
+		SELECT id FROM medication WHERE ref_id = $1
+	`
+	qDeleteMedicationIngredients := `
+		DELETE FROM medication_ingredient WHERE medication_id = $1
 	`
 	qCheckPatient := `
 		SELECT id FROM patient WHERE ref_id = $1
 	`
 	qInsertPatient := `
-		INSERT INTO patient (ref_id, name, phone_number) VALUES ($1, $2, $3) RETURNING id;
+		INSERT INTO patient (ref_id, name, phone_number, email) VALUES ($1, $2, $3, NULLIF($4, '')) RETURNING id;
 	`
-	qInsertMedicationRequest := `
-		INSERT INTO medication_request (medication_id,ref_id,status,patient_id,prescription_id,prescription_item_id,reason,intent,category,reported,encounter,requester,performer,recorder,note,insurance,course_of_therapy_type,dosage_instructions,dispense_request,substitution,raw_request) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21) RETURNING id
+	qUpsertMedicationRequest := `
+		INSERT INTO medication_request (medication_id,ref_id,status,patient_id,prescription_id,prescription_item_id,reason,intent,category,reported,encounter,requester,performer,recorder,note,insurance,course_of_therapy_type,dosage_instructions,dispense_request,substitution,raw_request,version_id,last_updated,content_hash) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,NULLIF($22, ''),$23,$24)
+		ON CONFLICT (ref_id) DO UPDATE SET
+			medication_id = EXCLUDED.medication_id,
+			status = EXCLUDED.status,
+			patient_id = EXCLUDED.patient_id,
+			prescription_id = EXCLUDED.prescription_id,
+			prescription_item_id = EXCLUDED.prescription_item_id,
+			reason = EXCLUDED.reason,
+			intent = EXCLUDED.intent,
+			category = EXCLUDED.category,
+			encounter = EXCLUDED.encounter,
+			requester = EXCLUDED.requester,
+			performer = EXCLUDED.performer,
+			recorder = EXCLUDED.recorder,
+			note = EXCLUDED.note,
+			insurance = EXCLUDED.insurance,
+			course_of_therapy_type = EXCLUDED.course_of_therapy_type,
+			dosage_instructions = EXCLUDED.dosage_instructions,
+			dispense_request = EXCLUDED.dispense_request,
+			substitution = EXCLUDED.substitution,
+			raw_request = EXCLUDED.raw_request,
+			version_id = EXCLUDED.version_id,
+			last_updated = EXCLUDED.last_updated,
+			content_hash = EXCLUDED.content_hash,
+			updated_at = NOW()
+		WHERE
+			(
+				medication_request.version_id IS DISTINCT FROM EXCLUDED.version_id
+			OR
+				(EXCLUDED.version_id IS NULL AND medication_request.content_hash IS DISTINCT FROM EXCLUDED.content_hash)
+			)
+		AND
+			(medication_request.last_updated IS NULL OR EXCLUDED.last_updated IS NULL OR EXCLUDED.last_updated >= medication_request.last_updated)
+		RETURNING id, (xmax = 0) AS inserted
 	`
 
 	tx, err := pr.DB.Begin(ctx)
 	if err != nil {
-		pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR begin TX", err)
+		pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR begin TX", err)
 
-		return err
+		return "", err
 	}
 
-	// INSERT MEDICATION
+	// UPSERT MEDICATION
 
 	amountJsonData, err := json.Marshal(req.Medication.Amount)
 	if err != nil {
@@ -82,18 +147,36 @@ func (pr *PrescriptionRepositoryImpl) Insert(ctx context.Context, req *model.Pre
 	if err != nil {
 		errRollback := tx.Rollback(ctx)
 		if errRollback != nil {
-			pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR rollback TX", errRollback)
+			pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
 
-			return errRollback
+			return "", errRollback
 		}
 
-		pr.Logger.Error("PrescriptionRepositoryImpl.Insert json marshal ERROR", err)
+		pr.Logger.Error("PrescriptionRepositoryImpl.Upsert json marshal ERROR", err)
 
-		return err
+		return "", err
 	}
 
-	var medicationID int
-	row := tx.QueryRow(ctx, qInsertMedication,
+	medicationJsonData, err := json.Marshal(req.Medication)
+	if err != nil {
+		errRollback := tx.Rollback(ctx)
+		if errRollback != nil {
+			pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
+
+			return "", errRollback
+		}
+
+		pr.Logger.Error("PrescriptionRepositoryImpl.Upsert json marshal ERROR", err)
+
+		return "", err
+	}
+
+	var (
+		medicationID      int
+		medicationCreated bool
+		medicationChanged = true
+	)
+	row := tx.QueryRow(ctx, qUpsertMedication,
 		req.Medication.ID,
 		req.Medication.Identifier[0].Value,
 		req.Medication.Code.Coding[0].Code,
@@ -105,45 +188,63 @@ func (pr *PrescriptionRepositoryImpl) Insert(ctx context.Context, req *model.Pre
 		req.Medication.Manufacturer.Reference,
 		string(extJsonData),
 		string(batchJsonData),
+		req.Medication.Meta.VersionID,
+		nullableTime(req.Medication.Meta.LastUpdated),
+		contentHash(medicationJsonData),
 	)
 
-	err = row.Scan(&medicationID)
+	err = row.Scan(&medicationID, &medicationCreated)
+	if err != nil && err.Error() == pgx.ErrNoRows.Error() { // stored medication is the same or newer version
+		medicationChanged = false
+
+		row = tx.QueryRow(ctx, qGetMedicationID, req.Medication.ID)
+		err = row.Scan(&medicationID)
+	}
 	if err != nil {
 		errRollback := tx.Rollback(ctx)
 		if errRollback != nil {
-			pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR rollback TX", errRollback)
+			pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
 
-			return errRollback
+			return "", errRollback
 		}
 
-		pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR Scan Insert Medication", err)
+		pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR Scan Upsert Medication", err)
 
-		return err
+		return "", err
 	}
 
-	// INSERT BULK MEDICATION INGREDIENTS
+	// REPLACE MEDICATION INGREDIENTS
 
-	numberArgsPerRowMedicationIngredients := 6
-	valueArgsMedicationIngredients := make([]interface{}, 0, numberArgsPerRowMedicationIngredients*len(req.Medication.Ingredient))
+	if medicationChanged {
+		if !medicationCreated {
+			_, err = tx.Exec(ctx, qDeleteMedicationIngredients, medicationID)
+			if err != nil {
+				errRollback := tx.Rollback(ctx)
+				if errRollback != nil {
+					pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
 
-	for i := 0; i < len(req.Medication.Ingredient); i++ {
-		valueArgsMedicationIngredients = append(valueArgsMedicationIngredients, medicationID, req.Medication.Ingredient[i].ItemCodeableConcept.Coding[0].Code, req.Medication.Ingredient[i].ItemCodeableConcept.Coding[0].Display, req.Medication.Ingredient[i].IsActive, fmt.Sprintf("%2.f %s", req.Medication.Ingredient[i].Strength.Denominator.Value, req.Medication.Ingredient[i].Strength.Denominator.Code), fmt.Sprintf("%2.f %s", req.Medication.Ingredient[i].Strength.Numerator.Value, req.Medication.Ingredient[i].Strength.Numerator.Code))
-	}
+					return "", errRollback
+				}
 
-	qInsertMedicationIngredients = helper.BulkInsert(qInsertMedicationIngredients, numberArgsPerRowMedicationIngredients, len(req.Medication.Ingredient))
+				pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR Exec Delete Medication Ingredients", err)
 
-	_, err = tx.Exec(ctx, qInsertMedicationIngredients, valueArgsMedicationIngredients...)
-	if err != nil {
-		errRollback := tx.Rollback(ctx)
-		if errRollback != nil {
-			pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR rollback TX", errRollback)
-
-			return errRollback
+				return "", err
+			}
 		}
 
-		pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR Exec Insert Bulk Medication Ingredients", err)
+		err = pr.insertMedicationIngredients(ctx, tx, medicationID, req.Medication.Ingredient)
+		if err != nil {
+			errRollback := tx.Rollback(ctx)
+			if errRollback != nil {
+				pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
+
+				return "", errRollback
+			}
 
-		return err
+			pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR Exec Insert Bulk Medication Ingredients", err)
+
+			return "", err
+		}
 	}
 
 	// INSERT PATIENT
@@ -159,36 +260,36 @@ func (pr *PrescriptionRepositoryImpl) Insert(ctx context.Context, req *model.Pre
 	if err != nil && err.Error() != pgx.ErrNoRows.Error() { // exclude ErrNoRows first, for clean handling purposes
 		errRollback := tx.Rollback(ctx)
 		if errRollback != nil {
-			pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR rollback TX", errRollback)
+			pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
 
-			return errRollback
+			return "", errRollback
 		}
 
-		pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR Scan Check Patient", err)
+		pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR Scan Check Patient", err)
 
-		return err
+		return "", err
 	}
 
 	// check error again, if ref id patient is not exists, then create patient
 	if err != nil && err.Error() == pgx.ErrNoRows.Error() {
-		row = tx.QueryRow(ctx, qInsertPatient, trimPatientRefId, req.MedicationRequest.Subject.Display, phoneNumber)
+		row = tx.QueryRow(ctx, qInsertPatient, trimPatientRefId, req.MedicationRequest.Subject.Display, phoneNumber, email)
 
 		err = row.Scan(&patientID)
 		if err != nil {
 			errRollback := tx.Rollback(ctx)
 			if errRollback != nil {
-				pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR rollback TX", errRollback)
+				pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
 
-				return errRollback
+				return "", errRollback
 			}
 
-			pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR Scan Insert Patient", err)
+			pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR Scan Insert Patient", err)
 
-			return err
+			return "", err
 		}
 	}
 
-	// INSERT MEDICATION REQUEST
+	// UPSERT MEDICATION REQUEST
 
 	noteJsonData, err := json.Marshal(req.MedicationRequest.Note)
 	if err != nil {
@@ -204,26 +305,26 @@ func (pr *PrescriptionRepositoryImpl) Insert(ctx context.Context, req *model.Pre
 	if err != nil {
 		errRollback := tx.Rollback(ctx)
 		if errRollback != nil {
-			pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR rollback TX", errRollback)
+			pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
 
-			return errRollback
+			return "", errRollback
 		}
-		pr.Logger.Error("PrescriptionRepositoryImpl.Insert json marshal ERROR", err)
+		pr.Logger.Error("PrescriptionRepositoryImpl.Upsert json marshal ERROR", err)
 
-		return err
+		return "", err
 	}
 
 	dispenseRequestJsonData, err := json.Marshal(req.MedicationRequest.DispenseRequest)
 	if err != nil {
 		errRollback := tx.Rollback(ctx)
 		if errRollback != nil {
-			pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR rollback TX", errRollback)
+			pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
 
-			return errRollback
+			return "", errRollback
 		}
-		pr.Logger.Error("PrescriptionRepositoryImpl.Insert json marshal ERROR", err)
+		pr.Logger.Error("PrescriptionRepositoryImpl.Upsert json marshal ERROR", err)
 
-		return err
+		return "", err
 	}
 
 	substitutionJsonData, err := json.Marshal(req.MedicationRequest.Substitution)
@@ -235,17 +336,21 @@ func (pr *PrescriptionRepositoryImpl) Insert(ctx context.Context, req *model.Pre
 	if err != nil {
 		errRollback := tx.Rollback(ctx)
 		if errRollback != nil {
-			pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR rollback TX", errRollback)
+			pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
 
-			return errRollback
+			return "", errRollback
 		}
-		pr.Logger.Error("PrescriptionRepositoryImpl.Insert json marshal ERROR", err)
+		pr.Logger.Error("PrescriptionRepositoryImpl.Upsert json marshal ERROR", err)
 
-		return err
+		return "", err
 	}
 
-	var medicationRequestID int
-	row = tx.QueryRow(ctx, qInsertMedicationRequest,
+	var (
+		medicationRequestID      int
+		medicationRequestCreated bool
+		medicationRequestChanged = true
+	)
+	row = tx.QueryRow(ctx, qUpsertMedicationRequest,
 		medicationID,
 		req.MedicationRequest.ID,
 		req.MedicationRequest.Status,
@@ -267,37 +372,94 @@ func (pr *PrescriptionRepositoryImpl) Insert(ctx context.Context, req *model.Pre
 		string(dispenseRequestJsonData),
 		string(substitutionJsonData),
 		string(rawRequestJsonData),
+		req.MedicationRequest.Meta.VersionID,
+		nullableTime(req.MedicationRequest.Meta.LastUpdated),
+		contentHash(rawRequestJsonData),
 	)
 
-	err = row.Scan(&medicationRequestID)
+	err = row.Scan(&medicationRequestID, &medicationRequestCreated)
+	if err != nil && err.Error() == pgx.ErrNoRows.Error() { // stored medication request is the same or newer version
+		medicationRequestChanged = false
+		err = nil
+	}
 	if err != nil {
 		errRollback := tx.Rollback(ctx)
 		if errRollback != nil {
-			pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR rollback TX", errRollback)
+			pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
 
-			return errRollback
+			return "", errRollback
 		}
 
-		pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR Scan Insert Medication Request", err)
+		pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR Scan Upsert Medication Request", err)
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return "", model.NewError(model.Conflict, fmt.Sprintf("prescription item %s is already stored for other medication request", req.MedicationRequest.Identifier[1].Value))
+		}
 
-		return err
+		return "", err
 	}
 
 	err = tx.Commit(ctx)
 	if err != nil {
 		errRollback := tx.Rollback(ctx)
 		if errRollback != nil {
-			pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR rollback TX", errRollback)
+			pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR rollback TX", errRollback)
 
-			return errRollback
+			return "", errRollback
 		}
 
-		pr.Logger.Error("PrescriptionRepositoryImpl.Insert ERROR commit TX", err)
+		pr.Logger.Error("PrescriptionRepositoryImpl.Upsert ERROR commit TX", err)
 
-		return err
+		return "", err
 	}
 
-	return nil
+	switch {
+	case medicationRequestCreated:
+		return model.PrescriptionUpsertResultEnumCreated, nil
+	case medicationRequestChanged || medicationChanged:
+		return model.PrescriptionUpsertResultEnumUpdated, nil
+	default:
+		return model.PrescriptionUpsertResultEnumUnchanged, nil
+	}
+}
+
+// insertMedicationIngredients insert ingredients of medication in one statement
+func (pr *PrescriptionRepositoryImpl) insertMedicationIngredients(ctx context.Context, tx pgx.Tx, medicationID int, ingredients []model.Ingredient) error {
+	qInsertMedicationIngredients := `
+		INSERT INTO medication_ingredient (
+			medication_id,code,display,is_active,strength_denominator,strength_numerator
+		) VALUES %s
+	`
+
+	numberArgsPerRowMedicationIngredients := 6
+	valueArgsMedicationIngredients := make([]interface{}, 0, numberArgsPerRowMedicationIngredients*len(ingredients))
+
+	for i := 0; i < len(ingredients); i++ {
+		valueArgsMedicationIngredients = append(valueArgsMedicationIngredients, medicationID, ingredients[i].ItemCodeableConcept.Coding[0].Code, ingredients[i].ItemCodeableConcept.Coding[0].Display, ingredients[i].IsActive, fmt.Sprintf("%2.f %s", ingredients[i].Strength.Denominator.Value, ingredients[i].Strength.Denominator.Code), fmt.Sprintf("%2.f %s", ingredients[i].Strength.Numerator.Value, ingredients[i].Strength.Numerator.Code))
+	}
+
+	qInsertMedicationIngredients = helper.BulkInsert(qInsertMedicationIngredients, numberArgsPerRowMedicationIngredients, len(ingredients))
+
+	_, err := tx.Exec(ctx, qInsertMedicationIngredients, valueArgsMedicationIngredients...)
+
+	return err
+}
+
+// nullableTime return nil for zero time, so absent FHIR meta.lastUpdated is stored as NULL
+func nullableTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// contentHash return hash of json encoded FHIR resource, it tells a changed resource from a resubmitted one when meta.versionId isn't sent
+func contentHash(b []byte) string {
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
 }
 
 func (pr *PrescriptionRepositoryImpl) GetByPrescriptionID(ctx context.Context, id string) ([]model.Prescription, error) {
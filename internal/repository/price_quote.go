@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"encoding/json"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// PriceQuoteRepository is an interface that has all the function to be implemented inside price quote repository
+	PriceQuoteRepository interface {
+		Insert(ctx context.Context, req *model.PriceQuote) (int, error)
+		GetByID(ctx context.Context, id int) (*model.PriceQuote, error)
+		ConsumeByID(ctx context.Context, id, transactionID int) error
+	}
+
+	// PriceQuoteRepositoryImpl is an app price quote struct that consists of all the dependencies needed for price quote repository
+	PriceQuoteRepositoryImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      DBTX
+	}
+)
+
+// NewPriceQuoteRepository return new instances price quote repository
+func NewPriceQuoteRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *PriceQuoteRepositoryImpl {
+	return &PriceQuoteRepositoryImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+func (pr *PriceQuoteRepositoryImpl) Insert(ctx context.Context, req *model.PriceQuote) (int, error) {
+	q := `
+		INSERT INTO price_quote (patient_id, patient_address_id, items, shipping_cost, total_price, expires_at) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id
+	`
+
+	itemsJsonData, err := json.Marshal(req.Items)
+	if err != nil {
+		pr.Logger.Error("PriceQuoteRepositoryImpl.Insert json marshal ERROR", err)
+
+		return 0, err
+	}
+
+	var quoteID int
+	row := pr.DB.QueryRow(ctx, q, req.PatientID, req.PatientAddressID, string(itemsJsonData), req.ShippingCost, req.TotalPrice, req.ExpiresAt)
+	err = row.Scan(
+		&quoteID,
+	)
+	if err != nil {
+		pr.Logger.Error("PriceQuoteRepositoryImpl.Insert QueryRow Scan ERROR", err)
+
+		return 0, err
+	}
+
+	return quoteID, nil
+}
+
+func (pr *PriceQuoteRepositoryImpl) GetByID(ctx context.Context, id int) (*model.PriceQuote, error) {
+	q := `
+		SELECT
+			id,
+			patient_id,
+			patient_address_id,
+			items,
+			shipping_cost,
+			total_price,
+			expires_at,
+			transaction_id,
+			consumed_at,
+			created_at
+		FROM
+			price_quote
+		WHERE
+			id = $1
+	`
+
+	var (
+		quote         = model.PriceQuote{}
+		itemsJsonData []byte
+	)
+
+	row := pr.DB.QueryRow(ctx, q, id)
+	err := row.Scan(
+		&quote.ID,
+		&quote.PatientID,
+		&quote.PatientAddressID,
+		&itemsJsonData,
+		&quote.ShippingCost,
+		&quote.TotalPrice,
+		&quote.ExpiresAt,
+		&quote.TransactionID,
+		&quote.ConsumedAt,
+		&quote.CreatedAt,
+	)
+	if err != nil {
+		pr.Logger.Error("PriceQuoteRepositoryImpl.GetByID QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	err = json.Unmarshal(itemsJsonData, &quote.Items)
+	if err != nil {
+		pr.Logger.Error("PriceQuoteRepositoryImpl.GetByID json unmarshal ERROR", err)
+
+		return nil, err
+	}
+
+	return &quote, nil
+}
+
+// ConsumeByID mark quote as used by the transaction, the row stay locked until the running transaction end.
+// Quote that is already consumed is a conflict, so one quote can't back two transactions
+func (pr *PriceQuoteRepositoryImpl) ConsumeByID(ctx context.Context, id, transactionID int) error {
+	q := `
+		UPDATE price_quote SET transaction_id = $1, consumed_at = NOW() WHERE id = $2 AND consumed_at IS NULL
+	`
+
+	tag, err := pr.DB.Exec(ctx, q, transactionID, id)
+	if err != nil {
+		pr.Logger.Error("PriceQuoteRepositoryImpl.ConsumeByID Exec ERROR", err)
+
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return model.NewError(model.Conflict, "price quote is already used, please regenerate payment info")
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// ReconciliationDiscrepancyRepository is an interface that has all the function to be implemented inside reconciliation discrepancy repository
+	ReconciliationDiscrepancyRepository interface {
+		Insert(ctx context.Context, req *model.ReconciliationDiscrepancy) (int, error)
+		Get(ctx context.Context, limit, offset int) ([]model.ReconciliationDiscrepancy, error)
+		Count(ctx context.Context) (int, error)
+	}
+
+	// ReconciliationDiscrepancyRepositoryImpl is an app reconciliation discrepancy struct that consists of all the dependencies needed for reconciliation discrepancy repository
+	ReconciliationDiscrepancyRepositoryImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      DBTX
+	}
+)
+
+// NewReconciliationDiscrepancyRepository return new instances reconciliation discrepancy repository
+func NewReconciliationDiscrepancyRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *ReconciliationDiscrepancyRepositoryImpl {
+	return &ReconciliationDiscrepancyRepositoryImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+func (rr *ReconciliationDiscrepancyRepositoryImpl) Insert(ctx context.Context, req *model.ReconciliationDiscrepancy) (int, error) {
+	q := `
+		INSERT INTO reconciliation_discrepancy (payment_id, transaction_id, invoice_id, payment_status, invoice_status, result, note) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id
+	`
+
+	var discrepancyID int
+	row := rr.DB.QueryRow(ctx, q, req.PaymentID, req.TransactionID, req.InvoiceID, req.PaymentStatus, req.InvoiceStatus, req.Result, req.Note)
+	err := row.Scan(
+		&discrepancyID,
+	)
+	if err != nil {
+		rr.Logger.Error("ReconciliationDiscrepancyRepositoryImpl.Insert QueryRow Scan ERROR", err)
+
+		return 0, err
+	}
+
+	return discrepancyID, nil
+}
+
+// Get get discrepancies ordered from the newest one
+func (rr *ReconciliationDiscrepancyRepositoryImpl) Get(ctx context.Context, limit, offset int) ([]model.ReconciliationDiscrepancy, error) {
+	q := `
+		SELECT
+			id,
+			payment_id,
+			transaction_id,
+			invoice_id,
+			payment_status,
+			invoice_status,
+			result,
+			note,
+			created_at
+		FROM
+			reconciliation_discrepancy
+		ORDER BY
+			id DESC
+		LIMIT $1 OFFSET $2
+	`
+
+	discrepancies := []model.ReconciliationDiscrepancy{}
+
+	rows, err := rr.DB.Query(ctx, q, limit, offset)
+	if err != nil {
+		rr.Logger.Error("ReconciliationDiscrepancyRepositoryImpl.Get Query ERROR", err)
+
+		return []model.ReconciliationDiscrepancy{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		discrepancy := model.ReconciliationDiscrepancy{}
+		err := rows.Scan(
+			&discrepancy.ID,
+			&discrepancy.PaymentID,
+			&discrepancy.TransactionID,
+			&discrepancy.InvoiceID,
+			&discrepancy.PaymentStatus,
+			&discrepancy.InvoiceStatus,
+			&discrepancy.Result,
+			&discrepancy.Note,
+			&discrepancy.CreatedAt,
+		)
+		if err != nil {
+			rr.Logger.Error("ReconciliationDiscrepancyRepositoryImpl.Get rows Scan ERROR", err)
+
+			return []model.ReconciliationDiscrepancy{}, err
+		}
+
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	return discrepancies, nil
+}
+
+func (rr *ReconciliationDiscrepancyRepositoryImpl) Count(ctx context.Context) (int, error) {
+	q := `
+		SELECT COUNT(id) FROM reconciliation_discrepancy
+	`
+
+	var total int
+	err := rr.DB.QueryRow(ctx, q).Scan(&total)
+	if err != nil {
+		rr.Logger.Error("ReconciliationDiscrepancyRepositoryImpl.Count QueryRow.Scan ERROR", err)
+
+		return 0, err
+	}
+
+	return total, nil
+}
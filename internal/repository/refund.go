@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/helper"
+	"e-resep-be/internal/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// RefundRepository is an interface that has all the function to be implemented inside refund repository
+	RefundRepository interface {
+		Insert(ctx context.Context, req *model.Refund) (int, error)
+		GetByIDForUpdate(ctx context.Context, id int) (*model.Refund, error)
+		GetDetailIDsByTransactionID(ctx context.Context, transactionID int, statuses []model.RefundStatusEnum) ([]int, error)
+		GetUnacknowledgedUpdatedBefore(ctx context.Context, updatedBefore time.Time, limit int) ([]model.Refund, error)
+		UpdatePartnerIDByID(ctx context.Context, partnerID string, id int) error
+		UpdateStatusByID(ctx context.Context, status model.RefundStatusEnum, failureCode string, id int) error
+	}
+
+	// RefundRepositoryImpl is an app refund struct that consists of all the dependencies needed for refund repository
+	RefundRepositoryImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      DBTX
+	}
+)
+
+// NewRefundRepository return new instances refund repository
+func NewRefundRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *RefundRepositoryImpl {
+	return &RefundRepositoryImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+// Insert insert refund with its details, it must be called inside unit of work so both are inserted atomically
+func (rr *RefundRepositoryImpl) Insert(ctx context.Context, req *model.Refund) (int, error) {
+	qInsertRefund := `
+		INSERT INTO refund (transaction_id, payment_id, amount, reason, status) VALUES ($1,$2,$3,$4,$5) RETURNING id
+	`
+
+	qInsertRefundDetail := `
+		INSERT INTO refund_detail (refund_id, transaction_detail_id, amount) VALUES %s
+	`
+
+	var refundID int
+	row := rr.DB.QueryRow(ctx, qInsertRefund, req.TransactionID, req.PaymentID, req.Amount, req.Reason, model.RefundStatusEnumPending)
+	err := row.Scan(
+		&refundID,
+	)
+	if err != nil {
+		rr.Logger.Error("RefundRepositoryImpl.Insert QueryRow Scan ERROR", err)
+
+		return 0, err
+	}
+
+	if len(req.Details) == 0 {
+		return refundID, nil
+	}
+
+	numberArgsPerRows := 3
+	valueArgs := make([]interface{}, 0, numberArgsPerRows*len(req.Details))
+
+	for i := 0; i < len(req.Details); i++ {
+		valueArgs = append(valueArgs, refundID, req.Details[i].TransactionDetailID, req.Details[i].Amount)
+	}
+
+	qInsertRefundDetail = helper.BulkInsert(qInsertRefundDetail, numberArgsPerRows, len(req.Details))
+
+	_, err = rr.DB.Exec(ctx, qInsertRefundDetail, valueArgs...)
+	if err != nil {
+		rr.Logger.Error("RefundRepositoryImpl.Insert ERROR Exec Insert Bulk Refund Detail", err)
+
+		return 0, err
+	}
+
+	return refundID, nil
+}
+
+// GetByIDForUpdate get refund by id and lock the row until the running transaction end, must be called inside unit of work
+func (rr *RefundRepositoryImpl) GetByIDForUpdate(ctx context.Context, id int) (*model.Refund, error) {
+	q := `
+		SELECT
+			id,
+			transaction_id,
+			payment_id,
+			partner_id,
+			amount,
+			reason,
+			status,
+			failure_code,
+			created_at,
+			updated_at
+		FROM
+			refund
+		WHERE
+			id = $1
+		FOR UPDATE
+	`
+
+	refund := model.Refund{}
+	row := rr.DB.QueryRow(ctx, q, id)
+	err := row.Scan(
+		&refund.ID,
+		&refund.TransactionID,
+		&refund.PaymentID,
+		&refund.PartnerID,
+		&refund.Amount,
+		&refund.Reason,
+		&refund.Status,
+		&refund.FailureCode,
+		&refund.CreatedAt,
+		&refund.UpdatedAt,
+	)
+	if err != nil {
+		rr.Logger.Error("RefundRepositoryImpl.GetByIDForUpdate QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return &refund, nil
+}
+
+// GetDetailIDsByTransactionID get transaction detail ids of transaction that are refunded by refund with given statuses
+func (rr *RefundRepositoryImpl) GetDetailIDsByTransactionID(ctx context.Context, transactionID int, statuses []model.RefundStatusEnum) ([]int, error) {
+	q := `
+		SELECT
+			rd.transaction_detail_id
+		FROM
+			refund_detail rd
+		JOIN
+			refund r ON r.id = rd.refund_id
+		WHERE
+			r.transaction_id = $1 AND r.status = ANY($2)
+	`
+
+	statusArgs := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		statusArgs = append(statusArgs, string(status))
+	}
+
+	ids := []int{}
+
+	rows, err := rr.DB.Query(ctx, q, transactionID, statusArgs)
+	if err != nil {
+		rr.Logger.Error("RefundRepositoryImpl.GetDetailIDsByTransactionID Query ERROR", err)
+
+		return []int{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			rr.Logger.Error("RefundRepositoryImpl.GetDetailIDsByTransactionID rows Scan ERROR", err)
+
+			return []int{}, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// GetUnacknowledgedUpdatedBefore get pending refunds that payment gateway never acknowledged, oldest first
+func (rr *RefundRepositoryImpl) GetUnacknowledgedUpdatedBefore(ctx context.Context, updatedBefore time.Time, limit int) ([]model.Refund, error) {
+	q := `
+		SELECT
+			id,
+			transaction_id,
+			payment_id,
+			partner_id,
+			amount,
+			reason,
+			status,
+			failure_code,
+			created_at,
+			updated_at
+		FROM
+			refund
+		WHERE
+			status = $1 AND partner_id IS NULL AND COALESCE(updated_at, created_at) < $2
+		ORDER BY
+			COALESCE(updated_at, created_at)
+		LIMIT $3
+	`
+
+	refunds := []model.Refund{}
+
+	rows, err := rr.DB.Query(ctx, q, model.RefundStatusEnumPending, updatedBefore, limit)
+	if err != nil {
+		rr.Logger.Error("RefundRepositoryImpl.GetUnacknowledgedUpdatedBefore Query ERROR", err)
+
+		return []model.Refund{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		refund := model.Refund{}
+		err := rows.Scan(
+			&refund.ID,
+			&refund.TransactionID,
+			&refund.PaymentID,
+			&refund.PartnerID,
+			&refund.Amount,
+			&refund.Reason,
+			&refund.Status,
+			&refund.FailureCode,
+			&refund.CreatedAt,
+			&refund.UpdatedAt,
+		)
+		if err != nil {
+			rr.Logger.Error("RefundRepositoryImpl.GetUnacknowledgedUpdatedBefore rows Scan ERROR", err)
+
+			return []model.Refund{}, err
+		}
+
+		refunds = append(refunds, refund)
+	}
+
+	return refunds, nil
+}
+
+func (rr *RefundRepositoryImpl) UpdatePartnerIDByID(ctx context.Context, partnerID string, id int) error {
+	q := `
+		UPDATE refund SET partner_id = $1, updated_at = NOW() WHERE id = $2
+	`
+
+	_, err := rr.DB.Exec(ctx, q, partnerID, id)
+	if err != nil {
+		rr.Logger.Error("RefundRepositoryImpl.UpdatePartnerIDByID Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (rr *RefundRepositoryImpl) UpdateStatusByID(ctx context.Context, status model.RefundStatusEnum, failureCode string, id int) error {
+	q := `
+		UPDATE refund SET status = $1, failure_code = NULLIF($2, ''), updated_at = NOW() WHERE id = $3
+	`
+
+	_, err := rr.DB.Exec(ctx, q, status, failureCode, id)
+	if err != nil {
+		rr.Logger.Error("RefundRepositoryImpl.UpdateStatusByID Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
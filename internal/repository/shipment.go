@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// ShipmentRepository is an interface that has all the function to be implemented inside shipment repository
+	ShipmentRepository interface {
+		Insert(ctx context.Context, req *model.Shipment) (int, error)
+		GetByTransactionID(ctx context.Context, transactionID int) (*model.Shipment, error)
+		GetByPartnerIDForUpdate(ctx context.Context, partnerID string) (*model.Shipment, error)
+		UpdateCourierStatusByID(ctx context.Context, status model.CourierStatusEnum, trackingNumber string, courierUpdatedAt time.Time, id int) error
+	}
+
+	// ShipmentRepositoryImpl is an app shipment struct that consists of all the dependencies needed for shipment repository
+	ShipmentRepositoryImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      DBTX
+	}
+)
+
+// NewShipmentRepository return new instances shipment repository
+func NewShipmentRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *ShipmentRepositoryImpl {
+	return &ShipmentRepositoryImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+func (sr *ShipmentRepositoryImpl) Insert(ctx context.Context, req *model.Shipment) (int, error) {
+	q := `
+		INSERT INTO shipment (transaction_id, partner_id, tracking_number, tracking_url, courier_status, price, courier_updated_at) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id
+	`
+
+	var shipmentID int
+	row := sr.DB.QueryRow(ctx, q, req.TransactionID, req.PartnerID, req.TrackingNumber, req.TrackingURL, req.CourierStatus, req.Price, req.CourierUpdatedAt)
+	err := row.Scan(
+		&shipmentID,
+	)
+	if err != nil {
+		sr.Logger.Error("ShipmentRepositoryImpl.Insert QueryRow Scan ERROR", err)
+
+		return 0, err
+	}
+
+	return shipmentID, nil
+}
+
+func (sr *ShipmentRepositoryImpl) GetByTransactionID(ctx context.Context, transactionID int) (*model.Shipment, error) {
+	q := `
+		SELECT
+			id,
+			transaction_id,
+			partner_id,
+			tracking_number,
+			tracking_url,
+			courier_status,
+			price,
+			courier_updated_at,
+			created_at,
+			updated_at
+		FROM
+			shipment
+		WHERE
+			transaction_id = $1
+	`
+
+	shipment := model.Shipment{}
+	row := sr.DB.QueryRow(ctx, q, transactionID)
+	err := row.Scan(
+		&shipment.ID,
+		&shipment.TransactionID,
+		&shipment.PartnerID,
+		&shipment.TrackingNumber,
+		&shipment.TrackingURL,
+		&shipment.CourierStatus,
+		&shipment.Price,
+		&shipment.CourierUpdatedAt,
+		&shipment.CreatedAt,
+		&shipment.UpdatedAt,
+	)
+	if err != nil {
+		sr.Logger.Error("ShipmentRepositoryImpl.GetByTransactionID QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return &shipment, nil
+}
+
+// GetByPartnerIDForUpdate get shipment by courier shipment id and lock the row until the running transaction end, must be called inside unit of work
+func (sr *ShipmentRepositoryImpl) GetByPartnerIDForUpdate(ctx context.Context, partnerID string) (*model.Shipment, error) {
+	q := `
+		SELECT
+			id,
+			transaction_id,
+			partner_id,
+			tracking_number,
+			tracking_url,
+			courier_status,
+			price,
+			courier_updated_at,
+			created_at,
+			updated_at
+		FROM
+			shipment
+		WHERE
+			partner_id = $1
+		FOR UPDATE
+	`
+
+	shipment := model.Shipment{}
+	row := sr.DB.QueryRow(ctx, q, partnerID)
+	err := row.Scan(
+		&shipment.ID,
+		&shipment.TransactionID,
+		&shipment.PartnerID,
+		&shipment.TrackingNumber,
+		&shipment.TrackingURL,
+		&shipment.CourierStatus,
+		&shipment.Price,
+		&shipment.CourierUpdatedAt,
+		&shipment.CreatedAt,
+		&shipment.UpdatedAt,
+	)
+	if err != nil {
+		sr.Logger.Error("ShipmentRepositoryImpl.GetByPartnerIDForUpdate QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return &shipment, nil
+}
+
+// UpdateCourierStatusByID update courier status of shipment, empty tracking number keep the current one
+func (sr *ShipmentRepositoryImpl) UpdateCourierStatusByID(ctx context.Context, status model.CourierStatusEnum, trackingNumber string, courierUpdatedAt time.Time, id int) error {
+	q := `
+		UPDATE shipment SET courier_status = $1, tracking_number = COALESCE(NULLIF($2, ''), tracking_number), courier_updated_at = $3, updated_at = NOW() WHERE id = $4
+	`
+
+	_, err := sr.DB.Exec(ctx, q, status, trackingNumber, courierUpdatedAt, id)
+	if err != nil {
+		sr.Logger.Error("ShipmentRepositoryImpl.UpdateCourierStatusByID Exec ERROR", err)
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// ShippingTariffRepository is an interface that has all the function to be implemented inside shipping tariff repository
+	ShippingTariffRepository interface {
+		GetBySubDistrictOrDistance(ctx context.Context, subDistrict string, distanceKm float64) (*model.ShippingTariff, error)
+	}
+
+	// ShippingTariffRepositoryImpl is an app shipping tariff struct that consists of all the dependencies needed for shipping tariff repository
+	ShippingTariffRepositoryImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      DBTX
+	}
+)
+
+// NewShippingTariffRepository return new instances shipping tariff repository
+func NewShippingTariffRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *ShippingTariffRepositoryImpl {
+	return &ShippingTariffRepositoryImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+// GetBySubDistrictOrDistance return flat tariff of the sub district when exists, otherwise tariff of the distance band
+func (sr *ShippingTariffRepositoryImpl) GetBySubDistrictOrDistance(ctx context.Context, subDistrict string, distanceKm float64) (*model.ShippingTariff, error) {
+	q := `
+		SELECT
+			id,
+			sub_district,
+			min_distance_km,
+			max_distance_km,
+			price,
+			created_at
+		FROM
+			shipping_tariff
+		WHERE
+			LOWER(sub_district) = LOWER($1)
+		OR
+			(sub_district IS NULL AND $2 >= min_distance_km AND $2 < max_distance_km)
+		ORDER BY
+			sub_district IS NULL ASC,
+			min_distance_km ASC
+		LIMIT 1
+	`
+
+	tariff := model.ShippingTariff{}
+	row := sr.DB.QueryRow(ctx, q, subDistrict, distanceKm)
+	err := row.Scan(
+		&tariff.ID,
+		&tariff.SubDistrict,
+		&tariff.MinDistanceKm,
+		&tariff.MaxDistanceKm,
+		&tariff.Price,
+		&tariff.CreatedAt,
+	)
+	if err != nil {
+		sr.Logger.Error("ShippingTariffRepositoryImpl.GetBySubDistrictOrDistance QueryRow.Scan ERROR", err)
+
+		return nil, err
+	}
+
+	return &tariff, nil
+}
@@ -7,8 +7,8 @@ import (
 	"e-resep-be/internal/model"
 	"fmt"
 	"reflect"
+	"time"
 
-	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
@@ -19,6 +19,10 @@ type (
 		GetDetailsByTransactionID(ctx context.Context, transactionID int) ([]model.TransactionDetail, error)
 		UpdateByID(ctx context.Context, req model.Transaction, id int) error
 		GetByID(ctx context.Context, id int) (*model.Transaction, error)
+		GetByStatusCreatedBefore(ctx context.Context, status model.TransactionStatusEnum, createdBefore time.Time, limit int) ([]model.Transaction, error)
+		GetHistory(ctx context.Context, filter model.TransactionHistoryFilter, limit, offset int) ([]model.TransactionHistory, error)
+		CountHistory(ctx context.Context, filter model.TransactionHistoryFilter) (int, error)
+		GetDetailsByTransactionIDs(ctx context.Context, transactionIDs []int) ([]model.TransactionDetail, error)
 	}
 
 	// TransactionRepositoryImpl is an app transaction struct that consists of all the dependencies needed for transaction repository
@@ -26,12 +30,12 @@ type (
 		Context context.Context
 		Config  *config.Configuration
 		Logger  *logrus.Logger
-		DB      *pgxpool.Pool
+		DB      DBTX
 	}
 )
 
 // NewTransactionRepository return new instances transaction repository
-func NewTransactionRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db *pgxpool.Pool) *TransactionRepositoryImpl {
+func NewTransactionRepository(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db DBTX) *TransactionRepositoryImpl {
 	return &TransactionRepositoryImpl{
 		Context: ctx,
 		Config:  config,
@@ -46,7 +50,7 @@ func (tr *TransactionRepositoryImpl) Insert(ctx context.Context, req *model.Crea
 	`
 
 	qInsertTrxDetail := `
-		INSERT INTO transaction_detail (transaction_id, medication_id, medication_name, price) VALUES %s
+		INSERT INTO transaction_detail (transaction_id, medication_id, medication_name, price, medication_request_id) VALUES %s
 	`
 
 	tx, err := tr.DB.Begin(ctx)
@@ -74,11 +78,11 @@ func (tr *TransactionRepositoryImpl) Insert(ctx context.Context, req *model.Crea
 		return 0, err
 	}
 
-	numberArgsPerRows := 4
+	numberArgsPerRows := 5
 	valueArgs := make([]interface{}, 0, numberArgsPerRows*len(req.Items))
 
 	for i := 0; i < len(req.Items); i++ {
-		valueArgs = append(valueArgs, transactionID, req.Items[i].ID, req.Items[i].Name, req.Items[i].Price)
+		valueArgs = append(valueArgs, transactionID, req.Items[i].ID, req.Items[i].Name, req.Items[i].Price, req.Items[i].MedicationRequestID)
 	}
 
 	qInsertTrxDetail = helper.BulkInsert(qInsertTrxDetail, numberArgsPerRows, len(req.Items))
@@ -228,3 +232,210 @@ func (tr *TransactionRepositoryImpl) GetByID(ctx context.Context, id int) (*mode
 
 	return &transaction, nil
 }
+
+// GetByStatusCreatedBefore get oldest transactions with given status that is created before the given time
+func (tr *TransactionRepositoryImpl) GetByStatusCreatedBefore(ctx context.Context, status model.TransactionStatusEnum, createdBefore time.Time, limit int) ([]model.Transaction, error) {
+	q := `
+		SELECT
+			id,
+			patient_id,
+			patient_address_id,
+			status,
+			additional_price,
+			total_price,
+			created_at,
+			updated_at
+		FROM
+			transaction
+		WHERE
+			status = $1 AND created_at < $2
+		ORDER BY
+			created_at
+		LIMIT $3
+	`
+
+	transactions := []model.Transaction{}
+
+	rows, err := tr.DB.Query(ctx, q, status, createdBefore, limit)
+	if err != nil {
+		tr.Logger.Error("TransactionRepositoryImpl.GetByStatusCreatedBefore Query ERROR", err)
+
+		return []model.Transaction{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		transaction := model.Transaction{}
+		err := rows.Scan(
+			&transaction.ID,
+			&transaction.PatientID,
+			&transaction.PatientAddressID,
+			&transaction.Status,
+			&transaction.AdditionalPrice,
+			&transaction.TotalPrice,
+			&transaction.CreatedAt,
+			&transaction.UpdatedAt,
+		)
+		if err != nil {
+			tr.Logger.Error("TransactionRepositoryImpl.GetByStatusCreatedBefore rows Scan ERROR", err)
+
+			return []model.Transaction{}, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
+
+// qTransactionHistoryFilter filter transaction history by patient, statuses and created time, empty statuses or nil time is not filtered
+const qTransactionHistoryFilter = `
+		t.patient_id = $1
+		AND (cardinality($2::text[]) = 0 OR t.status = ANY($2))
+		AND ($3::timestamptz IS NULL OR t.created_at >= $3)
+		AND ($4::timestamptz IS NULL OR t.created_at < $4)
+`
+
+// transactionHistoryFilterArgs return query args of qTransactionHistoryFilter
+func transactionHistoryFilterArgs(filter model.TransactionHistoryFilter) []interface{} {
+	statusArgs := make([]string, 0, len(filter.Statuses))
+	for _, status := range filter.Statuses {
+		statusArgs = append(statusArgs, string(status))
+	}
+
+	return []interface{}{filter.PatientID, statusArgs, filter.CreatedFrom, filter.CreatedUntil}
+}
+
+// GetHistory get transactions of patient together with their payment, ordered from the newest one
+func (tr *TransactionRepositoryImpl) GetHistory(ctx context.Context, filter model.TransactionHistoryFilter, limit, offset int) ([]model.TransactionHistory, error) {
+	q := `
+		SELECT
+			t.id,
+			t.patient_id,
+			t.patient_address_id,
+			t.status,
+			t.additional_price,
+			t.total_price,
+			t.created_at,
+			t.updated_at,
+			p.status,
+			p.method
+		FROM
+			transaction t
+		JOIN
+			payment p ON p.transaction_id = t.id
+		WHERE
+	` + qTransactionHistoryFilter + `
+		ORDER BY
+			t.created_at DESC, t.id DESC
+		LIMIT $5 OFFSET $6
+	`
+
+	transactions := []model.TransactionHistory{}
+
+	args := append(transactionHistoryFilterArgs(filter), limit, offset)
+
+	rows, err := tr.DB.Query(ctx, q, args...)
+	if err != nil {
+		tr.Logger.Error("TransactionRepositoryImpl.GetHistory Query ERROR", err)
+
+		return []model.TransactionHistory{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		transaction := model.TransactionHistory{}
+		err := rows.Scan(
+			&transaction.ID,
+			&transaction.PatientID,
+			&transaction.PatientAddressID,
+			&transaction.Status,
+			&transaction.AdditionalPrice,
+			&transaction.TotalPrice,
+			&transaction.CreatedAt,
+			&transaction.UpdatedAt,
+			&transaction.PaymentStatus,
+			&transaction.PaymentMethod,
+		)
+		if err != nil {
+			tr.Logger.Error("TransactionRepositoryImpl.GetHistory rows Scan ERROR", err)
+
+			return []model.TransactionHistory{}, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
+
+func (tr *TransactionRepositoryImpl) CountHistory(ctx context.Context, filter model.TransactionHistoryFilter) (int, error) {
+	q := `
+		SELECT
+			COUNT(t.id)
+		FROM
+			transaction t
+		JOIN
+			payment p ON p.transaction_id = t.id
+		WHERE
+	` + qTransactionHistoryFilter
+
+	var total int
+	err := tr.DB.QueryRow(ctx, q, transactionHistoryFilterArgs(filter)...).Scan(&total)
+	if err != nil {
+		tr.Logger.Error("TransactionRepositoryImpl.CountHistory QueryRow.Scan ERROR", err)
+
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (tr *TransactionRepositoryImpl) GetDetailsByTransactionIDs(ctx context.Context, transactionIDs []int) ([]model.TransactionDetail, error) {
+	q := `
+		SELECT
+			id,
+			transaction_id,
+			medication_id,
+			medication_name,
+			price,
+			created_at
+		FROM
+			transaction_detail
+		WHERE
+			transaction_id = ANY($1)
+		ORDER BY
+			id
+	`
+
+	transactionDetails := []model.TransactionDetail{}
+
+	rows, err := tr.DB.Query(ctx, q, transactionIDs)
+	if err != nil {
+		tr.Logger.Error("TransactionRepositoryImpl.GetDetailsByTransactionIDs Query ERROR", err)
+
+		return []model.TransactionDetail{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		trxDetail := model.TransactionDetail{}
+		err := rows.Scan(
+			&trxDetail.ID,
+			&trxDetail.TransactionID,
+			&trxDetail.MedicationID,
+			&trxDetail.MedicationName,
+			&trxDetail.Price,
+			&trxDetail.CreatedAt,
+		)
+		if err != nil {
+			tr.Logger.Error("TransactionRepositoryImpl.GetDetailsByTransactionIDs rows Scan ERROR", err)
+
+			return []model.TransactionDetail{}, err
+		}
+
+		transactionDetails = append(transactionDetails, trxDetail)
+	}
+
+	return transactionDetails, nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// Repositories is a set of repositories sharing the same database transaction
+	Repositories struct {
+		Medication                MedicationRepository
+		Patient                   PatientRepository
+		PatientAddress            PatientAddressRepository
+		Prescription              PrescriptionRepository
+		MedicationRequest         MedicationRequestRepository
+		Transaction               TransactionRepository
+		Payment                   PaymentRepository
+		PaymentEvent              PaymentEventRepository
+		PriceQuote                PriceQuoteRepository
+		Refund                    RefundRepository
+		ReconciliationDiscrepancy ReconciliationDiscrepancyRepository
+		Fulfilment                FulfilmentRepository
+		Shipment                  ShipmentRepository
+		DispenseOrder             DispenseOrderRepository
+		Outbox                    OutboxRepository
+		IdempotencyKey            IdempotencyKeyRepository
+	}
+
+	// UnitOfWork is an interface to run several repositories call inside one database transaction
+	UnitOfWork interface {
+		WithTx(ctx context.Context, fn func(repos *Repositories) error) error
+	}
+
+	// UnitOfWorkImpl is an app unit of work struct that consists of all the dependencies needed for unit of work
+	UnitOfWorkImpl struct {
+		Context context.Context
+		Config  *config.Configuration
+		Logger  *logrus.Logger
+		DB      *pgxpool.Pool
+	}
+)
+
+// NewUnitOfWork return new instances unit of work
+func NewUnitOfWork(ctx context.Context, config *config.Configuration, logger *logrus.Logger, db *pgxpool.Pool) *UnitOfWorkImpl {
+	return &UnitOfWorkImpl{
+		Context: ctx,
+		Config:  config,
+		Logger:  logger,
+		DB:      db,
+	}
+}
+
+// WithTx run fn inside database transaction, the transaction is committed when fn return nil and rolled back otherwise
+func (uw *UnitOfWorkImpl) WithTx(ctx context.Context, fn func(repos *Repositories) error) error {
+	tx, err := uw.DB.Begin(ctx)
+	if err != nil {
+		uw.Logger.Error("UnitOfWorkImpl.WithTx ERROR begin TX", err)
+
+		return err
+	}
+
+	// rollback is no-op when transaction is already committed
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	repos := &Repositories{
+		Medication:                NewMedicationRepository(uw.Context, uw.Config, uw.Logger, tx),
+		Patient:                   NewPatientRepository(uw.Context, uw.Config, uw.Logger, tx),
+		PatientAddress:            NewPatientAddressRepository(uw.Context, uw.Config, uw.Logger, tx),
+		Prescription:              NewPrescriptionRepository(uw.Context, uw.Config, uw.Logger, tx),
+		MedicationRequest:         NewMedicationRequestRepository(uw.Context, uw.Config, uw.Logger, tx),
+		Transaction:               NewTransactionRepository(uw.Context, uw.Config, uw.Logger, tx),
+		Payment:                   NewPaymentRepository(uw.Context, uw.Config, uw.Logger, tx),
+		PaymentEvent:              NewPaymentEventRepository(uw.Context, uw.Config, uw.Logger, tx),
+		PriceQuote:                NewPriceQuoteRepository(uw.Context, uw.Config, uw.Logger, tx),
+		Refund:                    NewRefundRepository(uw.Context, uw.Config, uw.Logger, tx),
+		ReconciliationDiscrepancy: NewReconciliationDiscrepancyRepository(uw.Context, uw.Config, uw.Logger, tx),
+		Fulfilment:                NewFulfilmentRepository(uw.Context, uw.Config, uw.Logger, tx),
+		Shipment:                  NewShipmentRepository(uw.Context, uw.Config, uw.Logger, tx),
+		DispenseOrder:             NewDispenseOrderRepository(uw.Context, uw.Config, uw.Logger, tx),
+		Outbox:                    NewOutboxRepository(uw.Context, uw.Config, uw.Logger, tx),
+		IdempotencyKey:            NewIdempotencyKeyRepository(uw.Context, uw.Config, uw.Logger, tx),
+	}
+
+	err = fn(repos)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		uw.Logger.Error("UnitOfWorkImpl.WithTx ERROR commit TX", err)
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package requester
+
+import (
+	"bytes"
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultCourierTimeout is used when COURIER_TIMEOUT is not configured
+const defaultCourierTimeout = 10 * time.Second
+
+// Courier wire contract (GoSend/Grab-style instant delivery)
+//
+// Book a shipment from the pharmacy to the patient:
+//
+//	POST {COURIER_URL}/v1/shipments
+//	Content-Type: application/json
+//	Authorization: Bearer {COURIER_API_KEY}
+//	Idempotency-Key: shipment-{transaction id}
+//
+//	{"reference_id": "12", "service_type": "instant",
+//	 "origin": {"name": "...", "phone_number": "+62...", "address": "...", "latitude": -6.2, "longitude": 106.8},
+//	 "destination": {"name": "...", "phone_number": "+62...", "address": "...", "latitude": -6.3, "longitude": 106.9, "notes": "..."},
+//	 "items": [{"name": "Paracetamol 500 mg", "quantity": 2}]}
+//
+// Retrying with the same Idempotency-Key return the shipment booked by the first request. On 2xx the body is:
+//
+//	{"data": {"id": "shp_1", "reference_id": "12", "tracking_number": "CR0001", "tracking_url": "https://...",
+//	 "status": "ALLOCATING", "price": 15000, "updated_at": "2024-01-01T10:00:00Z"}}
+//
+// Get a shipment:
+//
+//	GET {COURIER_URL}/v1/shipments/{id}
+//	Authorization: Bearer {COURIER_API_KEY}
+//
+// Status changes are posted to /v1/courier/notification with x-callback-token: {COURIER_CALLBACK_TOKEN}:
+//
+//	{"event_id": "evt_1", "data": {...shipment...}}
+//
+// On non-2xx the body is:
+//
+//	{"error": {"code": "SHIPMENT_NOT_FOUND", "message": "shipment not found"}}
+//
+// Non-2xx status are mapped into model errors: 404 -> NotFound, 400/422 -> Validation, others -> Partner.
+
+type (
+	// CourierRequester is an interface that has all the function to be implemented inside courier requester
+	CourierRequester interface {
+		CreateShipment(ctx context.Context, req *model.CourierShipmentRequest, idempotencyKey string) (*model.CourierShipment, error)
+		GetShipment(ctx context.Context, id string) (*model.CourierShipment, error)
+	}
+
+	// CourierRequesterImpl is an app courier struct that consists of all the dependencies needed for courier requester
+	CourierRequesterImpl struct {
+		Context    context.Context
+		Config     *config.Configuration
+		Logger     *logrus.Logger
+		HTTPClient *http.Client
+	}
+)
+
+// NewCourierRequester return new instances courier requester
+func NewCourierRequester(ctx context.Context, config *config.Configuration, logger *logrus.Logger, httpCli *http.Client) *CourierRequesterImpl {
+	return &CourierRequesterImpl{
+		Context:    ctx,
+		Config:     config,
+		Logger:     logger,
+		HTTPClient: httpCli,
+	}
+}
+
+// CreateShipment book a shipment on courier, the idempotency key make retries return the same shipment
+func (cr *CourierRequesterImpl) CreateShipment(ctx context.Context, req *model.CourierShipmentRequest, idempotencyKey string) (*model.CourierShipment, error) {
+	if cr.Config.Courier.CourierURL == "" {
+		return nil, model.NewError(model.Partner, "courier url is not configured")
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/shipments", strings.TrimSuffix(cr.Config.Courier.CourierURL, "/"))
+
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling shipment request: %w", err)
+	}
+
+	var resp model.CourierShipmentResponse
+	err = cr.doRequest(ctx, http.MethodPost, endpoint, bytes.NewReader(reqBytes), idempotencyKey, &resp)
+	if err != nil {
+		cr.Logger.Error("CourierRequesterImpl.CreateShipment ERROR ", err)
+
+		return nil, err
+	}
+
+	return &resp.Data, nil
+}
+
+func (cr *CourierRequesterImpl) GetShipment(ctx context.Context, id string) (*model.CourierShipment, error) {
+	if cr.Config.Courier.CourierURL == "" {
+		return nil, model.NewError(model.Partner, "courier url is not configured")
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/shipments/%s", strings.TrimSuffix(cr.Config.Courier.CourierURL, "/"), url.PathEscape(id))
+
+	var resp model.CourierShipmentResponse
+	err := cr.doRequest(ctx, http.MethodGet, endpoint, nil, "", &resp)
+	if err != nil {
+		cr.Logger.Error("CourierRequesterImpl.GetShipment ERROR ", err)
+
+		return nil, err
+	}
+
+	return &resp.Data, nil
+}
+
+// doRequest send request to courier with configured timeout and decode the success body into dest
+func (cr *CourierRequesterImpl) doRequest(ctx context.Context, method, endpoint string, body io.Reader, idempotencyKey string, dest interface{}) error {
+	timeout := defaultCourierTimeout
+	if cr.Config.Courier.CourierTimeout > 0 {
+		timeout = time.Duration(cr.Config.Courier.CourierTimeout) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cr.Config.Courier.CourierAPIKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := cr.HTTPClient.Do(req)
+	if err != nil {
+		return model.NewError(model.Partner, fmt.Sprintf("error sending request to courier: %v", err))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return model.NewError(model.Partner, fmt.Sprintf("error reading courier response: %v", err))
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return cr.mapErrorResponse(resp.StatusCode, respBody)
+	}
+
+	if err := json.Unmarshal(respBody, dest); err != nil {
+		return model.NewError(model.Partner, fmt.Sprintf("error decoding courier response: %v", err))
+	}
+
+	return nil
+}
+
+// mapErrorResponse convert non-2xx courier response into model error
+func (cr *CourierRequesterImpl) mapErrorResponse(statusCode int, body []byte) error {
+	var errResp model.CourierErrorResponse
+	msg := http.StatusText(statusCode)
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		msg = fmt.Sprintf("%s (%s)", errResp.Error.Message, errResp.Error.Code)
+	}
+
+	switch statusCode {
+	case http.StatusNotFound:
+		return model.NewError(model.NotFound, msg)
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return model.NewError(model.Validation, msg)
+	default:
+		return model.NewError(model.Partner, fmt.Sprintf("courier responded %d: %s", statusCode, msg))
+	}
+}
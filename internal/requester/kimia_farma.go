@@ -1,19 +1,84 @@
 package requester
 
 import (
+	"bytes"
 	"context"
 	"e-resep-be/internal/config"
 	"e-resep-be/internal/helper"
 	"e-resep-be/internal/model"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// defaultKimiaFarmaTimeout is used when KIMIA_FARMA_TIMEOUT is not configured
+	defaultKimiaFarmaTimeout = 10 * time.Second
+	// defaultKimiaFarmaMaxConcurrency is used when KIMIA_FARMA_MAX_CONCURRENCY is not configured
+	defaultKimiaFarmaMaxConcurrency = 5
+)
+
+// Kimia Farma wire contract
+//
+// Check availability and price of a single medication by KFA code:
+//
+//	GET {KIMIA_FARMA_URL}/v1/medications/{kfa_code}/availability
+//	Accept: application/json
+//	X-API-Key: {KIMIA_FARMA_API_KEY}
+//
+// On 2xx the body is:
+//
+//	{"data": {"kfa_code": "93001019", "is_available": true, "price": 15000, "stock": 120}}
+//
+// On non-2xx the body is:
+//
+//	{"error": {"code": "MEDICATION_NOT_FOUND", "message": "medication not found"}}
+//
+// Check availability and price of many medications in one round trip (enabled by KIMIA_FARMA_BATCH_ENABLED):
+//
+//	POST {KIMIA_FARMA_URL}/v1/medications/availability
+//	Content-Type: application/json
+//	X-API-Key: {KIMIA_FARMA_API_KEY}
+//
+//	{"kfa_codes": ["93001019", "92000456"]}
+//
+// On 2xx the body report resolved and unresolved codes separately:
+//
+//	{"data": [{"kfa_code": "93001019", "is_available": true, "price": 15000, "stock": 120}],
+//	 "errors": [{"kfa_code": "92000456", "code": "MEDICATION_NOT_FOUND", "message": "medication not found"}]}
+//
+// Push paid order to be dispensed and delivered by kimia farma:
+//
+//	POST {KIMIA_FARMA_URL}/v1/dispense-orders
+//	Content-Type: application/json
+//	X-API-Key: {KIMIA_FARMA_API_KEY}
+//	Idempotency-Key: dispense-order-{transaction id}
+//
+//	{"reference_id": "12",
+//	 "items": [{"kfa_code": "93001019", "name": "Paracetamol 500 mg", "quantity": 10, "price": 15000,
+//	            "medication_request_id": "...", "dosage_instructions": [{...fhir dosage...}]}],
+//	 "delivery": {"recipient_name": "...", "phone_number": "+62...", "address": "...", "sub_district": "...", "district": "...",
+//	              "city": "...", "province": "...", "postal_code": "...", "latitude": -6.2, "longitude": 106.8, "notes": "..."}}
+//
+// Retrying with the same Idempotency-Key return the order created by the first request. On 2xx the body is:
+//
+//	{"data": {"order_id": "KF-ORD-0001", "reference_id": "12", "status": "RECEIVED"}}
+//
+// Non-2xx status are mapped into model errors: 404 -> NotFound, 400/422 -> Validation, others -> Partner.
+
 type (
 	// KimiaFarmaRequester is an interface that has all the function to be implemented inside kimia farma requester
 	KimiaFarmaRequester interface {
 		CheckAvailabilityAndPriceMedicationByCode(ctx context.Context, kfaCode string) (*model.CheckAvailabilityResponse, error)
+		CheckAvailabilityBatch(ctx context.Context, kfaCodes []string) (*model.CheckAvailabilityBatchResponse, error)
+		CreateDispenseOrder(ctx context.Context, req *model.KimiaFarmaDispenseOrderRequest, idempotencyKey string) (*model.KimiaFarmaDispenseOrder, error)
 	}
 
 	// KimiaFarmaRequesterImpl is an app kimia farma struct that consists of all the dependencies needed for kimia farma requester
@@ -36,12 +101,269 @@ func NewKimiaFarmaRequester(ctx context.Context, config *config.Configuration, l
 }
 
 func (kr *KimiaFarmaRequesterImpl) CheckAvailabilityAndPriceMedicationByCode(ctx context.Context, kfaCode string) (*model.CheckAvailabilityResponse, error) {
-	// TODO : implement requester check availablilty and price medication by id
-	if kr.Config.Server.AppEnv == "development" {
-		return &model.CheckAvailabilityResponse{
-			IsAvailable: true,
-			Price:       helper.GenerateRandomPrice(3000, 200000),
-		}, nil
-	}
-	return nil, nil
+	// keep random price for local development without kimia farma access
+	if kr.Config.KimiaFarma.KimiaFarmaURL == "" {
+		if kr.Config.Server.AppEnv == "development" {
+			return &model.CheckAvailabilityResponse{
+				KFACode:     kfaCode,
+				IsAvailable: true,
+				Price:       helper.GenerateRandomPrice(3000, 200000),
+			}, nil
+		}
+
+		return nil, model.NewError(model.Partner, "kimia farma url is not configured")
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/medications/%s/availability", strings.TrimSuffix(kr.Config.KimiaFarma.KimiaFarmaURL, "/"), url.PathEscape(kfaCode))
+
+	var resp model.KimiaFarmaAvailabilityResponse
+	err := kr.doRequest(ctx, http.MethodGet, endpoint, nil, "", &resp)
+	if err != nil {
+		kr.Logger.Error("KimiaFarmaRequesterImpl.CheckAvailabilityAndPriceMedicationByCode ERROR ", err)
+
+		return nil, err
+	}
+
+	if resp.Data.KFACode == "" {
+		resp.Data.KFACode = kfaCode
+	}
+
+	return &resp.Data, nil
+}
+
+// CheckAvailabilityBatch resolve availability and price of all kfa codes, failures of a single code are reported per code inside the response.
+// It use kimia farma batch endpoint when enabled, otherwise fan-out single lookups with bounded concurrency.
+func (kr *KimiaFarmaRequesterImpl) CheckAvailabilityBatch(ctx context.Context, kfaCodes []string) (*model.CheckAvailabilityBatchResponse, error) {
+	codes := uniqueCodes(kfaCodes)
+	if len(codes) == 0 {
+		return model.NewCheckAvailabilityBatchResponse(), nil
+	}
+
+	if kr.Config.KimiaFarma.KimiaFarmaBatchEnabled && kr.Config.KimiaFarma.KimiaFarmaURL != "" {
+		return kr.checkAvailabilityBatchRequest(ctx, codes)
+	}
+
+	return kr.checkAvailabilityFanOut(ctx, codes)
+}
+
+func (kr *KimiaFarmaRequesterImpl) checkAvailabilityBatchRequest(ctx context.Context, codes []string) (*model.CheckAvailabilityBatchResponse, error) {
+	endpoint := fmt.Sprintf("%s/v1/medications/availability", strings.TrimSuffix(kr.Config.KimiaFarma.KimiaFarmaURL, "/"))
+
+	reqBytes, err := json.Marshal(model.KimiaFarmaBatchAvailabilityRequest{KFACodes: codes})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling batch request: %w", err)
+	}
+
+	var resp model.KimiaFarmaBatchAvailabilityResponse
+	err = kr.doRequest(ctx, http.MethodPost, endpoint, bytes.NewReader(reqBytes), "", &resp)
+	if err != nil {
+		kr.Logger.Error("KimiaFarmaRequesterImpl.CheckAvailabilityBatch ERROR ", err)
+
+		return nil, err
+	}
+
+	result := model.NewCheckAvailabilityBatchResponse()
+	for _, item := range resp.Data {
+		result.Items[item.KFACode] = item
+	}
+
+	for _, e := range resp.Errors {
+		result.Errors[e.KFACode] = kr.mapBatchError(e)
+	}
+
+	// partner must answer every code, otherwise treat the missing one as not found
+	for _, code := range codes {
+		_, okItem := result.Items[code]
+		_, okErr := result.Errors[code]
+		if !okItem && !okErr {
+			result.Errors[code] = model.NewError(model.NotFound, "medication not returned by kimia farma")
+		}
+	}
+
+	return result, nil
+}
+
+// checkAvailabilityFanOut look up every code with bounded concurrency, lookups stop as soon as the request is cancelled
+func (kr *KimiaFarmaRequesterImpl) checkAvailabilityFanOut(ctx context.Context, codes []string) (*model.CheckAvailabilityBatchResponse, error) {
+	maxConcurrency := defaultKimiaFarmaMaxConcurrency
+	if kr.Config.KimiaFarma.KimiaFarmaMaxConcurrency > 0 {
+		maxConcurrency = kr.Config.KimiaFarma.KimiaFarmaMaxConcurrency
+	}
+
+	var (
+		result = model.NewCheckAvailabilityBatchResponse()
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, maxConcurrency)
+	)
+
+	for _, code := range codes {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+
+			return nil, ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(code string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			resp, err := kr.CheckAvailabilityAndPriceMedicationByCode(ctx, code)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				result.Errors[code] = err
+				return
+			}
+
+			result.Items[code] = *resp
+		}(code)
+	}
+
+	wg.Wait()
+
+	// lookup cut by cancelled request doesn't tell anything about the code
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// mapBatchError convert per code error reported by batch endpoint into model error
+func (kr *KimiaFarmaRequesterImpl) mapBatchError(e model.KimiaFarmaBatchError) error {
+	msg := fmt.Sprintf("%s (%s)", e.Message, e.Code)
+
+	switch e.Code {
+	case "MEDICATION_NOT_FOUND":
+		return model.NewError(model.NotFound, msg)
+	case "INVALID_KFA_CODE":
+		return model.NewError(model.Validation, msg)
+	default:
+		return model.NewError(model.Partner, msg)
+	}
+}
+
+// uniqueCodes remove empty and duplicated kfa codes while keeping the order
+func uniqueCodes(kfaCodes []string) []string {
+	seen := make(map[string]bool, len(kfaCodes))
+	codes := make([]string, 0, len(kfaCodes))
+	for _, code := range kfaCodes {
+		if code == "" || seen[code] {
+			continue
+		}
+
+		seen[code] = true
+		codes = append(codes, code)
+	}
+
+	return codes
+}
+
+// CreateDispenseOrder push paid order to kimia farma, the idempotency key make retries return the same order
+func (kr *KimiaFarmaRequesterImpl) CreateDispenseOrder(ctx context.Context, req *model.KimiaFarmaDispenseOrderRequest, idempotencyKey string) (*model.KimiaFarmaDispenseOrder, error) {
+	// keep fake order for local development without kimia farma access
+	if kr.Config.KimiaFarma.KimiaFarmaURL == "" {
+		if kr.Config.Server.AppEnv == "development" {
+			return &model.KimiaFarmaDispenseOrder{
+				OrderID:     "KF-DEV-" + req.ReferenceID,
+				ReferenceID: req.ReferenceID,
+				Status:      "RECEIVED",
+			}, nil
+		}
+
+		return nil, model.NewError(model.Partner, "kimia farma url is not configured")
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/dispense-orders", strings.TrimSuffix(kr.Config.KimiaFarma.KimiaFarmaURL, "/"))
+
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling dispense order request: %w", err)
+	}
+
+	var resp model.KimiaFarmaDispenseOrderResponse
+	err = kr.doRequest(ctx, http.MethodPost, endpoint, bytes.NewReader(reqBytes), idempotencyKey, &resp)
+	if err != nil {
+		kr.Logger.Error("KimiaFarmaRequesterImpl.CreateDispenseOrder ERROR ", err)
+
+		return nil, err
+	}
+
+	if resp.Data.OrderID == "" {
+		return nil, model.NewError(model.Partner, "kimia farma didn't return order id")
+	}
+
+	return &resp.Data, nil
+}
+
+// doRequest send request to kimia farma with configured timeout and decode the success body into dest
+func (kr *KimiaFarmaRequesterImpl) doRequest(ctx context.Context, method, endpoint string, body io.Reader, idempotencyKey string, dest interface{}) error {
+	timeout := defaultKimiaFarmaTimeout
+	if kr.Config.KimiaFarma.KimiaFarmaTimeout > 0 {
+		timeout = time.Duration(kr.Config.KimiaFarma.KimiaFarmaTimeout) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-API-Key", kr.Config.KimiaFarma.KimiaFarmaAPIKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := kr.HTTPClient.Do(req)
+	if err != nil {
+		return model.NewError(model.Partner, fmt.Sprintf("error sending request to kimia farma: %v", err))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return model.NewError(model.Partner, fmt.Sprintf("error reading kimia farma response: %v", err))
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return kr.mapErrorResponse(resp.StatusCode, respBody)
+	}
+
+	if err := json.Unmarshal(respBody, dest); err != nil {
+		return model.NewError(model.Partner, fmt.Sprintf("error decoding kimia farma response: %v", err))
+	}
+
+	return nil
+}
+
+// mapErrorResponse convert non-2xx kimia farma response into model error
+func (kr *KimiaFarmaRequesterImpl) mapErrorResponse(statusCode int, body []byte) error {
+	var errResp model.KimiaFarmaErrorResponse
+	msg := http.StatusText(statusCode)
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		msg = fmt.Sprintf("%s (%s)", errResp.Error.Message, errResp.Error.Code)
+	}
+
+	switch statusCode {
+	case http.StatusNotFound:
+		return model.NewError(model.NotFound, msg)
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return model.NewError(model.Validation, msg)
+	default:
+		return model.NewError(model.Partner, fmt.Sprintf("kimia farma responded %d: %s", statusCode, msg))
+	}
 }
@@ -0,0 +1,257 @@
+package requester
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultKimiaFarmaCacheTTL is used when KIMIA_FARMA_CACHE_TTL is not configured
+const defaultKimiaFarmaCacheTTL = 5 * time.Minute
+
+type (
+	// KimiaFarmaCacheRequesterImpl is a kimia farma requester that cache availability and price per kfa code,
+	// concurrent lookups of the same code are deduplicated into a single call to the wrapped requester
+	KimiaFarmaCacheRequesterImpl struct {
+		Context   context.Context
+		Config    *config.Configuration
+		Logger    *logrus.Logger
+		Requester KimiaFarmaRequester
+
+		mu      sync.Mutex
+		entries map[string]kimiaFarmaCacheEntry
+		calls   map[string]*kimiaFarmaCall
+	}
+
+	kimiaFarmaCacheEntry struct {
+		resp      model.CheckAvailabilityResponse
+		expiresAt time.Time
+	}
+
+	// kimiaFarmaCall is an in-flight lookup of a single kfa code, done is closed once its result is set
+	kimiaFarmaCall struct {
+		done chan struct{}
+		resp *model.CheckAvailabilityResponse
+		err  error
+		// abandoned is set when the lookup is cut by cancelled request of its caller, the result isn't shared
+		abandoned bool
+	}
+)
+
+// NewKimiaFarmaCacheRequester return new instances kimia farma requester wrapped with cache
+func NewKimiaFarmaCacheRequester(ctx context.Context, config *config.Configuration, logger *logrus.Logger, requester KimiaFarmaRequester) *KimiaFarmaCacheRequesterImpl {
+	return &KimiaFarmaCacheRequesterImpl{
+		Context:   ctx,
+		Config:    config,
+		Logger:    logger,
+		Requester: requester,
+		entries:   map[string]kimiaFarmaCacheEntry{},
+		calls:     map[string]*kimiaFarmaCall{},
+	}
+}
+
+func (kc *KimiaFarmaCacheRequesterImpl) CheckAvailabilityAndPriceMedicationByCode(ctx context.Context, kfaCode string) (*model.CheckAvailabilityResponse, error) {
+	for {
+		kc.mu.Lock()
+		if resp, ok := kc.getLocked(kfaCode); ok {
+			kc.mu.Unlock()
+
+			return &resp, nil
+		}
+
+		// another request is already looking up this code, wait for its result
+		if c, ok := kc.calls[kfaCode]; ok {
+			kc.mu.Unlock()
+
+			if err := c.wait(ctx); err != nil {
+				return nil, err
+			}
+
+			// caller of the lookup is gone, look it up again
+			if c.abandoned {
+				continue
+			}
+
+			return c.resp, c.err
+		}
+
+		c := kc.startCallLocked(kfaCode)
+		kc.mu.Unlock()
+
+		c.resp, c.err = kc.Requester.CheckAvailabilityAndPriceMedicationByCode(ctx, kfaCode)
+
+		kc.mu.Lock()
+		kc.finishCallLocked(ctx, kfaCode, c)
+		kc.mu.Unlock()
+
+		return c.resp, c.err
+	}
+}
+
+func (kc *KimiaFarmaCacheRequesterImpl) CheckAvailabilityBatch(ctx context.Context, kfaCodes []string) (*model.CheckAvailabilityBatchResponse, error) {
+	var (
+		result  = model.NewCheckAvailabilityBatchResponse()
+		waiting = map[string]*kimiaFarmaCall{}
+		owned   = map[string]*kimiaFarmaCall{}
+		missing = []string{}
+	)
+
+	// split codes into cached, already in-flight and the ones this request must look up
+	kc.mu.Lock()
+	for _, code := range uniqueCodes(kfaCodes) {
+		if resp, ok := kc.getLocked(code); ok {
+			result.Items[code] = resp
+			continue
+		}
+
+		if c, ok := kc.calls[code]; ok {
+			waiting[code] = c
+			continue
+		}
+
+		owned[code] = kc.startCallLocked(code)
+		missing = append(missing, code)
+	}
+	kc.mu.Unlock()
+
+	if len(missing) > 0 {
+		resp, err := kc.Requester.CheckAvailabilityBatch(ctx, missing)
+
+		kc.mu.Lock()
+		for code, c := range owned {
+			switch {
+			case err != nil:
+				c.err = err
+			case resp.Errors[code] != nil:
+				c.err = resp.Errors[code]
+			default:
+				item := resp.Items[code]
+				c.resp = &item
+			}
+
+			kc.finishCallLocked(ctx, code, c)
+		}
+		kc.mu.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for code, c := range owned {
+		if c.err != nil {
+			result.Errors[code] = c.err
+			continue
+		}
+
+		result.Items[code] = *c.resp
+	}
+
+	retry := []string{}
+	for code, c := range waiting {
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		if c.abandoned {
+			retry = append(retry, code)
+			continue
+		}
+
+		if c.err != nil {
+			result.Errors[code] = c.err
+			continue
+		}
+
+		result.Items[code] = *c.resp
+	}
+
+	// lookups abandoned by their caller are looked up again with this request
+	if len(retry) > 0 {
+		retried, err := kc.CheckAvailabilityBatch(ctx, retry)
+		if err != nil {
+			return nil, err
+		}
+
+		for code, item := range retried.Items {
+			result.Items[code] = item
+		}
+
+		for code, err := range retried.Errors {
+			result.Errors[code] = err
+		}
+	}
+
+	return result, nil
+}
+
+// CreateDispenseOrder is never cached, it is passed to the wrapped requester as is
+func (kc *KimiaFarmaCacheRequesterImpl) CreateDispenseOrder(ctx context.Context, req *model.KimiaFarmaDispenseOrderRequest, idempotencyKey string) (*model.KimiaFarmaDispenseOrder, error) {
+	return kc.Requester.CreateDispenseOrder(ctx, req, idempotencyKey)
+}
+
+// startCallLocked register in-flight lookup of kfa code, caller must hold kc.mu
+func (kc *KimiaFarmaCacheRequesterImpl) startCallLocked(kfaCode string) *kimiaFarmaCall {
+	c := &kimiaFarmaCall{done: make(chan struct{})}
+	kc.calls[kfaCode] = c
+
+	return c
+}
+
+// finishCallLocked cache successful lookup and release its waiters, lookup cut by cancelled request is neither cached
+// nor shared so the waiters look it up again. Caller must hold kc.mu
+func (kc *KimiaFarmaCacheRequesterImpl) finishCallLocked(ctx context.Context, kfaCode string, c *kimiaFarmaCall) {
+	switch {
+	case c.err != nil && (ctx.Err() != nil || errors.Is(c.err, context.Canceled) || errors.Is(c.err, context.DeadlineExceeded)):
+		c.abandoned = true
+	case c.err == nil:
+		kc.setLocked(kfaCode, *c.resp)
+	}
+
+	delete(kc.calls, kfaCode)
+	close(c.done)
+}
+
+// wait block until the lookup is finished or ctx is done
+func (c *kimiaFarmaCall) wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getLocked return cached response that is not expired yet, caller must hold kc.mu
+func (kc *KimiaFarmaCacheRequesterImpl) getLocked(kfaCode string) (model.CheckAvailabilityResponse, bool) {
+	entry, ok := kc.entries[kfaCode]
+	if !ok {
+		return model.CheckAvailabilityResponse{}, false
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(kc.entries, kfaCode)
+
+		return model.CheckAvailabilityResponse{}, false
+	}
+
+	return entry.resp, true
+}
+
+// setLocked store response with configured TTL, caller must hold kc.mu
+func (kc *KimiaFarmaCacheRequesterImpl) setLocked(kfaCode string, resp model.CheckAvailabilityResponse) {
+	ttl := defaultKimiaFarmaCacheTTL
+	if kc.Config.KimiaFarma.KimiaFarmaCacheTTL > 0 {
+		ttl = time.Duration(kc.Config.KimiaFarma.KimiaFarmaCacheTTL) * time.Second
+	}
+
+	kc.entries[kfaCode] = kimiaFarmaCacheEntry{
+		resp:      resp,
+		expiresAt: time.Now().Add(ttl),
+	}
+}
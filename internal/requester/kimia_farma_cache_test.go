@@ -0,0 +1,266 @@
+package requester
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeKimiaFarmaRequester answer lookups with lookup, it count calls per kfa code
+type fakeKimiaFarmaRequester struct {
+	mu     sync.Mutex
+	calls  map[string]int
+	lookup func(ctx context.Context, kfaCode string, call int) (*model.CheckAvailabilityResponse, error)
+}
+
+func (f *fakeKimiaFarmaRequester) called(kfaCode string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[kfaCode]
+}
+
+func (f *fakeKimiaFarmaRequester) CheckAvailabilityAndPriceMedicationByCode(ctx context.Context, kfaCode string) (*model.CheckAvailabilityResponse, error) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = map[string]int{}
+	}
+	f.calls[kfaCode]++
+	call := f.calls[kfaCode]
+	f.mu.Unlock()
+
+	return f.lookup(ctx, kfaCode, call)
+}
+
+func (f *fakeKimiaFarmaRequester) CheckAvailabilityBatch(ctx context.Context, kfaCodes []string) (*model.CheckAvailabilityBatchResponse, error) {
+	result := model.NewCheckAvailabilityBatchResponse()
+	for _, code := range kfaCodes {
+		resp, err := f.CheckAvailabilityAndPriceMedicationByCode(ctx, code)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+
+			result.Errors[code] = err
+			continue
+		}
+
+		result.Items[code] = *resp
+	}
+
+	return result, nil
+}
+
+func (f *fakeKimiaFarmaRequester) CreateDispenseOrder(ctx context.Context, req *model.KimiaFarmaDispenseOrderRequest, idempotencyKey string) (*model.KimiaFarmaDispenseOrder, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newTestKimiaFarmaCache(fake *fakeKimiaFarmaRequester) *KimiaFarmaCacheRequesterImpl {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	return NewKimiaFarmaCacheRequester(context.Background(), &config.Configuration{
+		KimiaFarma: &config.KimiaFarma{},
+	}, logger, fake)
+}
+
+func TestKimiaFarmaCacheCheckAvailability(t *testing.T) {
+	fake := &fakeKimiaFarmaRequester{
+		lookup: func(ctx context.Context, kfaCode string, call int) (*model.CheckAvailabilityResponse, error) {
+			if kfaCode == "99999999" {
+				return nil, model.NewError(model.NotFound, "medication not found")
+			}
+
+			return &model.CheckAvailabilityResponse{KFACode: kfaCode, IsAvailable: true, Price: 15000}, nil
+		},
+	}
+	kc := newTestKimiaFarmaCache(fake)
+
+	for i := 0; i < 3; i++ {
+		resp, err := kc.CheckAvailabilityAndPriceMedicationByCode(context.Background(), "93001019")
+		if err != nil || resp.Price != 15000 {
+			t.Fatalf("lookup %d = %+v, %v, want price 15000", i, resp, err)
+		}
+
+		if _, err := kc.CheckAvailabilityAndPriceMedicationByCode(context.Background(), "99999999"); model.KindOf(err) != model.NotFound {
+			t.Fatalf("lookup %d error = %v, want not found", i, err)
+		}
+	}
+
+	if got := fake.called("93001019"); got != 1 {
+		t.Errorf("found code is looked up %d times, want 1", got)
+	}
+
+	if got := fake.called("99999999"); got != 3 {
+		t.Errorf("failed lookup is looked up %d times, want 3 because error isn't cached", got)
+	}
+
+	resp, err := kc.CheckAvailabilityBatch(context.Background(), []string{"93001019", "92000456"})
+	if err != nil || len(resp.Items) != 2 {
+		t.Fatalf("batch = %+v, %v, want 2 items", resp, err)
+	}
+
+	if got := fake.called("93001019"); got != 1 {
+		t.Errorf("cached code is looked up again by batch, %d calls", got)
+	}
+}
+
+func TestKimiaFarmaCacheDeduplicateConcurrentLookups(t *testing.T) {
+	release := make(chan struct{})
+	fake := &fakeKimiaFarmaRequester{
+		lookup: func(ctx context.Context, kfaCode string, call int) (*model.CheckAvailabilityResponse, error) {
+			<-release
+			return &model.CheckAvailabilityResponse{KFACode: kfaCode, Price: 15000}, nil
+		},
+	}
+	kc := newTestKimiaFarmaCache(fake)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := kc.CheckAvailabilityAndPriceMedicationByCode(context.Background(), "93001019")
+			if err != nil || resp.Price != 15000 {
+				t.Errorf("lookup = %+v, %v, want price 15000", resp, err)
+			}
+		}()
+	}
+
+	// let every lookup join the in-flight one before it is answered
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := fake.called("93001019"); got != 1 {
+		t.Errorf("kfa code is looked up %d times, want 1", got)
+	}
+}
+
+func TestKimiaFarmaCacheRetryLookupOfCancelledCaller(t *testing.T) {
+	firstStarted := make(chan struct{})
+	fake := &fakeKimiaFarmaRequester{
+		lookup: func(ctx context.Context, kfaCode string, call int) (*model.CheckAvailabilityResponse, error) {
+			if call == 1 {
+				close(firstStarted)
+				<-ctx.Done()
+
+				return nil, ctx.Err()
+			}
+
+			return &model.CheckAvailabilityResponse{KFACode: kfaCode, Price: 15000}, nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		lookup func(kc *KimiaFarmaCacheRequesterImpl, ctx context.Context) (int, error)
+	}{
+		{
+			name: "single lookup",
+			lookup: func(kc *KimiaFarmaCacheRequesterImpl, ctx context.Context) (int, error) {
+				resp, err := kc.CheckAvailabilityAndPriceMedicationByCode(ctx, "93001019")
+				if err != nil {
+					return 0, err
+				}
+
+				return resp.Price, nil
+			},
+		},
+		{
+			name: "batch lookup",
+			lookup: func(kc *KimiaFarmaCacheRequesterImpl, ctx context.Context) (int, error) {
+				resp, err := kc.CheckAvailabilityBatch(ctx, []string{"93001019"})
+				if err != nil {
+					return 0, err
+				}
+
+				if err := resp.Errors["93001019"]; err != nil {
+					return 0, err
+				}
+
+				return resp.Items["93001019"].Price, nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firstStarted = make(chan struct{})
+			fake.calls = nil
+			kc := newTestKimiaFarmaCache(fake)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			firstDone := make(chan error, 1)
+			go func() {
+				_, err := tt.lookup(kc, ctx)
+				firstDone <- err
+			}()
+
+			<-firstStarted
+
+			secondDone := make(chan struct{})
+			var (
+				price int
+				err   error
+			)
+			go func() {
+				defer close(secondDone)
+				price, err = tt.lookup(kc, context.Background())
+			}()
+
+			// second caller is waiting for the first lookup when the first caller is gone
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+
+			if firstErr := <-firstDone; !errors.Is(firstErr, context.Canceled) {
+				t.Errorf("first caller error = %v, want context canceled", firstErr)
+			}
+
+			<-secondDone
+			if err != nil || price != 15000 {
+				t.Fatalf("second caller = %d, %v, want price 15000", price, err)
+			}
+
+			if got := fake.called("93001019"); got != 2 {
+				t.Errorf("kfa code is looked up %d times, want 2", got)
+			}
+		})
+	}
+}
+
+func TestKimiaFarmaCacheWaiterWatchItsOwnContext(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	fake := &fakeKimiaFarmaRequester{
+		lookup: func(ctx context.Context, kfaCode string, call int) (*model.CheckAvailabilityResponse, error) {
+			close(started)
+			<-release
+
+			return &model.CheckAvailabilityResponse{KFACode: kfaCode, Price: 15000}, nil
+		},
+	}
+	kc := newTestKimiaFarmaCache(fake)
+	defer close(release)
+
+	go func() {
+		_, _ = kc.CheckAvailabilityAndPriceMedicationByCode(context.Background(), "93001019")
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := kc.CheckAvailabilityAndPriceMedicationByCode(ctx, "93001019")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want deadline exceeded", err)
+	}
+}
@@ -0,0 +1,405 @@
+package requester
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestKimiaFarmaRequester return kimia farma requester that send every request to handler
+func newTestKimiaFarmaRequester(t *testing.T, handler http.Handler, modify func(cfg *config.KimiaFarma)) *KimiaFarmaRequesterImpl {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	cfg := &config.KimiaFarma{
+		KimiaFarmaURL:    srv.URL,
+		KimiaFarmaAPIKey: "kf-test-key",
+	}
+	if modify != nil {
+		modify(cfg)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	return NewKimiaFarmaRequester(context.Background(), &config.Configuration{
+		Server:     &config.Server{},
+		KimiaFarma: cfg,
+	}, logger, srv.Client())
+}
+
+// availabilityHandler answer single availability lookup from prices, code without price is not found
+func availabilityHandler(prices map[string]int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/medications/"), "/availability")
+
+		price, ok := prices[code]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":"MEDICATION_NOT_FOUND","message":"medication not found"}}`))
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(model.KimiaFarmaAvailabilityResponse{
+			Data: model.CheckAvailabilityResponse{KFACode: code, IsAvailable: true, Price: price},
+		})
+	}
+}
+
+func TestKimiaFarmaCheckAvailabilityBatchFanOut(t *testing.T) {
+	var inFlight, maxInFlight int32
+	prices := map[string]int{"93001019": 15000, "92000456": 8000, "91000330": 22000}
+	handler := availabilityHandler(prices)
+
+	kr := newTestKimiaFarmaRequester(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		handler(w, r)
+	}), func(cfg *config.KimiaFarma) {
+		cfg.KimiaFarmaMaxConcurrency = 2
+	})
+
+	resp, err := kr.CheckAvailabilityBatch(context.Background(), []string{"93001019", "92000456", "", "93001019", "91000330", "99999999"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.Items) != 3 {
+		t.Errorf("got %d items, want 3: %+v", len(resp.Items), resp.Items)
+	}
+
+	for code, price := range prices {
+		if resp.Items[code].Price != price {
+			t.Errorf("price of %s = %d, want %d", code, resp.Items[code].Price, price)
+		}
+	}
+
+	if model.KindOf(resp.Errors["99999999"]) != model.NotFound {
+		t.Errorf("error of 99999999 = %v, want not found", resp.Errors["99999999"])
+	}
+
+	if got := atomic.LoadInt32(&maxInFlight); got > 2 {
+		t.Errorf("max concurrent lookups = %d, want at most 2", got)
+	}
+}
+
+func TestKimiaFarmaCheckAvailabilityBatchFanOutCancelled(t *testing.T) {
+	var requests int32
+	started := make(chan struct{}, 10)
+
+	kr := newTestKimiaFarmaRequester(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		started <- struct{}{}
+		<-r.Context().Done()
+	}), func(cfg *config.KimiaFarma) {
+		cfg.KimiaFarmaMaxConcurrency = 1
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	_, err := kr.CheckAvailabilityBatch(ctx, []string{"93001019", "92000456", "91000330"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context canceled", err)
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("kimia farma received %d lookups, want 1", got)
+	}
+}
+
+func TestKimiaFarmaCheckAvailabilityBatchRequest(t *testing.T) {
+	kr := newTestKimiaFarmaRequester(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/medications/availability" {
+			t.Errorf("request = %s %s, want POST /v1/medications/availability", r.Method, r.URL.Path)
+		}
+
+		var req model.KimiaFarmaBatchAvailabilityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode batch request: %v", err)
+		}
+
+		if strings.Join(req.KFACodes, ",") != "93001019,92000456,91000330,99999999" {
+			t.Errorf("kfa codes = %v, want unique codes in order", req.KFACodes)
+		}
+
+		_, _ = w.Write([]byte(`{
+			"data": [{"kfa_code": "93001019", "is_available": true, "price": 15000, "stock": 120}],
+			"errors": [
+				{"kfa_code": "92000456", "code": "MEDICATION_NOT_FOUND", "message": "medication not found"},
+				{"kfa_code": "91000330", "code": "INVALID_KFA_CODE", "message": "invalid code"}
+			]
+		}`))
+	}), func(cfg *config.KimiaFarma) {
+		cfg.KimiaFarmaBatchEnabled = true
+	})
+
+	resp, err := kr.CheckAvailabilityBatch(context.Background(), []string{"93001019", "92000456", "93001019", "91000330", "99999999"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if item := resp.Items["93001019"]; !item.IsAvailable || item.Price != 15000 {
+		t.Errorf("item 93001019 = %+v, want available for 15000", item)
+	}
+
+	wantErrors := map[string]model.ErrorKind{
+		"92000456": model.NotFound,
+		"91000330": model.Validation,
+		// code the partner doesn't answer is not found
+		"99999999": model.NotFound,
+	}
+	for code, kind := range wantErrors {
+		if got := model.KindOf(resp.Errors[code]); got != kind {
+			t.Errorf("error of %s = %v, want %s", code, resp.Errors[code], kind)
+		}
+	}
+}
+
+func TestKimiaFarmaCheckAvailabilityAndPriceMedicationByCode(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantPrice int
+		wantKind  model.ErrorKind
+	}{
+		{
+			name:      "available",
+			status:    http.StatusOK,
+			body:      `{"data": {"kfa_code": "93001019", "is_available": true, "price": 15000, "stock": 120}}`,
+			wantPrice: 15000,
+		},
+		{
+			name:      "kfa code is not echoed",
+			status:    http.StatusOK,
+			body:      `{"data": {"is_available": true, "price": 15000}}`,
+			wantPrice: 15000,
+		},
+		{
+			name:     "not found",
+			status:   http.StatusNotFound,
+			body:     `{"error": {"code": "MEDICATION_NOT_FOUND", "message": "medication not found"}}`,
+			wantKind: model.NotFound,
+		},
+		{
+			name:     "bad request",
+			status:   http.StatusBadRequest,
+			body:     `{"error": {"code": "INVALID_KFA_CODE", "message": "invalid code"}}`,
+			wantKind: model.Validation,
+		},
+		{
+			name:     "unprocessable entity",
+			status:   http.StatusUnprocessableEntity,
+			body:     `{"error": {"code": "INVALID_KFA_CODE", "message": "invalid code"}}`,
+			wantKind: model.Validation,
+		},
+		{
+			name:     "server error without error envelope",
+			status:   http.StatusBadGateway,
+			body:     `<html>bad gateway</html>`,
+			wantKind: model.Partner,
+		},
+		{
+			name:     "unauthorized",
+			status:   http.StatusUnauthorized,
+			body:     `{"error": {"code": "INVALID_API_KEY", "message": "invalid api key"}}`,
+			wantKind: model.Partner,
+		},
+		{
+			name:     "invalid success body",
+			status:   http.StatusOK,
+			body:     `{"data": [`,
+			wantKind: model.Partner,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr := newTestKimiaFarmaRequester(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.URL.Path != "/v1/medications/93001019/availability" {
+					t.Errorf("request = %s %s, want GET /v1/medications/93001019/availability", r.Method, r.URL.Path)
+				}
+
+				if r.Header.Get("X-API-Key") != "kf-test-key" || r.Header.Get("Accept") != "application/json" {
+					t.Errorf("headers = %v, want api key and json accept", r.Header)
+				}
+
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}), nil)
+
+			resp, err := kr.CheckAvailabilityAndPriceMedicationByCode(context.Background(), "93001019")
+			if tt.wantKind != "" {
+				if model.KindOf(err) != tt.wantKind {
+					t.Fatalf("error = %v, want %s", err, tt.wantKind)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if resp.KFACode != "93001019" || resp.Price != tt.wantPrice || !resp.IsAvailable {
+				t.Errorf("resp = %+v, want available 93001019 for %d", resp, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestKimiaFarmaErrorMessage(t *testing.T) {
+	kr := newTestKimiaFarmaRequester(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error": {"code": "MAINTENANCE", "message": "under maintenance"}}`))
+	}), nil)
+
+	_, err := kr.CheckAvailabilityAndPriceMedicationByCode(context.Background(), "93001019")
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "under maintenance (MAINTENANCE)") {
+		t.Errorf("error = %v, want status code and partner message", err)
+	}
+}
+
+func TestKimiaFarmaRequestTimeout(t *testing.T) {
+	kr := newTestKimiaFarmaRequester(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}), func(cfg *config.KimiaFarma) {
+		cfg.KimiaFarmaTimeout = 1
+	})
+
+	start := time.Now()
+	_, err := kr.CheckAvailabilityAndPriceMedicationByCode(context.Background(), "93001019")
+	if model.KindOf(err) != model.Partner {
+		t.Fatalf("error = %v, want partner error", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("request took %s, want it to stop after the configured timeout", elapsed)
+	}
+}
+
+func TestKimiaFarmaWithoutURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		appEnv   string
+		wantKind model.ErrorKind
+	}{
+		{name: "development use random price", appEnv: "development"},
+		{name: "production must be configured", appEnv: "production", wantKind: model.Partner},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr := NewKimiaFarmaRequester(context.Background(), &config.Configuration{
+				Server:     &config.Server{AppEnv: tt.appEnv},
+				KimiaFarma: &config.KimiaFarma{},
+			}, logrus.New(), http.DefaultClient)
+
+			resp, err := kr.CheckAvailabilityAndPriceMedicationByCode(context.Background(), "93001019")
+			if tt.wantKind != "" {
+				if model.KindOf(err) != tt.wantKind {
+					t.Fatalf("error = %v, want %s", err, tt.wantKind)
+				}
+
+				return
+			}
+
+			if err != nil || resp.Price <= 0 || !resp.IsAvailable {
+				t.Errorf("resp = %+v, %v, want available medication with price", resp, err)
+			}
+		})
+	}
+}
+
+func TestKimiaFarmaCreateDispenseOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantOrderID string
+		wantKind    model.ErrorKind
+	}{
+		{
+			name:        "created",
+			status:      http.StatusCreated,
+			body:        `{"data": {"order_id": "KF-ORD-0001", "reference_id": "12", "status": "RECEIVED"}}`,
+			wantOrderID: "KF-ORD-0001",
+		},
+		{
+			name:     "order id is missing",
+			status:   http.StatusOK,
+			body:     `{"data": {"reference_id": "12", "status": "RECEIVED"}}`,
+			wantKind: model.Partner,
+		},
+		{
+			name:     "rejected",
+			status:   http.StatusUnprocessableEntity,
+			body:     `{"error": {"code": "INVALID_ADDRESS", "message": "address is outside delivery area"}}`,
+			wantKind: model.Validation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr := newTestKimiaFarmaRequester(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/v1/dispense-orders" {
+					t.Errorf("request = %s %s, want POST /v1/dispense-orders", r.Method, r.URL.Path)
+				}
+
+				if r.Header.Get("Idempotency-Key") != "dispense-order-12" || r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("headers = %v, want idempotency key and json content type", r.Header)
+				}
+
+				var req model.KimiaFarmaDispenseOrderRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ReferenceID != "12" || len(req.Items) != 1 {
+					t.Errorf("body = %+v, %v, want order 12 with 1 item", req, err)
+				}
+
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}), nil)
+
+			order, err := kr.CreateDispenseOrder(context.Background(), &model.KimiaFarmaDispenseOrderRequest{
+				ReferenceID: "12",
+				Items:       []model.KimiaFarmaDispenseItem{{KFACode: "93001019", Quantity: 10, Price: 15000}},
+			}, "dispense-order-12")
+			if tt.wantKind != "" {
+				if model.KindOf(err) != tt.wantKind {
+					t.Fatalf("error = %v, want %s", err, tt.wantKind)
+				}
+
+				return
+			}
+
+			if err != nil || order.OrderID != tt.wantOrderID {
+				t.Errorf("order = %+v, %v, want %s", order, err, tt.wantOrderID)
+			}
+		})
+	}
+}
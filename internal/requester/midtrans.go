@@ -0,0 +1,469 @@
+package requester
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"crypto/subtle"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/helper"
+	"e-resep-be/internal/model"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultMidtransTimeout is used when MIDTRANS_TIMEOUT is not configured
+	defaultMidtransTimeout = 10 * time.Second
+
+	// midtransTimeLayout is the layout of every time sent by midtrans, in western indonesia time
+	midtransTimeLayout = "2006-01-02 15:04:05"
+
+	// midtrans transaction status
+	midtransStatusCapture       = "capture"
+	midtransStatusSettlement    = "settlement"
+	midtransStatusPending       = "pending"
+	midtransStatusDeny          = "deny"
+	midtransStatusCancel        = "cancel"
+	midtransStatusExpire        = "expire"
+	midtransStatusFailure       = "failure"
+	midtransStatusRefund        = "refund"
+	midtransStatusPartialRefund = "partial_refund"
+
+	// midtransFraudStatusAccept is fraud status of card capture that is safe to be settled
+	midtransFraudStatusAccept = "accept"
+	// midtransStatusCodeNotFound is status code inside body of status endpoint when order doesn't exist
+	midtransStatusCodeNotFound = "404"
+)
+
+// Midtrans wire contract
+//
+// Create snap transaction, patient pay on midtrans snap page limited to enabled payments:
+//
+//	POST {MIDTRANS_SNAP_URL}/v1/transactions
+//	Authorization: Basic base64({MIDTRANS_SERVER_KEY}:)
+//
+//	{"transaction_details": {"order_id": "12", "gross_amount": 20000}, "enabled_payments": ["bca_va"], ...}
+//
+// On 201 the body is:
+//
+//	{"token": "66e4fa55-fdac-4ef9-91b5-733b97d1b862", "redirect_url": "https://app.midtrans.com/snap/v4/redirection/66e4fa55"}
+//
+// Get, expire and refund transaction by order id:
+//
+//	GET  {MIDTRANS_URL}/v2/{order_id}/status
+//	POST {MIDTRANS_URL}/v2/{order_id}/expire
+//	POST {MIDTRANS_URL}/v2/{order_id}/refund  {"refund_key": "refund-3", "amount": 5000, "reason": "..."}
+//
+// Each of them return the transaction status, unknown order is reported with "404" status code inside the body:
+//
+//	{"status_code": "200", "order_id": "12", "transaction_status": "settlement", "gross_amount": "20000.00", ...}
+//
+// Payment notification is sent with the same body together with signature key, the sha512 of
+// order_id + status_code + gross_amount + server key.
+
+type (
+	// MidtransPaymentGatewayImpl is payment gateway backed by midtrans snap, the order id is our payment id
+	MidtransPaymentGatewayImpl struct {
+		Context    context.Context
+		Config     *config.Configuration
+		Logger     *logrus.Logger
+		HTTPClient *http.Client
+	}
+)
+
+// midtransEnabledPayments map virtual account and e-wallet channel into midtrans snap payment type
+var midtransEnabledPayments = map[model.PaymentMethodEnum]map[string]string{
+	model.PaymentMethodEnumVirtualAccount: {
+		"BCA":     "bca_va",
+		"BNI":     "bni_va",
+		"BRI":     "bri_va",
+		"CIMB":    "cimb_va",
+		"PERMATA": "permata_va",
+		"MANDIRI": "echannel",
+	},
+	model.PaymentMethodEnumEwallet: {
+		"SHOPEEPAY": "shopeepay",
+	},
+	model.PaymentMethodEnumQRIS: {
+		"QRIS": "other_qris",
+	},
+}
+
+// NewMidtransPaymentGateway return new instances midtrans payment gateway
+func NewMidtransPaymentGateway(ctx context.Context, config *config.Configuration, logger *logrus.Logger, httpCli *http.Client) *MidtransPaymentGatewayImpl {
+	return &MidtransPaymentGatewayImpl{
+		Context:    ctx,
+		Config:     config,
+		Logger:     logger,
+		HTTPClient: httpCli,
+	}
+}
+
+// CreateCharge create midtrans snap transaction, direct charge limit snap page to the chosen payment method
+func (mg *MidtransPaymentGatewayImpl) CreateCharge(ctx context.Context, req *model.ChargeRequest) (*model.Charge, error) {
+	snapReq := model.MidtransSnapRequest{
+		TransactionDetails: model.MidtransTransactionDetails{
+			OrderID:     req.ReferenceID,
+			GrossAmount: req.Amount,
+		},
+		CustomerDetails: &model.MidtransCustomerDetails{
+			FirstName: req.Customer.Name,
+			Email:     req.Customer.Email,
+			Phone:     req.Customer.PhoneNumber,
+		},
+		Callbacks: &model.MidtransCallbacks{
+			Finish: req.SuccessRedirectURL,
+		},
+		Expiry: &model.MidtransExpiry{
+			Unit:     "minute",
+			Duration: int(req.Duration.Minutes()),
+		},
+	}
+
+	// gross amount must equal the sum of item details
+	for _, item := range req.Items {
+		snapReq.ItemDetails = append(snapReq.ItemDetails, model.MidtransItemDetail{
+			ID:       item.ReferenceID,
+			Price:    item.Price,
+			Quantity: item.Quantity,
+			Name:     item.Name,
+		})
+	}
+
+	if req.ShippingCost > 0 {
+		snapReq.ItemDetails = append(snapReq.ItemDetails, model.MidtransItemDetail{
+			ID:       "shipping",
+			Price:    req.ShippingCost,
+			Quantity: 1,
+			Name:     "Ongkos Kirim",
+		})
+	}
+
+	if req.Method.IsDirectCharge() {
+		enabledPayment, ok := midtransEnabledPayments[req.Method][req.ChannelCode]
+		if !ok {
+			return nil, model.NewError(model.Validation, fmt.Sprintf("%s %s is not supported by midtrans", req.Method, req.ChannelCode))
+		}
+
+		snapReq.EnabledPayments = []string{enabledPayment}
+	}
+
+	reqBytes, err := json.Marshal(snapReq)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling snap request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/transactions", strings.TrimSuffix(mg.Config.Midtrans.MidtransSnapURL, "/"))
+
+	var resp model.MidtransSnapResponse
+	err = mg.doRequest(ctx, http.MethodPost, endpoint, bytes.NewReader(reqBytes), &resp)
+	if err != nil {
+		mg.Logger.Error("MidtransPaymentGatewayImpl.CreateCharge ERROR ", err)
+
+		return nil, err
+	}
+
+	mg.Logger.Info("Success Create Snap Transaction ", req.ReferenceID)
+
+	expiresAt := time.Now().Add(req.Duration)
+
+	return &model.Charge{
+		PartnerID:     req.ReferenceID,
+		ReferenceID:   req.ReferenceID,
+		Status:        model.ChargeStatusEnumPending,
+		PartnerStatus: midtransStatusPending,
+		CheckoutURL:   resp.RedirectURL,
+		ExpiresAt:     &expiresAt,
+		UpdatedAt:     time.Now(),
+	}, nil
+}
+
+func (mg *MidtransPaymentGatewayImpl) GetCharge(ctx context.Context, payment *model.Payment) (*model.Charge, error) {
+	status, err := mg.getTransactionStatus(ctx, payment.PartnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.StatusCode == midtransStatusCodeNotFound {
+		return nil, model.NewError(model.NotFound, fmt.Sprintf("midtrans order %s not found", payment.PartnerID))
+	}
+
+	return midtransStatusToCharge(status)
+}
+
+// FindChargeByReferenceID find snap transaction by payment id, snap transaction only exist on midtrans once patient choose payment method
+func (mg *MidtransPaymentGatewayImpl) FindChargeByReferenceID(ctx context.Context, payment *model.Payment) (*model.Charge, error) {
+	status, err := mg.getTransactionStatus(ctx, fmt.Sprintf("%d", payment.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	if status.StatusCode == midtransStatusCodeNotFound {
+		return nil, nil
+	}
+
+	return midtransStatusToCharge(status)
+}
+
+// CancelCharge expire pending midtrans transaction, midtrans refuse to expire transaction that is already paid
+func (mg *MidtransPaymentGatewayImpl) CancelCharge(ctx context.Context, payment *model.Payment) (*model.Charge, error) {
+	endpoint := fmt.Sprintf("%s/v2/%s/expire", strings.TrimSuffix(mg.Config.Midtrans.MidtransURL, "/"), url.PathEscape(payment.PartnerID))
+
+	var resp model.MidtransTransactionStatus
+	err := mg.doRequest(ctx, http.MethodPost, endpoint, nil, &resp)
+	if err != nil {
+		mg.Logger.Error("MidtransPaymentGatewayImpl.CancelCharge ERROR ", err)
+
+		return nil, err
+	}
+
+	// patient never open snap page, so there is no transaction to be expired on midtrans
+	if resp.StatusCode == midtransStatusCodeNotFound {
+		return &model.Charge{
+			PartnerID:     payment.PartnerID,
+			ReferenceID:   payment.PartnerID,
+			Status:        model.ChargeStatusEnumExpired,
+			PartnerStatus: midtransStatusExpire,
+			UpdatedAt:     time.Now(),
+		}, nil
+	}
+
+	return midtransStatusToCharge(&resp)
+}
+
+// CreateRefund refund paid midtrans transaction, midtrans refund synchronously so the refund is succeeded right away
+func (mg *MidtransPaymentGatewayImpl) CreateRefund(ctx context.Context, payment *model.Payment, req *model.RefundChargeRequest) (*model.ChargeRefund, error) {
+	refundKey := fmt.Sprintf("refund-%s", req.ReferenceID)
+
+	reqBytes, err := json.Marshal(model.MidtransRefundRequest{
+		RefundKey: refundKey,
+		Amount:    req.Amount,
+		Reason:    req.Reason,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling refund request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v2/%s/refund", strings.TrimSuffix(mg.Config.Midtrans.MidtransURL, "/"), url.PathEscape(payment.PartnerID))
+
+	var resp model.MidtransTransactionStatus
+	err = mg.doRequest(ctx, http.MethodPost, endpoint, bytes.NewReader(reqBytes), &resp)
+	if err != nil {
+		mg.Logger.Error("MidtransPaymentGatewayImpl.CreateRefund ERROR ", err)
+
+		return nil, err
+	}
+
+	if resp.TransactionStatus != midtransStatusRefund && resp.TransactionStatus != midtransStatusPartialRefund {
+		return nil, model.Rejected(model.NewError(model.Partner, fmt.Sprintf("midtrans refund is rejected: %s %s", resp.StatusCode, resp.StatusMessage)))
+	}
+
+	return &model.ChargeRefund{
+		PartnerID:       refundKey,
+		ReferenceID:     req.ReferenceID,
+		ChargePartnerID: payment.PartnerID,
+		Status:          model.RefundStatusEnumSucceeded,
+		PartnerStatus:   resp.TransactionStatus,
+	}, nil
+}
+
+// ParseChargeNotification verify signature of midtrans notification and parse it
+func (mg *MidtransPaymentGatewayImpl) ParseChargeNotification(ctx context.Context, callbackID string, rawPayload []byte) (*model.ChargeNotification, error) {
+	var req model.MidtransTransactionStatus
+	if err := json.Unmarshal(rawPayload, &req); err != nil {
+		return nil, model.NewError(model.Validation, err.Error())
+	}
+
+	// every signature would be computable by anyone without server key
+	if mg.Config.Midtrans.MidtransServerKey == "" {
+		return nil, model.NewError(model.Validation, "midtrans server key is not configured")
+	}
+
+	signature := sha512.Sum512([]byte(req.OrderID + req.StatusCode + req.GrossAmount + mg.Config.Midtrans.MidtransServerKey))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(signature[:])), []byte(req.SignatureKey)) != 1 {
+		mg.Logger.Error("MidtransPaymentGatewayImpl.ParseChargeNotification ERROR invalid signature key of order ", req.OrderID)
+
+		return nil, model.NewError(model.Validation, "invalid signature key")
+	}
+
+	// midtrans doesn't send notification id, use transaction state as callback identity
+	if callbackID == "" {
+		callbackID = fmt.Sprintf("%s:%s:%s", req.TransactionID, req.TransactionStatus, req.StatusCode)
+	}
+
+	charge, err := midtransStatusToCharge(&req)
+	if err != nil {
+		return nil, model.NewError(model.Validation, err.Error())
+	}
+
+	return &model.ChargeNotification{
+		CallbackID: callbackID,
+		Charge:     *charge,
+	}, nil
+}
+
+// ParseRefundNotification is not supported, midtrans refund is finished synchronously when it is requested
+func (mg *MidtransPaymentGatewayImpl) ParseRefundNotification(ctx context.Context, callbackID string, rawPayload []byte) (*model.RefundNotification, error) {
+	return nil, model.NewError(model.Validation, "midtrans doesn't send refund notification")
+}
+
+func (mg *MidtransPaymentGatewayImpl) getTransactionStatus(ctx context.Context, orderID string) (*model.MidtransTransactionStatus, error) {
+	endpoint := fmt.Sprintf("%s/v2/%s/status", strings.TrimSuffix(mg.Config.Midtrans.MidtransURL, "/"), url.PathEscape(orderID))
+
+	var resp model.MidtransTransactionStatus
+	err := mg.doRequest(ctx, http.MethodGet, endpoint, nil, &resp)
+	if err != nil {
+		mg.Logger.Error("MidtransPaymentGatewayImpl.getTransactionStatus ERROR ", err)
+
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// doRequest call midtrans authenticated with server key and decode the json response into dest
+func (mg *MidtransPaymentGatewayImpl) doRequest(ctx context.Context, method, endpoint string, body io.Reader, dest interface{}) error {
+	timeout := defaultMidtransTimeout
+	if mg.Config.Midtrans.MidtransTimeout > 0 {
+		timeout = time.Duration(mg.Config.Midtrans.MidtransTimeout) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.SetBasicAuth(mg.Config.Midtrans.MidtransServerKey, "")
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := mg.HTTPClient.Do(req)
+	if err != nil {
+		return model.NewError(model.Partner, fmt.Sprintf("error sending request to midtrans: %v", err))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return model.NewError(model.Partner, fmt.Sprintf("error reading midtrans response: %v", err))
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResp model.MidtransErrorResponse
+		msg := http.StatusText(resp.StatusCode)
+		if err := json.Unmarshal(respBody, &errResp); err == nil && len(errResp.ErrorMessages) > 0 {
+			msg = strings.Join(errResp.ErrorMessages, ", ")
+		}
+
+		if resp.StatusCode == http.StatusBadRequest {
+			return model.Rejected(model.NewError(model.Validation, msg))
+		}
+
+		err := model.NewError(model.Partner, fmt.Sprintf("midtrans responded %d: %s", resp.StatusCode, msg))
+
+		// 4xx is a definite refusal, midtrans doesn't act on the request
+		if resp.StatusCode < http.StatusInternalServerError {
+			return model.Rejected(err)
+		}
+
+		return err
+	}
+
+	if err := json.Unmarshal(respBody, dest); err != nil {
+		return model.NewError(model.Partner, fmt.Sprintf("error decoding midtrans response: %v", err))
+	}
+
+	return nil
+}
+
+// midtransStatusToCharge convert midtrans transaction status into charge
+func midtransStatusToCharge(status *model.MidtransTransactionStatus) (*model.Charge, error) {
+	charge := model.Charge{
+		Gateway:       model.PaymentGatewayEnumMidtrans,
+		PartnerID:     status.OrderID,
+		ReferenceID:   status.OrderID,
+		Status:        mapMidtransStatus(status.TransactionStatus, status.FraudStatus),
+		PartnerStatus: status.TransactionStatus,
+		UpdatedAt:     time.Now(),
+	}
+
+	// gross amount is sent with two decimals, e.g. "150000.00"
+	if status.GrossAmount != "" {
+		grossAmount, err := strconv.ParseFloat(status.GrossAmount, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gross amount %q: %w", status.GrossAmount, err)
+		}
+
+		charge.Amount = int(math.Round(grossAmount))
+	}
+
+	if len(status.VANumbers) > 0 {
+		charge.VirtualAccountNumber = status.VANumbers[0].VANumber
+	} else if status.PermataVANumber != "" {
+		charge.VirtualAccountNumber = status.PermataVANumber
+	}
+
+	if status.ExpiryTime != "" {
+		expiresAt, err := time.ParseInLocation(midtransTimeLayout, status.ExpiryTime, helper.TimezoneJakarta)
+		if err != nil {
+			return nil, err
+		}
+
+		charge.ExpiresAt = &expiresAt
+	}
+
+	if charge.Status == model.ChargeStatusEnumPaid {
+		paidAt := status.SettlementTime
+		if paidAt == "" {
+			paidAt = status.TransactionTime
+		}
+
+		if paidAt != "" {
+			parsePaidAt, err := time.ParseInLocation(midtransTimeLayout, paidAt, helper.TimezoneJakarta)
+			if err != nil {
+				return nil, err
+			}
+
+			charge.PaidAt = &parsePaidAt
+			charge.UpdatedAt = parsePaidAt
+		}
+	}
+
+	return &charge, nil
+}
+
+// mapMidtransStatus return charge status of midtrans transaction status, refunded transaction was paid before
+func mapMidtransStatus(transactionStatus, fraudStatus string) model.ChargeStatusEnum {
+	switch transactionStatus {
+	case midtransStatusSettlement, midtransStatusRefund, midtransStatusPartialRefund:
+		return model.ChargeStatusEnumPaid
+	case midtransStatusCapture:
+		if fraudStatus == "" || fraudStatus == midtransFraudStatusAccept {
+			return model.ChargeStatusEnumPaid
+		}
+
+		return model.ChargeStatusEnumPending
+	case midtransStatusExpire:
+		return model.ChargeStatusEnumExpired
+	case midtransStatusDeny, midtransStatusCancel, midtransStatusFailure:
+		return model.ChargeStatusEnumFailed
+	default:
+		return model.ChargeStatusEnumPending
+	}
+}
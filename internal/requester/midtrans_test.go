@@ -0,0 +1,165 @@
+package requester
+
+import (
+	"context"
+	"crypto/sha512"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// midtransSignature return signature key of midtrans notification signed with the given server key
+func midtransSignature(orderID, statusCode, grossAmount, serverKey string) string {
+	signature := sha512.Sum512([]byte(orderID + statusCode + grossAmount + serverKey))
+	return hex.EncodeToString(signature[:])
+}
+
+func TestMidtransParseChargeNotification(t *testing.T) {
+	const serverKey = "SB-Mid-server-test"
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	newGateway := func(key string) *MidtransPaymentGatewayImpl {
+		return NewMidtransPaymentGateway(context.Background(), &config.Configuration{
+			Midtrans: &config.Midtrans{MidtransServerKey: key},
+		}, logger, nil)
+	}
+
+	settlement := model.MidtransTransactionStatus{
+		StatusCode:        "200",
+		TransactionID:     "b6a4f1c2",
+		OrderID:           "42",
+		GrossAmount:       "150000.00",
+		TransactionStatus: "settlement",
+		TransactionTime:   "2024-05-01 10:00:00",
+		SettlementTime:    "2024-05-01 10:05:00",
+	}
+
+	tests := []struct {
+		name       string
+		serverKey  string
+		modify     func(status *model.MidtransTransactionStatus)
+		wantErr    bool
+		wantAmount int
+	}{
+		{
+			name:      "valid signature",
+			serverKey: serverKey,
+			modify: func(status *model.MidtransTransactionStatus) {
+				status.SignatureKey = midtransSignature(status.OrderID, status.StatusCode, status.GrossAmount, serverKey)
+			},
+			wantAmount: 150000,
+		},
+		{
+			name:      "signed with other server key",
+			serverKey: serverKey,
+			modify: func(status *model.MidtransTransactionStatus) {
+				status.SignatureKey = midtransSignature(status.OrderID, status.StatusCode, status.GrossAmount, "other-key")
+			},
+			wantErr: true,
+		},
+		{
+			name:      "gross amount is changed after signing",
+			serverKey: serverKey,
+			modify: func(status *model.MidtransTransactionStatus) {
+				status.SignatureKey = midtransSignature(status.OrderID, status.StatusCode, status.GrossAmount, serverKey)
+				status.GrossAmount = "1.00"
+			},
+			wantErr: true,
+		},
+		{
+			name:      "missing signature",
+			serverKey: serverKey,
+			modify:    func(status *model.MidtransTransactionStatus) {},
+			wantErr:   true,
+		},
+		{
+			name:      "server key is not configured",
+			serverKey: "",
+			modify: func(status *model.MidtransTransactionStatus) {
+				status.SignatureKey = midtransSignature(status.OrderID, status.StatusCode, status.GrossAmount, "")
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := settlement
+			tt.modify(&status)
+
+			payload, err := json.Marshal(status)
+			if err != nil {
+				t.Fatalf("marshal notification: %v", err)
+			}
+
+			notification, err := newGateway(tt.serverKey).ParseChargeNotification(context.Background(), "", payload)
+			if tt.wantErr {
+				if model.KindOf(err) != model.Validation {
+					t.Fatalf("error = %v, want validation error", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			charge := notification.Charge
+			if charge.Gateway != model.PaymentGatewayEnumMidtrans || charge.ReferenceID != status.OrderID ||
+				charge.Status != model.ChargeStatusEnumPaid || charge.Amount != tt.wantAmount {
+				t.Errorf("charge = %+v, want paid midtrans charge of order %s for %d", charge, status.OrderID, tt.wantAmount)
+			}
+		})
+	}
+}
+
+func TestMidtransCreateRefundRejection(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		wantRejected bool
+	}{
+		{name: "refund is denied", status: http.StatusOK, body: `{"status_code":"412","status_message":"Merchant cannot modify the status of the transaction","transaction_status":"settlement"}`, wantRejected: true},
+		{name: "bad request", status: http.StatusBadRequest, body: `{"error_messages":["amount exceed"]}`, wantRejected: true},
+		{name: "unauthorized", status: http.StatusUnauthorized, body: `{}`, wantRejected: true},
+		{name: "server error", status: http.StatusInternalServerError, body: `{}`, wantRejected: false},
+		{name: "gateway timeout", status: http.StatusGatewayTimeout, body: `{}`, wantRejected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			gateway := NewMidtransPaymentGateway(context.Background(), &config.Configuration{
+				Midtrans: &config.Midtrans{MidtransURL: server.URL, MidtransServerKey: "SB-Mid-server-test"},
+			}, logger, server.Client())
+
+			_, err := gateway.CreateRefund(context.Background(), &model.Payment{PartnerID: "42"}, &model.RefundChargeRequest{ReferenceID: "7", Amount: 1000})
+			if err == nil {
+				t.Fatal("CreateRefund() error = nil, want error")
+			}
+
+			if got := model.IsRejected(err); got != tt.wantRejected {
+				t.Errorf("IsRejected(%v) = %v, want %v", err, got, tt.wantRejected)
+			}
+		})
+	}
+}
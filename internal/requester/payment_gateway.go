@@ -0,0 +1,70 @@
+package requester
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"fmt"
+	"strings"
+)
+
+type (
+	// PaymentGateway is an interface that has all the function to be implemented by every payment gateway,
+	// services only talk to payment gateway through it so they are not bound to a single provider
+	PaymentGateway interface {
+		CreateCharge(ctx context.Context, req *model.ChargeRequest) (*model.Charge, error)
+		GetCharge(ctx context.Context, payment *model.Payment) (*model.Charge, error)
+		// FindChargeByReferenceID return nil charge when payment never reach payment gateway
+		FindChargeByReferenceID(ctx context.Context, payment *model.Payment) (*model.Charge, error)
+		CancelCharge(ctx context.Context, payment *model.Payment) (*model.Charge, error)
+		CreateRefund(ctx context.Context, payment *model.Payment, req *model.RefundChargeRequest) (*model.ChargeRefund, error)
+		ParseChargeNotification(ctx context.Context, callbackID string, rawPayload []byte) (*model.ChargeNotification, error)
+		ParseRefundNotification(ctx context.Context, callbackID string, rawPayload []byte) (*model.RefundNotification, error)
+	}
+
+	// PaymentGatewayProvider is an interface that has all the function to be implemented inside payment gateway provider
+	PaymentGatewayProvider interface {
+		// Active return payment gateway of new payments
+		Active() (model.PaymentGatewayEnum, PaymentGateway)
+		// Get return payment gateway of existing payment, payment keep using the gateway that charge it
+		Get(name model.PaymentGatewayEnum) (PaymentGateway, error)
+	}
+
+	// PaymentGatewayProviderImpl is an app payment gateway provider struct that consists of every configured payment gateway
+	PaymentGatewayProviderImpl struct {
+		Config   *config.Configuration
+		Gateways map[model.PaymentGatewayEnum]PaymentGateway
+	}
+)
+
+// NewPaymentGatewayProvider return new instances payment gateway provider
+func NewPaymentGatewayProvider(config *config.Configuration, gateways map[model.PaymentGatewayEnum]PaymentGateway) *PaymentGatewayProviderImpl {
+	return &PaymentGatewayProviderImpl{
+		Config:   config,
+		Gateways: gateways,
+	}
+}
+
+// Active return payment gateway selected by PAYMENT_GATEWAY, default to xendit
+func (pp *PaymentGatewayProviderImpl) Active() (model.PaymentGatewayEnum, PaymentGateway) {
+	name := model.PaymentGatewayEnum(strings.ToUpper(pp.Config.PaymentGateway.PaymentGateway))
+	if _, ok := pp.Gateways[name]; !ok {
+		name = model.PaymentGatewayEnumXendit
+	}
+
+	return name, pp.Gateways[name]
+}
+
+func (pp *PaymentGatewayProviderImpl) Get(name model.PaymentGatewayEnum) (PaymentGateway, error) {
+	// payments created before gateway is recorded are charged by xendit
+	if name == "" {
+		name = model.PaymentGatewayEnumXendit
+	}
+
+	gateway, ok := pp.Gateways[name]
+	if !ok {
+		return nil, fmt.Errorf("payment gateway %s is not configured", name)
+	}
+
+	return gateway, nil
+}
@@ -53,7 +53,7 @@ func (wr *WhatsappRequesterImpl) SendMessageByRecipentNumber(ctx context.Context
 		return fmt.Errorf("error marshaling message: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", wr.Config.Whatsapp.WaBroadcastURL, bytes.NewBuffer(sendMesssageReqBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wr.Config.Whatsapp.WaBroadcastURL, bytes.NewBuffer(sendMesssageReqBytes))
 	if err != nil {
 		return fmt.Errorf("error creating request: %v", err)
 	}
@@ -84,6 +84,17 @@ Periksa Resep Anda di : %s
 
 Jika Anda memiliki pertanyaan, jangan ragu untuk menghubungi tim dukungan kami.
 
+Terima kasih,
+*E-RESEP*`
+	case model.TemplateCancelPrescription:
+		return `Halo *%s*,
+
+Resep Anda telah dibatalkan oleh dokter atau klinik, sehingga obat pada resep tersebut tidak dapat ditebus. Detail resep dapat dilihat pada tautan berikut:
+
+%s
+
+Silakan hubungi dokter atau klinik Anda untuk informasi lebih lanjut.
+
 Terima kasih,
 *E-RESEP*`
 	default:
@@ -4,10 +4,15 @@ import (
 	"context"
 	"e-resep-be/internal/config"
 	"encoding/json"
+	"io"
+	"net/http"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/xendit/xendit-go/v6"
 	"github.com/xendit/xendit-go/v6/invoice"
+	"github.com/xendit/xendit-go/v6/payment_request"
+	"github.com/xendit/xendit-go/v6/refund"
 )
 
 type (
@@ -16,6 +21,30 @@ type (
 		CreateInvoice(ctx context.Context, req invoice.CreateInvoiceRequest) (*invoice.Invoice, error)
 		GetInvoiceByID(ctx context.Context, invoiceID string) (*invoice.Invoice, error)
 		ExpireInvoiceByID(ctx context.Context, invoiceID string) (*invoice.Invoice, error)
+		GetInvoicesByExternalID(ctx context.Context, externalID string) ([]invoice.Invoice, error)
+		CreateRefund(ctx context.Context, req refund.CreateRefund, idempotencyKey string) (*refund.Refund, error)
+		CreatePaymentRequest(ctx context.Context, req payment_request.PaymentRequestParameters, idempotencyKey string) (*XenditPaymentRequest, error)
+		GetPaymentRequestByID(ctx context.Context, paymentRequestID string) (*XenditPaymentRequest, error)
+		GetPaymentRequestsByReferenceID(ctx context.Context, referenceID string) ([]XenditPaymentRequest, error)
+	}
+
+	// XenditPaymentRequest is xendit payment request together with the fields the sdk model doesn't carry
+	XenditPaymentRequest struct {
+		payment_request.PaymentRequest
+
+		// EwalletExpiresAt is the deadline for patient to authorize the e-wallet checkout, nil for other payment method
+		EwalletExpiresAt *time.Time
+	}
+
+	// xenditPaymentRequestExtra is the part of xendit payment request response that is dropped by the sdk model
+	xenditPaymentRequestExtra struct {
+		PaymentMethod struct {
+			Ewallet *struct {
+				ChannelProperties struct {
+					ExpiresAt *time.Time `json:"expires_at"`
+				} `json:"channel_properties"`
+			} `json:"ewallet"`
+		} `json:"payment_method"`
 	}
 
 	// XenditRequesterImpl is an app xendit struct that consists of all the dependencies needed for xendit requester
@@ -88,3 +117,142 @@ func (xr *XenditRequesterImpl) ExpireInvoiceByID(ctx context.Context, invoiceID
 
 	return resp, nil
 }
+
+func (xr *XenditRequesterImpl) GetInvoicesByExternalID(ctx context.Context, externalID string) ([]invoice.Invoice, error) {
+	resp, _, err := xr.XenditSDK.InvoiceApi.GetInvoices(ctx).
+		ExternalId(externalID).
+		Execute()
+	if err != nil {
+		xr.Logger.Error("XenditRequesterImpl.GetInvoicesByExternalID.Execute ERROR Message ", err.Error())
+
+		b, _ := json.Marshal(err.FullError())
+		xr.Logger.Error("XenditRequesterImpl.GetInvoicesByExternalID.Execute Full Error Struct", string(b))
+
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// CreateRefund request refund of a paid invoice, request with the same idempotency key is only refunded once
+func (xr *XenditRequesterImpl) CreateRefund(ctx context.Context, req refund.CreateRefund, idempotencyKey string) (*refund.Refund, error) {
+	resp, _, err := xr.XenditSDK.RefundApi.CreateRefund(ctx).
+		IdempotencyKey(idempotencyKey).
+		CreateRefund(req).
+		Execute()
+	if err != nil {
+		xr.Logger.Error("XenditRequesterImpl.CreateRefund.Execute ERROR Message ", err.Error())
+
+		b, _ := json.Marshal(err.FullError())
+		xr.Logger.Error("XenditRequesterImpl.CreateRefund.Execute Full Error Struct", string(b))
+
+		return nil, err
+	}
+
+	xr.Logger.Info("Success Create Refund ", resp)
+
+	return resp, nil
+}
+
+// CreatePaymentRequest charge patient directly with the chosen payment method, request with the same idempotency key is only charged once
+func (xr *XenditRequesterImpl) CreatePaymentRequest(ctx context.Context, req payment_request.PaymentRequestParameters, idempotencyKey string) (*XenditPaymentRequest, error) {
+	resp, httpResp, err := xr.XenditSDK.PaymentRequestApi.CreatePaymentRequest(ctx).
+		IdempotencyKey(idempotencyKey).
+		PaymentRequestParameters(req).
+		Execute()
+	if err != nil {
+		xr.Logger.Error("XenditRequesterImpl.CreatePaymentRequest.Execute ERROR Message ", err.Error())
+
+		b, _ := json.Marshal(err.FullError())
+		xr.Logger.Error("XenditRequesterImpl.CreatePaymentRequest.Execute Full Error Struct", string(b))
+
+		return nil, err
+	}
+
+	xr.Logger.Info("Success Create Payment Request ", resp)
+
+	var extra xenditPaymentRequestExtra
+	xr.decodeRawResponse("CreatePaymentRequest", httpResp, &extra)
+
+	return newXenditPaymentRequest(*resp, extra), nil
+}
+
+func (xr *XenditRequesterImpl) GetPaymentRequestByID(ctx context.Context, paymentRequestID string) (*XenditPaymentRequest, error) {
+	resp, httpResp, err := xr.XenditSDK.PaymentRequestApi.GetPaymentRequestByID(ctx, paymentRequestID).
+		Execute()
+	if err != nil {
+		xr.Logger.Error("XenditRequesterImpl.GetPaymentRequestByID.Execute ERROR Message ", err.Error())
+
+		b, _ := json.Marshal(err.FullError())
+		xr.Logger.Error("XenditRequesterImpl.GetPaymentRequestByID.Execute Full Error Struct", string(b))
+
+		return nil, err
+	}
+
+	xr.Logger.Info("Success Get Payment Request ", resp)
+
+	var extra xenditPaymentRequestExtra
+	xr.decodeRawResponse("GetPaymentRequestByID", httpResp, &extra)
+
+	return newXenditPaymentRequest(*resp, extra), nil
+}
+
+func (xr *XenditRequesterImpl) GetPaymentRequestsByReferenceID(ctx context.Context, referenceID string) ([]XenditPaymentRequest, error) {
+	resp, httpResp, err := xr.XenditSDK.PaymentRequestApi.GetAllPaymentRequests(ctx).
+		ReferenceId([]string{referenceID}).
+		Execute()
+	if err != nil {
+		xr.Logger.Error("XenditRequesterImpl.GetPaymentRequestsByReferenceID.Execute ERROR Message ", err.Error())
+
+		b, _ := json.Marshal(err.FullError())
+		xr.Logger.Error("XenditRequesterImpl.GetPaymentRequestsByReferenceID.Execute Full Error Struct", string(b))
+
+		return nil, err
+	}
+
+	var extra struct {
+		Data []xenditPaymentRequestExtra `json:"data"`
+	}
+	xr.decodeRawResponse("GetPaymentRequestsByReferenceID", httpResp, &extra)
+
+	paymentRequests := make([]XenditPaymentRequest, 0, len(resp.Data))
+	for i, paymentRequest := range resp.Data {
+		var paymentRequestExtra xenditPaymentRequestExtra
+		if i < len(extra.Data) {
+			paymentRequestExtra = extra.Data[i]
+		}
+
+		paymentRequests = append(paymentRequests, *newXenditPaymentRequest(paymentRequest, paymentRequestExtra))
+	}
+
+	return paymentRequests, nil
+}
+
+// decodeRawResponse decode the fields the sdk model doesn't carry from the response body, the sdk buffers the body so it
+// can be read again. the fields are optional, so failing to decode them is only logged
+func (xr *XenditRequesterImpl) decodeRawResponse(operation string, httpResp *http.Response, dst interface{}) {
+	if httpResp == nil || httpResp.Body == nil {
+		return
+	}
+
+	b, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		xr.Logger.Warn("XenditRequesterImpl.", operation, " read raw response ERROR Message ", err.Error())
+		return
+	}
+
+	if err := json.Unmarshal(b, dst); err != nil {
+		xr.Logger.Warn("XenditRequesterImpl.", operation, " decode raw response ERROR Message ", err.Error())
+	}
+}
+
+// newXenditPaymentRequest combine payment request of the sdk with the fields decoded from the raw response
+func newXenditPaymentRequest(paymentRequest payment_request.PaymentRequest, extra xenditPaymentRequestExtra) *XenditPaymentRequest {
+	result := XenditPaymentRequest{PaymentRequest: paymentRequest}
+
+	if ewallet := extra.PaymentMethod.Ewallet; ewallet != nil {
+		result.EwalletExpiresAt = ewallet.ChannelProperties.ExpiresAt
+	}
+
+	return &result
+}
@@ -0,0 +1,542 @@
+package requester
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/helper"
+	"e-resep-be/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xendit/xendit-go/v6/common"
+	"github.com/xendit/xendit-go/v6/invoice"
+	"github.com/xendit/xendit-go/v6/payment_request"
+	"github.com/xendit/xendit-go/v6/refund"
+)
+
+const (
+	// xenditRefundReason is refund reason sent to xendit, the detailed reason is kept as metadata
+	xenditRefundReason = "CANCELLATION"
+
+	// xendit refund callback status
+	xenditRefundStatusSucceeded = "SUCCEEDED"
+	xenditRefundStatusFailed    = "FAILED"
+
+	// url type of xendit payment request action
+	xenditActionURLTypeDeeplink = "DEEPLINK"
+	xenditActionURLTypeWeb      = "WEB"
+	xenditActionURLTypeMobile   = "MOBILE"
+)
+
+type (
+	// XenditPaymentGatewayImpl is payment gateway backed by xendit, patient pay through xendit invoice page
+	// or directly through xendit payment request
+	XenditPaymentGatewayImpl struct {
+		Context         context.Context
+		Config          *config.Configuration
+		Logger          *logrus.Logger
+		XenditRequester XenditRequester
+	}
+)
+
+// NewXenditPaymentGateway return new instances xendit payment gateway
+func NewXenditPaymentGateway(ctx context.Context, config *config.Configuration, logger *logrus.Logger, xenditRequester XenditRequester) *XenditPaymentGatewayImpl {
+	return &XenditPaymentGatewayImpl{
+		Context:         ctx,
+		Config:          config,
+		Logger:          logger,
+		XenditRequester: xenditRequester,
+	}
+}
+
+// CreateCharge create xendit invoice, or xendit payment request when payment is charged directly
+func (xg *XenditPaymentGatewayImpl) CreateCharge(ctx context.Context, req *model.ChargeRequest) (*model.Charge, error) {
+	if req.Method.IsDirectCharge() {
+		return xg.createPaymentRequest(ctx, req)
+	}
+
+	return xg.createInvoice(ctx, req)
+}
+
+// createInvoice create xendit invoice of the payment, patient choose the payment method on the invoice page
+func (xg *XenditPaymentGatewayImpl) createInvoice(ctx context.Context, req *model.ChargeRequest) (*model.Charge, error) {
+	// initiate invoice object request
+	invoiceReq := invoice.NewCreateInvoiceRequest(req.ReferenceID, float64(req.Amount))
+
+	// set customer data inside invoices
+	customerData := invoice.NewCustomerObject()
+	customerData.SetCustomerId(req.Customer.ID)
+	customerData.SetGivenNames(req.Customer.Name)
+
+	if req.Customer.PhoneNumber != "" {
+		customerData.SetMobileNumber(helper.FormatPhoneNumberE164(req.Customer.PhoneNumber))
+	}
+
+	if req.Customer.Email != "" {
+		customerData.SetEmail(req.Customer.Email)
+		invoiceReq.SetPayerEmail(req.Customer.Email)
+	}
+
+	invoiceReq.SetCustomer(*customerData)
+
+	// set description inside invoices
+	invoiceReq.SetDescription(req.Description)
+
+	// set payment deadline and the page patient is sent back to after paying
+	invoiceReq.SetInvoiceDuration(strconv.Itoa(int(req.Duration.Seconds())))
+	invoiceReq.SetSuccessRedirectUrl(req.SuccessRedirectURL)
+	invoiceReq.SetFailureRedirectUrl(req.FailureRedirectURL)
+
+	// set transaction items inside invoices
+	for _, chargeItem := range req.Items {
+		item := invoice.NewInvoiceItem(chargeItem.Name, float32(chargeItem.Price), float32(chargeItem.Quantity))
+		item.SetReferenceId(chargeItem.ReferenceID)
+
+		invoiceReq.Items = append(invoiceReq.Items, *item)
+	}
+
+	// set shipping cost as fee inside invoices
+	if req.ShippingCost > 0 {
+		invoiceReq.Fees = append(invoiceReq.Fees, *invoice.NewInvoiceFee("Ongkos Kirim", float32(req.ShippingCost)))
+	}
+
+	// call xendit to create invoices
+	results, err := xg.XenditRequester.CreateInvoice(ctx, *invoiceReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return invoiceToCharge(results), nil
+}
+
+// createPaymentRequest charge patient directly through xendit payment request and return the instruction of the chosen
+// payment method, virtual account number for VA, qr string for QRIS and checkout url for e-wallet
+func (xg *XenditPaymentGatewayImpl) createPaymentRequest(ctx context.Context, req *model.ChargeRequest) (*model.Charge, error) {
+	paymentMethod := payment_request.PaymentMethodParameters{
+		Reusability: payment_request.PAYMENTMETHODREUSABILITY_ONE_TIME_USE,
+	}
+
+	// payment deadline of virtual account and qris, e-wallet checkout deadline is set by the channel and reported back
+	// by xendit in the response
+	expiresAt := time.Now().Add(req.Duration)
+
+	switch req.Method {
+	case model.PaymentMethodEnumVirtualAccount:
+		paymentMethod.Type = payment_request.PAYMENTMETHODTYPE_VIRTUAL_ACCOUNT
+		paymentMethod.VirtualAccount = *payment_request.NewNullableVirtualAccountParameters(&payment_request.VirtualAccountParameters{
+			ChannelCode: payment_request.VirtualAccountChannelCode(req.ChannelCode),
+			ChannelProperties: payment_request.VirtualAccountChannelProperties{
+				CustomerName: req.Customer.Name,
+				ExpiresAt:    &expiresAt,
+			},
+		})
+	case model.PaymentMethodEnumQRIS:
+		paymentMethod.Type = payment_request.PAYMENTMETHODTYPE_QR_CODE
+		paymentMethod.QrCode = *payment_request.NewNullableQRCodeParameters(&payment_request.QRCodeParameters{
+			ChannelCode: *payment_request.NewNullableQRCodeChannelCode(payment_request.QRCODECHANNELCODE_QRIS.Ptr()),
+			ChannelProperties: &payment_request.QRCodeChannelProperties{
+				ExpiresAt: &expiresAt,
+			},
+		})
+	case model.PaymentMethodEnumEwallet:
+		channelCode := payment_request.EWalletChannelCode(req.ChannelCode)
+		channelProperties := payment_request.EWalletChannelProperties{
+			SuccessReturnUrl: &req.SuccessRedirectURL,
+			FailureReturnUrl: &req.FailureRedirectURL,
+		}
+
+		if req.MobileNumber != "" {
+			channelProperties.MobileNumber = &req.MobileNumber
+		}
+
+		paymentMethod.Type = payment_request.PAYMENTMETHODTYPE_EWALLET
+		paymentMethod.Ewallet = *payment_request.NewNullableEWalletParameters(&payment_request.EWalletParameters{
+			ChannelCode:       &channelCode,
+			ChannelProperties: &channelProperties,
+		})
+	default:
+		return nil, model.NewError(model.Validation, fmt.Sprintf("payment method %s is not supported by xendit", req.Method))
+	}
+
+	amount := float64(req.Amount)
+
+	// reference id as idempotency key, so retried request never charge patient twice
+	results, err := xg.XenditRequester.CreatePaymentRequest(ctx, payment_request.PaymentRequestParameters{
+		ReferenceId:   &req.ReferenceID,
+		Amount:        &amount,
+		Currency:      payment_request.PAYMENTREQUESTCURRENCY_IDR,
+		PaymentMethod: &paymentMethod,
+		Description:   *payment_request.NewNullableString(&req.Description),
+		Metadata: map[string]interface{}{
+			"patient_id": req.Customer.ID,
+		},
+	}, fmt.Sprintf("payment-%s", req.ReferenceID))
+	if err != nil {
+		return nil, err
+	}
+
+	return paymentRequestToCharge(results)
+}
+
+// GetCharge fetch invoice of the payment, or payment request when payment is charged directly
+func (xg *XenditPaymentGatewayImpl) GetCharge(ctx context.Context, payment *model.Payment) (*model.Charge, error) {
+	if payment.Method.IsDirectCharge() {
+		paymentRequest, err := xg.XenditRequester.GetPaymentRequestByID(ctx, payment.PartnerID)
+		if err != nil {
+			return nil, err
+		}
+
+		return paymentRequestToCharge(paymentRequest)
+	}
+
+	inv, err := xg.XenditRequester.GetInvoiceByID(ctx, payment.PartnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return invoiceToCharge(inv), nil
+}
+
+// FindChargeByReferenceID find invoice or payment request by payment id, it may exist when the app stopped right after creating it
+func (xg *XenditPaymentGatewayImpl) FindChargeByReferenceID(ctx context.Context, payment *model.Payment) (*model.Charge, error) {
+	referenceID := strconv.Itoa(payment.ID)
+
+	if payment.Method.IsDirectCharge() {
+		paymentRequests, err := xg.XenditRequester.GetPaymentRequestsByReferenceID(ctx, referenceID)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(paymentRequests) == 0 {
+			return nil, nil
+		}
+
+		return paymentRequestToCharge(&paymentRequests[0])
+	}
+
+	invoices, err := xg.XenditRequester.GetInvoicesByExternalID(ctx, referenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(invoices) == 0 {
+		return nil, nil
+	}
+
+	return invoiceToCharge(&invoices[0]), nil
+}
+
+// CancelCharge expire invoice of the payment, xendit refuse to expire invoice that is already paid
+func (xg *XenditPaymentGatewayImpl) CancelCharge(ctx context.Context, payment *model.Payment) (*model.Charge, error) {
+	// only invoice can be expired on xendit, direct charge expire by itself
+	if payment.Method.IsDirectCharge() {
+		return nil, model.NewError(model.Conflict, fmt.Sprintf("transaction paid with %s can't be cancelled", payment.Method))
+	}
+
+	inv, err := xg.XenditRequester.ExpireInvoiceByID(ctx, payment.PartnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return invoiceToCharge(inv), nil
+}
+
+// CreateRefund request refund of paid invoice or payment request, xendit notify the refund result through webhook
+func (xg *XenditPaymentGatewayImpl) CreateRefund(ctx context.Context, payment *model.Payment, req *model.RefundChargeRequest) (*model.ChargeRefund, error) {
+	amount := float64(req.Amount)
+	reason := xenditRefundReason
+	refundReq := refund.CreateRefund{
+		ReferenceId: &req.ReferenceID,
+		Amount:      &amount,
+		Reason:      &reason,
+		Metadata:    req.Metadata,
+	}
+
+	// partner id is payment request id when payment is charged directly, otherwise it is invoice id
+	if payment.Method.IsDirectCharge() {
+		refundReq.PaymentRequestId = &payment.PartnerID
+	} else {
+		refundReq.InvoiceId = &payment.PartnerID
+	}
+
+	refundResp, err := xg.XenditRequester.CreateRefund(ctx, refundReq, fmt.Sprintf("refund-%s", req.ReferenceID))
+	if err != nil {
+		return nil, xenditRejected(err)
+	}
+
+	result := model.ChargeRefund{
+		PartnerID:       derefString(refundResp.Id),
+		ReferenceID:     req.ReferenceID,
+		ChargePartnerID: payment.PartnerID,
+		Status:          model.RefundStatusEnumPending,
+	}
+
+	return &result, nil
+}
+
+// ParseChargeNotification parse callback of xendit invoice or of xendit payment, payment callback is wrapped inside event envelope
+func (xg *XenditPaymentGatewayImpl) ParseChargeNotification(ctx context.Context, callbackID string, rawPayload []byte) (*model.ChargeNotification, error) {
+	var envelope struct {
+		Event string `json:"event"`
+	}
+
+	if err := json.Unmarshal(rawPayload, &envelope); err != nil {
+		return nil, model.NewError(model.Validation, err.Error())
+	}
+
+	if envelope.Event != "" {
+		return xg.parsePaymentCallback(callbackID, rawPayload)
+	}
+
+	var req invoice.InvoiceCallback
+	if err := json.Unmarshal(rawPayload, &req); err != nil {
+		return nil, model.NewError(model.Validation, err.Error())
+	}
+
+	// xendit doesn't always send webhook id, fallback to invoice state as callback identity
+	if callbackID == "" {
+		callbackID = fmt.Sprintf("%s:%s:%s", req.Id, req.Status, req.Updated)
+	}
+
+	charge := model.Charge{
+		Gateway:       model.PaymentGatewayEnumXendit,
+		PartnerID:     req.Id,
+		ReferenceID:   req.ExternalId,
+		Status:        mapInvoiceStatus(req.Status),
+		PartnerStatus: req.Status,
+		Amount:        int(math.Round(req.Amount)),
+	}
+
+	// paid amount is what patient actually paid, it is only sent once the invoice is paid
+	if req.PaidAmount != nil {
+		charge.Amount = int(math.Round(*req.PaidAmount))
+	}
+
+	if charge.Status == model.ChargeStatusEnumPaid && req.PaidAt != nil {
+		paidAt, err := time.Parse(time.RFC3339, *req.PaidAt)
+		if err != nil {
+			return nil, model.NewError(model.Validation, err.Error())
+		}
+
+		charge.PaidAt = &paidAt
+	}
+
+	return &model.ChargeNotification{
+		CallbackID: callbackID,
+		Charge:     charge,
+	}, nil
+}
+
+func (xg *XenditPaymentGatewayImpl) parsePaymentCallback(callbackID string, rawPayload []byte) (*model.ChargeNotification, error) {
+	var req payment_request.PaymentCallback
+	if err := json.Unmarshal(rawPayload, &req); err != nil {
+		return nil, model.NewError(model.Validation, err.Error())
+	}
+
+	if req.Data == nil {
+		return nil, model.NewError(model.Validation, "payment callback data is empty")
+	}
+
+	data := req.Data
+
+	// xendit doesn't always send webhook id, fallback to payment state as callback identity
+	if callbackID == "" {
+		callbackID = fmt.Sprintf("%s:%s:%s", data.Id, data.Status, data.Updated)
+	}
+
+	charge := model.Charge{
+		Gateway:       model.PaymentGatewayEnumXendit,
+		PartnerID:     derefString(data.PaymentRequestId.Get()),
+		ReferenceID:   data.ReferenceId,
+		Status:        mapPaymentRequestStatus(data.Status),
+		PartnerStatus: data.Status,
+		Amount:        int(math.Round(data.Amount)),
+	}
+
+	if charge.Status == model.ChargeStatusEnumPaid {
+		paidAt, err := time.Parse(time.RFC3339, data.Updated)
+		if err != nil {
+			return nil, model.NewError(model.Validation, err.Error())
+		}
+
+		charge.PaidAt = &paidAt
+	}
+
+	return &model.ChargeNotification{
+		CallbackID: callbackID,
+		Charge:     charge,
+	}, nil
+}
+
+// ParseRefundNotification parse callback of xendit refund
+func (xg *XenditPaymentGatewayImpl) ParseRefundNotification(ctx context.Context, callbackID string, rawPayload []byte) (*model.RefundNotification, error) {
+	var req refund.RefundCallback
+	if err := json.Unmarshal(rawPayload, &req); err != nil {
+		return nil, model.NewError(model.Validation, err.Error())
+	}
+
+	if req.Data == nil {
+		return nil, model.NewError(model.Validation, "refund callback data is empty")
+	}
+
+	data := req.Data
+
+	// xendit doesn't always send webhook id, fallback to refund state as callback identity
+	if callbackID == "" {
+		callbackID = fmt.Sprintf("%s:%s:%s", data.Id, data.Status, data.Updated)
+	}
+
+	r := model.ChargeRefund{
+		PartnerID:       data.Id,
+		ReferenceID:     derefString(data.ReferenceId.Get()),
+		ChargePartnerID: derefString(data.InvoiceId.Get()),
+		PartnerStatus:   data.Status,
+		FailureCode:     derefString(data.FailureCode.Get()),
+	}
+
+	if r.ChargePartnerID == "" {
+		r.ChargePartnerID = data.PaymentId
+	}
+
+	switch data.Status {
+	case xenditRefundStatusSucceeded:
+		r.Status = model.RefundStatusEnumSucceeded
+	case xenditRefundStatusFailed:
+		r.Status = model.RefundStatusEnumFailed
+	default:
+		r.Status = model.RefundStatusEnumPending
+	}
+
+	return &model.RefundNotification{
+		CallbackID: callbackID,
+		Refund:     r,
+	}, nil
+}
+
+// xenditRejected mark error of 4xx xendit response as rejection, other errors e.g. timeout or 5xx have unknown outcome
+func xenditRejected(err error) error {
+	var sdkErr *common.XenditSdkError
+	if !errors.As(err, &sdkErr) {
+		return err
+	}
+
+	status, convErr := strconv.Atoi(sdkErr.Status())
+	if convErr != nil || status < http.StatusBadRequest || status >= http.StatusInternalServerError {
+		return err
+	}
+
+	return model.Rejected(err)
+}
+
+// invoiceToCharge convert xendit invoice into charge
+func invoiceToCharge(inv *invoice.Invoice) *model.Charge {
+	charge := model.Charge{
+		Gateway:       model.PaymentGatewayEnumXendit,
+		PartnerID:     derefString(inv.Id),
+		ReferenceID:   inv.ExternalId,
+		Status:        mapInvoiceStatus(string(inv.Status)),
+		PartnerStatus: string(inv.Status),
+		Amount:        int(math.Round(inv.Amount)),
+		CheckoutURL:   inv.InvoiceUrl,
+		ExpiresAt:     &inv.ExpiryDate,
+		UpdatedAt:     inv.Updated,
+	}
+
+	if charge.Status == model.ChargeStatusEnumPaid {
+		charge.PaidAt = &inv.Updated
+	}
+
+	return &charge
+}
+
+// paymentRequestToCharge convert xendit payment request into charge together with the instruction of its payment method
+func paymentRequestToCharge(paymentRequest *XenditPaymentRequest) (*model.Charge, error) {
+	updatedAt, err := time.Parse(time.RFC3339, paymentRequest.Updated)
+	if err != nil {
+		return nil, err
+	}
+
+	charge := model.Charge{
+		Gateway:       model.PaymentGatewayEnumXendit,
+		PartnerID:     paymentRequest.Id,
+		ReferenceID:   paymentRequest.ReferenceId,
+		Status:        mapPaymentRequestStatus(string(paymentRequest.Status)),
+		PartnerStatus: string(paymentRequest.Status),
+		Amount:        int(math.Round(paymentRequest.GetAmount())),
+		UpdatedAt:     updatedAt,
+	}
+
+	if charge.Status == model.ChargeStatusEnumPaid {
+		charge.PaidAt = &updatedAt
+	}
+
+	if va, ok := paymentRequest.PaymentMethod.GetVirtualAccountOk(); ok && va != nil {
+		charge.VirtualAccountNumber = derefString(va.ChannelProperties.VirtualAccountNumber)
+		charge.ExpiresAt = va.ChannelProperties.ExpiresAt
+	}
+
+	if qr, ok := paymentRequest.PaymentMethod.GetQrCodeOk(); ok && qr != nil && qr.ChannelProperties != nil {
+		charge.QRString = derefString(qr.ChannelProperties.QrString)
+		charge.ExpiresAt = qr.ChannelProperties.ExpiresAt
+	}
+
+	if paymentRequest.EwalletExpiresAt != nil {
+		charge.ExpiresAt = paymentRequest.EwalletExpiresAt
+	}
+
+	for _, action := range paymentRequest.Actions {
+		switch action.UrlType {
+		case xenditActionURLTypeDeeplink:
+			charge.DeeplinkURL = derefString(action.Url.Get())
+		case xenditActionURLTypeWeb, xenditActionURLTypeMobile:
+			if charge.CheckoutURL == "" {
+				charge.CheckoutURL = derefString(action.Url.Get())
+			}
+		}
+	}
+
+	return &charge, nil
+}
+
+// mapInvoiceStatus return charge status of xendit invoice status, invoice that is not finished yet is pending
+func mapInvoiceStatus(status string) model.ChargeStatusEnum {
+	switch status {
+	case string(invoice.INVOICESTATUS_PAID), string(invoice.INVOICESTATUS_SETTLED):
+		return model.ChargeStatusEnumPaid
+	case string(invoice.INVOICESTATUS_EXPIRED):
+		return model.ChargeStatusEnumExpired
+	case string(invoice.INVOICESTATUS_XENDIT_ENUM_DEFAULT_FALLBACK):
+		return model.ChargeStatusEnumFailed
+	default:
+		return model.ChargeStatusEnumPending
+	}
+}
+
+// mapPaymentRequestStatus return charge status of xendit payment request or payment status, payment that is not finished yet is pending
+func mapPaymentRequestStatus(status string) model.ChargeStatusEnum {
+	switch status {
+	case string(payment_request.PAYMENTREQUESTSTATUS_SUCCEEDED):
+		return model.ChargeStatusEnumPaid
+	case string(payment_request.PAYMENTREQUESTSTATUS_EXPIRED):
+		return model.ChargeStatusEnumExpired
+	case string(payment_request.PAYMENTREQUESTSTATUS_FAILED), string(payment_request.PAYMENTREQUESTSTATUS_CANCELED), string(payment_request.PAYMENTREQUESTSTATUS_VOIDED):
+		return model.ChargeStatusEnumFailed
+	default:
+		return model.ChargeStatusEnumPending
+	}
+}
+
+// derefString return value of nullable string, empty string when it is nil
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
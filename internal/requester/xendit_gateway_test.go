@@ -0,0 +1,188 @@
+package requester
+
+import (
+	"context"
+	"e-resep-be/internal/model"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/xendit/xendit-go/v6/common"
+	"github.com/xendit/xendit-go/v6/payment_request"
+)
+
+func TestMapInvoiceStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   model.ChargeStatusEnum
+	}{
+		{status: "PENDING", want: model.ChargeStatusEnumPending},
+		{status: "PAID", want: model.ChargeStatusEnumPaid},
+		{status: "SETTLED", want: model.ChargeStatusEnumPaid},
+		{status: "EXPIRED", want: model.ChargeStatusEnumExpired},
+		{status: "UNKNOWN_ENUM_VALUE", want: model.ChargeStatusEnumFailed},
+		{status: "", want: model.ChargeStatusEnumPending},
+		{status: "paid", want: model.ChargeStatusEnumPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if got := mapInvoiceStatus(tt.status); got != tt.want {
+				t.Errorf("mapInvoiceStatus(%q) = %s, want %s", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapPaymentRequestStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   model.ChargeStatusEnum
+	}{
+		{status: "PENDING", want: model.ChargeStatusEnumPending},
+		{status: "REQUIRES_ACTION", want: model.ChargeStatusEnumPending},
+		{status: "AWAITING_CAPTURE", want: model.ChargeStatusEnumPending},
+		{status: "SUCCEEDED", want: model.ChargeStatusEnumPaid},
+		{status: "EXPIRED", want: model.ChargeStatusEnumExpired},
+		{status: "FAILED", want: model.ChargeStatusEnumFailed},
+		{status: "CANCELED", want: model.ChargeStatusEnumFailed},
+		{status: "VOIDED", want: model.ChargeStatusEnumFailed},
+		{status: "UNKNOWN", want: model.ChargeStatusEnumPending},
+		{status: "", want: model.ChargeStatusEnumPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if got := mapPaymentRequestStatus(tt.status); got != tt.want {
+				t.Errorf("mapPaymentRequestStatus(%q) = %s, want %s", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaymentRequestToChargeEwalletExpiry(t *testing.T) {
+	body := []byte(`{
+		"id": "pr-1",
+		"reference_id": "10",
+		"amount": 15000,
+		"country": "ID",
+		"currency": "IDR",
+		"payment_method": {
+			"id": "pm-1",
+			"type": "EWALLET",
+			"reusability": "ONE_TIME_USE",
+			"status": "ACTIVE",
+			"ewallet": {
+				"channel_code": "DANA",
+				"channel_properties": {"expires_at": "2024-01-01T00:30:00Z"}
+			}
+		},
+		"status": "REQUIRES_ACTION",
+		"actions": [{"action": "AUTH", "url_type": "WEB", "method": "GET", "url": "https://checkout.example/pr-1"}],
+		"created": "2024-01-01T00:00:00Z",
+		"updated": "2024-01-01T00:00:00Z"
+	}`)
+
+	var paymentRequest payment_request.PaymentRequest
+	if err := json.Unmarshal(body, &paymentRequest); err != nil {
+		t.Fatalf("unmarshal payment request: %v", err)
+	}
+
+	var extra xenditPaymentRequestExtra
+	if err := json.Unmarshal(body, &extra); err != nil {
+		t.Fatalf("unmarshal payment request extra: %v", err)
+	}
+
+	charge, err := paymentRequestToCharge(newXenditPaymentRequest(paymentRequest, extra))
+	if err != nil {
+		t.Fatalf("paymentRequestToCharge() error = %v", err)
+	}
+
+	want := time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)
+	if charge.ExpiresAt == nil || !charge.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", charge.ExpiresAt, want)
+	}
+
+	if charge.Gateway != model.PaymentGatewayEnumXendit || charge.Amount != 15000 {
+		t.Errorf("Gateway, Amount = %s, %d, want %s, 15000", charge.Gateway, charge.Amount, model.PaymentGatewayEnumXendit)
+	}
+
+	if charge.CheckoutURL != "https://checkout.example/pr-1" {
+		t.Errorf("CheckoutURL = %q, want checkout url of the web action", charge.CheckoutURL)
+	}
+}
+
+func TestParseChargeNotificationAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    int
+	}{
+		{
+			name:    "pending invoice reports invoice amount",
+			payload: `{"id":"inv-1","external_id":"10","status":"PENDING","amount":150000,"created":"2024-01-01T00:00:00Z","updated":"2024-01-01T00:00:00Z","currency":"IDR"}`,
+			want:    150000,
+		},
+		{
+			name:    "paid invoice reports paid amount",
+			payload: `{"id":"inv-1","external_id":"10","status":"PAID","amount":150000,"paid_amount":149999.6,"paid_at":"2024-01-01T00:10:00Z","created":"2024-01-01T00:00:00Z","updated":"2024-01-01T00:10:00Z","currency":"IDR"}`,
+			want:    150000,
+		},
+		{
+			name: "payment callback reports payment amount",
+			payload: `{"event":"payment.succeeded","business_id":"biz","created":"2024-01-01T00:10:00Z","data":{
+				"id":"py-1","payment_request_id":"pr-1","reference_id":"10","currency":"IDR","amount":75000,"country":"ID",
+				"status":"SUCCEEDED","payment_method":{"id":"pm-1","type":"EWALLET","reusability":"ONE_TIME_USE","status":"ACTIVE"},
+				"created":"2024-01-01T00:00:00Z","updated":"2024-01-01T00:10:00Z"}}`,
+			want: 75000,
+		},
+	}
+
+	xg := &XenditPaymentGatewayImpl{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notification, err := xg.ParseChargeNotification(context.Background(), "cb-1", []byte(tt.payload))
+			if err != nil {
+				t.Fatalf("ParseChargeNotification() error = %v", err)
+			}
+
+			if notification.Charge.Gateway != model.PaymentGatewayEnumXendit {
+				t.Errorf("Gateway = %s, want %s", notification.Charge.Gateway, model.PaymentGatewayEnumXendit)
+			}
+
+			if notification.Charge.Amount != tt.want {
+				t.Errorf("Amount = %d, want %d", notification.Charge.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestXenditRejected(t *testing.T) {
+	body := []byte(`{"error_code":"API_VALIDATION_ERROR","message":"refund amount exceed the paid amount"}`)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "bad request", err: common.NewXenditSdkError(&body, "400", "400 Bad Request"), want: true},
+		{name: "duplicate", err: common.NewXenditSdkError(&body, "409", "409 Conflict"), want: true},
+		{name: "server error", err: common.NewXenditSdkError(&body, "503", "503 Service Unavailable"), want: false},
+		{name: "no status", err: common.NewXenditSdkError(&body, "", "connection reset"), want: false},
+		{name: "other error", err: context.DeadlineExceeded, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := xenditRejected(tt.err)
+			if got := model.IsRejected(err); got != tt.want {
+				t.Errorf("IsRejected(xenditRejected(%v)) = %v, want %v", tt.err, got, tt.want)
+			}
+
+			if err.Error() != tt.err.Error() {
+				t.Errorf("error message = %q, want %q", err.Error(), tt.err.Error())
+			}
+		})
+	}
+}
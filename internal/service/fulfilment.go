@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"e-resep-be/internal/repository"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+type (
+	// FulfilmentService is an interface that has all the function to be implemented inside fulfilment service
+	FulfilmentService interface {
+		UpdateFulfilment(ctx context.Context, transactionID int, req *model.UpdateFulfilmentRequest) (*model.Fulfilment, error)
+		GetTracking(ctx context.Context, patientRefID string, transactionID int) (*model.FulfilmentTracking, error)
+		HandleCourierNotification(ctx context.Context, req *model.CourierNotification) error
+	}
+
+	// FulfilmentServiceImpl is an app fulfilment struct that consists of all the dependencies needed for fulfilment service
+	FulfilmentServiceImpl struct {
+		Context         context.Context
+		Config          *config.Configuration
+		PatientRepo     repository.PatientRepository
+		TransactionRepo repository.TransactionRepository
+		FulfilmentRepo  repository.FulfilmentRepository
+		ShipmentRepo    repository.ShipmentRepository
+		UnitOfWork      repository.UnitOfWork
+	}
+)
+
+// NewFulfilmentService return new instances fulfilment service
+func NewFulfilmentService(ctx context.Context, config *config.Configuration, patientRepo repository.PatientRepository, transactionRepo repository.TransactionRepository, fulfilmentRepo repository.FulfilmentRepository, shipmentRepo repository.ShipmentRepository, unitOfWork repository.UnitOfWork) *FulfilmentServiceImpl {
+	return &FulfilmentServiceImpl{
+		Context:         ctx,
+		Config:          config,
+		PatientRepo:     patientRepo,
+		TransactionRepo: transactionRepo,
+		FulfilmentRepo:  fulfilmentRepo,
+		ShipmentRepo:    shipmentRepo,
+		UnitOfWork:      unitOfWork,
+	}
+}
+
+// UpdateFulfilment advance fulfilment of paid transaction into the next status, fulfilment can't skip a step or go back.
+// Fully refunded transaction is not fulfilled anymore. Shipped order is booked on courier by outbox dispatcher,
+// so courier is never called while fulfilment is locked
+func (fs *FulfilmentServiceImpl) UpdateFulfilment(ctx context.Context, transactionID int, req *model.UpdateFulfilmentRequest) (*model.Fulfilment, error) {
+	var fulfilment *model.Fulfilment
+
+	err := fs.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		// lock fulfilment, so concurrent update can't move it twice
+		current, err := repos.Fulfilment.GetByTransactionIDForUpdate(ctx, transactionID)
+		if err != nil {
+			if err.Error() == pgx.ErrNoRows.Error() {
+				return model.NewError(model.NotFound, "fulfilment not found, transaction is not paid")
+			}
+
+			return err
+		}
+
+		transaction, err := repos.Transaction.GetByID(ctx, transactionID)
+		if err != nil {
+			return err
+		}
+
+		if transaction.Status == model.TransactionStatusEnumRefunded {
+			return model.NewError(model.Conflict, "transaction is refunded, it can't be fulfilled")
+		}
+
+		if !current.Status.CanTransitionTo(req.Status) {
+			return model.NewError(model.Conflict, fmt.Sprintf("fulfilment can't move from %s to %s", current.Status, req.Status))
+		}
+
+		if req.Status == model.FulfilmentStatusEnumShipped {
+			message, err := model.NewOutboxMessage(model.OutboxEventTypeEnumShipmentBooking, strconv.Itoa(transaction.ID), model.ShipmentBookingPayload{
+				TransactionID: transaction.ID,
+			})
+			if err != nil {
+				return err
+			}
+
+			if _, err := repos.Outbox.Insert(ctx, message); err != nil {
+				return err
+			}
+		}
+
+		err = repos.Fulfilment.UpdateStatusByID(ctx, req.Status, req.Note, time.Now(), current.ID)
+		if err != nil {
+			return err
+		}
+
+		fulfilment, err = repos.Fulfilment.GetByTransactionID(ctx, transactionID)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fulfilment, nil
+}
+
+// GetTracking return fulfilment progress of patient transaction, transaction of another patient is reported as not found
+func (fs *FulfilmentServiceImpl) GetTracking(ctx context.Context, patientRefID string, transactionID int) (*model.FulfilmentTracking, error) {
+	patient, err := fs.PatientRepo.GetByRefID(ctx, patientRefID)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, model.NewError(model.NotFound, "patient not found")
+		}
+
+		return nil, err
+	}
+
+	transaction, err := fs.TransactionRepo.GetByID(ctx, transactionID)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, model.NewError(model.NotFound, "transaction not found")
+		}
+
+		return nil, err
+	}
+
+	if transaction.PatientID != patient.ID {
+		return nil, model.NewError(model.NotFound, "transaction not found")
+	}
+
+	fulfilment, err := fs.FulfilmentRepo.GetByTransactionID(ctx, transactionID)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, model.NewError(model.NotFound, fmt.Sprintf("transaction is not paid, status is %s", transaction.Status))
+		}
+
+		return nil, err
+	}
+
+	tracking := fulfilment.Tracking()
+
+	shipment, err := fs.ShipmentRepo.GetByTransactionID(ctx, transactionID)
+	if err != nil && err.Error() != pgx.ErrNoRows.Error() {
+		return nil, err
+	}
+
+	tracking.Shipment = shipment
+
+	return &tracking, nil
+}
+
+// courierFulfilmentStatus is fulfilment status reached when courier report final shipment status
+var courierFulfilmentStatus = map[model.CourierStatusEnum]model.FulfilmentStatusEnum{
+	model.CourierStatusEnumDelivered: model.FulfilmentStatusEnumDelivered,
+	model.CourierStatusEnumReturned:  model.FulfilmentStatusEnumReturned,
+}
+
+// HandleCourierNotification record courier status of shipment and move fulfilment into delivered or returned.
+// Notification older than the stored courier status is ignored, so retried or out of order webhook can't move shipment back
+func (fs *FulfilmentServiceImpl) HandleCourierNotification(ctx context.Context, req *model.CourierNotification) error {
+	return fs.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		shipment, err := repos.Shipment.GetByPartnerIDForUpdate(ctx, req.Data.ID)
+		if err != nil {
+			if err.Error() == pgx.ErrNoRows.Error() {
+				return model.NewError(model.NotFound, "shipment not found")
+			}
+
+			return err
+		}
+
+		if !req.Data.UpdatedAt.After(shipment.CourierUpdatedAt) {
+			return nil
+		}
+
+		err = repos.Shipment.UpdateCourierStatusByID(ctx, req.Data.Status, req.Data.TrackingNumber, req.Data.UpdatedAt, shipment.ID)
+		if err != nil {
+			return err
+		}
+
+		status, ok := courierFulfilmentStatus[req.Data.Status]
+		if !ok {
+			// cancelled shipment is only recorded, pharmacy decide to rebook it
+			return nil
+		}
+
+		fulfilment, err := repos.Fulfilment.GetByTransactionIDForUpdate(ctx, shipment.TransactionID)
+		if err != nil {
+			return err
+		}
+
+		if !fulfilment.Status.CanTransitionTo(status) {
+			return nil
+		}
+
+		return repos.Fulfilment.UpdateStatusByID(ctx, status, "", req.Data.UpdatedAt, fulfilment.ID)
+	})
+}
@@ -0,0 +1,393 @@
+package service
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/helper"
+	"e-resep-be/internal/model"
+	"e-resep-be/internal/repository"
+	"e-resep-be/internal/requester"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultOutboxMaxAttempts is used when OUTBOX_MAX_ATTEMPTS is not configured
+	defaultOutboxMaxAttempts = 8
+	// outboxBaseBackoff is the wait after the first failed attempt, it is doubled on every next failure
+	outboxBaseBackoff = 10 * time.Second
+	// outboxMaxBackoff limit the wait between two attempts
+	outboxMaxBackoff = 30 * time.Minute
+	// outboxBatchSize limit the number of messages dispatched in a single run
+	outboxBatchSize = 100
+	// outboxClaimLease is how long claimed message is kept away from other dispatchers while partner is called
+	outboxClaimLease = 5 * time.Minute
+)
+
+type (
+	// OutboxService is an interface that has all the function to be implemented inside outbox service
+	OutboxService interface {
+		DispatchPendingMessages(ctx context.Context) error
+	}
+
+	// OutboxServiceImpl is an app outbox struct that consists of all the dependencies needed for outbox service
+	OutboxServiceImpl struct {
+		Context             context.Context
+		Config              *config.Configuration
+		OutboxRepo          repository.OutboxRepository
+		TransactionRepo     repository.TransactionRepository
+		PatientAddressRepo  repository.PatientAddressRepository
+		DispenseOrderRepo   repository.DispenseOrderRepository
+		WhatsappRequester   requester.WhatsappRequester
+		KimiaFarmaRequester requester.KimiaFarmaRequester
+		CourierRequester    requester.CourierRequester
+		UnitOfWork          repository.UnitOfWork
+
+		handlers map[model.OutboxEventTypeEnum]outboxHandler
+	}
+
+	// outboxHandler run side effect of outbox message outside any database transaction, Validation error mean the message
+	// can never succeed and is dead-lettered. The returned record, if any, is run in the transaction that mark the message as dispatched
+	outboxHandler func(ctx context.Context, message *model.OutboxMessage) (outboxRecord, error)
+
+	// outboxRecord store the partner result of dispatched outbox message
+	outboxRecord func(ctx context.Context, repos *repository.Repositories) error
+)
+
+// NewOutboxService return new instances outbox service
+func NewOutboxService(ctx context.Context, config *config.Configuration, outboxRepo repository.OutboxRepository, transactionRepo repository.TransactionRepository, patientAddressRepo repository.PatientAddressRepository, dispenseOrderRepo repository.DispenseOrderRepository, whatsappRequester requester.WhatsappRequester, kimiaFarmaRequester requester.KimiaFarmaRequester, courierRequester requester.CourierRequester, unitOfWork repository.UnitOfWork) *OutboxServiceImpl {
+	obs := &OutboxServiceImpl{
+		Context:             ctx,
+		Config:              config,
+		OutboxRepo:          outboxRepo,
+		TransactionRepo:     transactionRepo,
+		PatientAddressRepo:  patientAddressRepo,
+		DispenseOrderRepo:   dispenseOrderRepo,
+		WhatsappRequester:   whatsappRequester,
+		KimiaFarmaRequester: kimiaFarmaRequester,
+		CourierRequester:    courierRequester,
+		UnitOfWork:          unitOfWork,
+	}
+
+	obs.handlers = map[model.OutboxEventTypeEnum]outboxHandler{
+		model.OutboxEventTypeEnumWhatsappMessage: obs.sendWhatsappMessage,
+		model.OutboxEventTypeEnumDispenseOrder:   obs.submitDispenseOrder,
+		model.OutboxEventTypeEnumShipmentBooking: obs.bookShipment,
+	}
+
+	return obs
+}
+
+// DispatchPendingMessages run side effect of due outbox messages in the order they are written.
+// Failed message is retried with exponential backoff until it run out of attempts, then it is dead-lettered
+func (obs *OutboxServiceImpl) DispatchPendingMessages(ctx context.Context) error {
+	messages, err := obs.OutboxRepo.GetDue(ctx, time.Now(), outboxBatchSize)
+	if err != nil {
+		return err
+	}
+
+	// keep dispatching the rest when one message fail, it will be retried on its next attempt
+	var errs []error
+	for _, message := range messages {
+		if err := obs.dispatchMessage(ctx, message.ID); err != nil {
+			errs = append(errs, fmt.Errorf("outbox message %d (%s): %w", message.ID, message.EventType, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// dispatchMessage claim the message, call partner without holding any lock, then record the result in a new transaction
+func (obs *OutboxServiceImpl) dispatchMessage(ctx context.Context, id int) error {
+	message, err := obs.claimMessage(ctx, id)
+	if err != nil || message == nil {
+		return err
+	}
+
+	var record outboxRecord
+	handler, ok := obs.handlers[message.EventType]
+	if !ok {
+		err = model.NewError(model.Validation, fmt.Sprintf("no handler for outbox event %s", message.EventType))
+	} else {
+		record, err = handler(ctx, message)
+	}
+
+	return obs.recordMessage(ctx, message, record, err)
+}
+
+// claimMessage lock due message and push its next attempt by the claim lease, so another dispatcher skip it while partner is called.
+// Message of dispatcher that stop before recording the result is dispatched again once the lease pass
+func (obs *OutboxServiceImpl) claimMessage(ctx context.Context, id int) (*model.OutboxMessage, error) {
+	var message *model.OutboxMessage
+
+	err := obs.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		// recheck under lock, another instance may claim the same message
+		current, err := repos.Outbox.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if current.Status != model.OutboxStatusEnumPending || current.NextAttemptAt.After(time.Now()) {
+			return nil
+		}
+
+		err = repos.Outbox.UpdateNextAttemptAtByID(ctx, time.Now().Add(outboxClaimLease), current.ID)
+		if err != nil {
+			return err
+		}
+
+		message = current
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// recordMessage mark claimed message as dispatched or record the failed attempt, result of a claim that is taken over
+// by another dispatcher after the lease passed is dropped, the other dispatcher record it
+func (obs *OutboxServiceImpl) recordMessage(ctx context.Context, message *model.OutboxMessage, record outboxRecord, handleErr error) error {
+	var dispatchErr error
+
+	err := obs.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		current, err := repos.Outbox.GetByIDForUpdate(ctx, message.ID)
+		if err != nil {
+			return err
+		}
+
+		if current.Status != model.OutboxStatusEnumPending || current.Attempts != message.Attempts {
+			return nil
+		}
+
+		if handleErr == nil {
+			if record != nil {
+				if err := record(ctx, repos); err != nil {
+					return err
+				}
+			}
+
+			return repos.Outbox.UpdateDispatchedByID(ctx, time.Now(), current.ID)
+		}
+
+		dispatchErr = handleErr
+
+		status := model.OutboxStatusEnumPending
+		if model.KindOf(handleErr) == model.Validation || current.Attempts+1 >= obs.maxAttempts() {
+			status = model.OutboxStatusEnumDead
+			dispatchErr = fmt.Errorf("dead-lettered after %d attempts: %w", current.Attempts+1, handleErr)
+		}
+
+		return repos.Outbox.UpdateAttemptByID(ctx, status, handleErr.Error(), time.Now().Add(helper.ExponentialBackoff(outboxBaseBackoff, outboxMaxBackoff, current.Attempts+1)), current.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return dispatchErr
+}
+
+func (obs *OutboxServiceImpl) sendWhatsappMessage(ctx context.Context, message *model.OutboxMessage) (outboxRecord, error) {
+	var payload model.WhatsappMessagePayload
+	if err := json.Unmarshal(message.Payload, &payload); err != nil {
+		return nil, model.NewError(model.Validation, fmt.Sprintf("invalid whatsapp message payload: %v", err))
+	}
+
+	return nil, obs.WhatsappRequester.SendMessageByRecipentNumber(ctx, payload.PatientName, payload.PatientID, payload.Destination, payload.TemplateName)
+}
+
+// submitDispenseOrder push paid transaction to kimia farma and keep their order id.
+// Transaction id is used as idempotency key, so retry of a claim that is not recorded return the same order
+func (obs *OutboxServiceImpl) submitDispenseOrder(ctx context.Context, message *model.OutboxMessage) (outboxRecord, error) {
+	var payload model.DispenseOrderPayload
+	if err := json.Unmarshal(message.Payload, &payload); err != nil {
+		return nil, model.NewError(model.Validation, fmt.Sprintf("invalid dispense order payload: %v", err))
+	}
+
+	dispenseReq, err := obs.buildDispenseOrderRequest(ctx, payload.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	partnerOrder, err := obs.KimiaFarmaRequester.CreateDispenseOrder(ctx, dispenseReq, fmt.Sprintf("dispense-order-%d", payload.TransactionID))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, repos *repository.Repositories) error {
+		return repos.DispenseOrder.Insert(ctx, &model.DispenseOrder{
+			TransactionID: payload.TransactionID,
+			PartnerID:     partnerOrder.OrderID,
+			SubmittedAt:   time.Now(),
+		})
+	}, nil
+}
+
+// buildDispenseOrderRequest collect transaction items with their dosage instructions and the delivery address
+func (obs *OutboxServiceImpl) buildDispenseOrderRequest(ctx context.Context, transactionID int) (*model.KimiaFarmaDispenseOrderRequest, error) {
+	transaction, err := obs.TransactionRepo.GetByID(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := obs.PatientAddressRepo.GetByID(ctx, transaction.PatientAddressID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := obs.DispenseOrderRepo.GetItemsByTransactionID(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return nil, model.NewError(model.Validation, "transaction has no item with medication request")
+	}
+
+	dispenseReq := model.KimiaFarmaDispenseOrderRequest{
+		ReferenceID: strconv.Itoa(transactionID),
+		Items:       make([]model.KimiaFarmaDispenseItem, 0, len(items)),
+		Delivery: model.KimiaFarmaDeliveryAddress{
+			RecipientName: address.RecipentName,
+			PhoneNumber:   helper.FormatPhoneNumberE164(address.RecipentPhoneNumber),
+			Address:       address.Address,
+			SubDistrict:   address.SubDistrict,
+			District:      address.District,
+			City:          address.City,
+			Province:      address.Province,
+			PostalCode:    address.PostalCode,
+			Latitude:      address.Latitude,
+			Longitude:     address.Longitude,
+		},
+	}
+
+	if address.AdditionalNotes != nil {
+		dispenseReq.Delivery.Notes = *address.AdditionalNotes
+	}
+
+	for _, item := range items {
+		// quantity prescribed by medication request, at least one pack is dispensed
+		quantity := int(math.Ceil(item.DispenseRequest.Quantity.Value))
+		if quantity < 1 {
+			quantity = 1
+		}
+
+		dispenseReq.Items = append(dispenseReq.Items, model.KimiaFarmaDispenseItem{
+			KFACode:             item.KFACode,
+			Name:                item.Name,
+			Quantity:            quantity,
+			Price:               item.Price,
+			MedicationRequestID: item.MedicationRequestID,
+			DosageInstructions:  item.DosageInstructions,
+		})
+	}
+
+	return &dispenseReq, nil
+}
+
+// bookShipment book courier delivery from pharmacy to shipped transaction patient address and store the shipment.
+// Transaction id is used as idempotency key, so retry of a claim that is not recorded return the same courier shipment
+func (obs *OutboxServiceImpl) bookShipment(ctx context.Context, message *model.OutboxMessage) (outboxRecord, error) {
+	var payload model.ShipmentBookingPayload
+	if err := json.Unmarshal(message.Payload, &payload); err != nil {
+		return nil, model.NewError(model.Validation, fmt.Sprintf("invalid shipment booking payload: %v", err))
+	}
+
+	transaction, err := obs.TransactionRepo.GetByID(ctx, payload.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := obs.PatientAddressRepo.GetByID(ctx, transaction.PatientAddressID)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := obs.TransactionRepo.GetDetailsByTransactionID(ctx, transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	destination := model.CourierLocation{
+		Name:        address.RecipentName,
+		PhoneNumber: helper.FormatPhoneNumberE164(address.RecipentPhoneNumber),
+		Address:     fmt.Sprintf("%s, %s, %s, %s, %s %s", address.Address, address.SubDistrict, address.District, address.City, address.Province, address.PostalCode),
+		Latitude:    address.Latitude,
+		Longitude:   address.Longitude,
+	}
+	if address.AdditionalNotes != nil {
+		destination.Notes = *address.AdditionalNotes
+	}
+
+	shipmentReq := model.CourierShipmentRequest{
+		ReferenceID: strconv.Itoa(transaction.ID),
+		ServiceType: model.CourierServiceTypeInstant,
+		Origin: model.CourierLocation{
+			Name:        obs.Config.Pharmacy.PharmacyName,
+			PhoneNumber: helper.FormatPhoneNumberE164(obs.Config.Pharmacy.PharmacyPhoneNumber),
+			Address:     obs.Config.Pharmacy.PharmacyAddress,
+			Latitude:    obs.Config.Pharmacy.PharmacyLatitude,
+			Longitude:   obs.Config.Pharmacy.PharmacyLongitude,
+		},
+		Destination: destination,
+		Items:       courierItems(details),
+	}
+
+	courierShipment, err := obs.CourierRequester.CreateShipment(ctx, &shipmentReq, fmt.Sprintf("shipment-%d", transaction.ID))
+	if err != nil {
+		return nil, model.NewError(model.Partner, fmt.Sprintf("failed to book courier: %v", err))
+	}
+
+	shipment := model.Shipment{
+		TransactionID:    transaction.ID,
+		PartnerID:        courierShipment.ID,
+		TrackingNumber:   courierShipment.TrackingNumber,
+		CourierStatus:    courierShipment.Status,
+		Price:            courierShipment.Price,
+		CourierUpdatedAt: courierShipment.UpdatedAt,
+	}
+	if courierShipment.TrackingURL != "" {
+		shipment.TrackingURL = &courierShipment.TrackingURL
+	}
+	if shipment.CourierUpdatedAt.IsZero() {
+		shipment.CourierUpdatedAt = time.Now()
+	}
+
+	return func(ctx context.Context, repos *repository.Repositories) error {
+		_, err := repos.Shipment.Insert(ctx, &shipment)
+
+		return err
+	}, nil
+}
+
+// courierItems group transaction details of the same medication into one courier item
+func courierItems(details []model.TransactionDetail) []model.CourierItem {
+	items := []model.CourierItem{}
+	index := make(map[int]int, len(details))
+	for _, detail := range details {
+		if i, ok := index[detail.MedicationID]; ok {
+			items[i].Quantity++
+			continue
+		}
+
+		index[detail.MedicationID] = len(items)
+		items = append(items, model.CourierItem{Name: detail.MedicationName, Quantity: 1})
+	}
+
+	return items
+}
+
+// maxAttempts return configured attempts before outbox message is dead-lettered, default to 8
+func (obs *OutboxServiceImpl) maxAttempts() int {
+	if obs.Config.Outbox.OutboxMaxAttempts > 0 {
+		return obs.Config.Outbox.OutboxMaxAttempts
+	}
+
+	return defaultOutboxMaxAttempts
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"e-resep-be/internal/repository"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeOutboxRepository keep a single outbox message in memory
+type fakeOutboxRepository struct {
+	repository.OutboxRepository
+	message *model.OutboxMessage
+}
+
+func (f *fakeOutboxRepository) GetByIDForUpdate(ctx context.Context, id int) (*model.OutboxMessage, error) {
+	message := *f.message
+
+	return &message, nil
+}
+
+func (f *fakeOutboxRepository) UpdateNextAttemptAtByID(ctx context.Context, nextAttemptAt time.Time, id int) error {
+	f.message.NextAttemptAt = nextAttemptAt
+
+	return nil
+}
+
+func (f *fakeOutboxRepository) UpdateDispatchedByID(ctx context.Context, dispatchedAt time.Time, id int) error {
+	f.message.Status = model.OutboxStatusEnumDispatched
+	f.message.DispatchedAt = &dispatchedAt
+	f.message.Attempts++
+
+	return nil
+}
+
+func (f *fakeOutboxRepository) UpdateAttemptByID(ctx context.Context, status model.OutboxStatusEnum, lastError string, nextAttemptAt time.Time, id int) error {
+	f.message.Status = status
+	f.message.LastError = &lastError
+	f.message.NextAttemptAt = nextAttemptAt
+	f.message.Attempts++
+
+	return nil
+}
+
+// fakeUnitOfWork run fn with the fake repositories and report whether a transaction is open
+type fakeUnitOfWork struct {
+	repos *repository.Repositories
+	inTx  bool
+}
+
+func (f *fakeUnitOfWork) WithTx(ctx context.Context, fn func(repos *repository.Repositories) error) error {
+	f.inTx = true
+	defer func() { f.inTx = false }()
+
+	return fn(f.repos)
+}
+
+func TestDispatchMessage(t *testing.T) {
+	tests := []struct {
+		name         string
+		attempts     int
+		handlerErr   error
+		takenOver    bool
+		wantStatus   model.OutboxStatusEnum
+		wantAttempts int
+		wantRecorded bool
+		wantErr      bool
+	}{
+		{
+			name:         "dispatched",
+			wantStatus:   model.OutboxStatusEnumDispatched,
+			wantAttempts: 1,
+			wantRecorded: true,
+		},
+		{
+			name:         "failed attempt is retried",
+			handlerErr:   model.NewError(model.Partner, "partner is down"),
+			wantStatus:   model.OutboxStatusEnumPending,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "rejected message is dead-lettered",
+			handlerErr:   model.NewError(model.Validation, "invalid payload"),
+			wantStatus:   model.OutboxStatusEnumDead,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "last attempt is dead-lettered",
+			attempts:     defaultOutboxMaxAttempts - 1,
+			handlerErr:   errors.New("timeout"),
+			wantStatus:   model.OutboxStatusEnumDead,
+			wantAttempts: defaultOutboxMaxAttempts,
+			wantErr:      true,
+		},
+		{
+			name:         "claim taken over by another dispatcher is dropped",
+			takenOver:    true,
+			wantStatus:   model.OutboxStatusEnumPending,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outboxRepo := &fakeOutboxRepository{message: &model.OutboxMessage{
+				ID:            1,
+				EventType:     model.OutboxEventTypeEnumDispenseOrder,
+				Status:        model.OutboxStatusEnumPending,
+				Attempts:      tt.attempts,
+				NextAttemptAt: time.Now().Add(-time.Second),
+			}}
+			unitOfWork := &fakeUnitOfWork{repos: &repository.Repositories{Outbox: outboxRepo}}
+
+			obs := NewOutboxService(context.Background(), &config.Configuration{Outbox: &config.Outbox{}}, outboxRepo, nil, nil, nil, nil, nil, nil, unitOfWork)
+
+			var recorded bool
+			obs.handlers[model.OutboxEventTypeEnumDispenseOrder] = func(ctx context.Context, message *model.OutboxMessage) (outboxRecord, error) {
+				if unitOfWork.inTx {
+					t.Fatal("partner is called inside database transaction")
+				}
+
+				if !outboxRepo.message.NextAttemptAt.After(time.Now()) {
+					t.Fatal("message is not claimed before partner is called")
+				}
+
+				if tt.takenOver {
+					outboxRepo.message.Attempts++
+				}
+
+				return func(ctx context.Context, repos *repository.Repositories) error {
+					recorded = true
+
+					return nil
+				}, tt.handlerErr
+			}
+
+			err := obs.dispatchMessage(context.Background(), 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("dispatchMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if outboxRepo.message.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", outboxRepo.message.Status, tt.wantStatus)
+			}
+
+			if outboxRepo.message.Attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", outboxRepo.message.Attempts, tt.wantAttempts)
+			}
+
+			if recorded != tt.wantRecorded {
+				t.Errorf("recorded = %v, want %v", recorded, tt.wantRecorded)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"e-resep-be/internal/config"
+	"e-resep-be/internal/helper"
 	"e-resep-be/internal/model"
 	"e-resep-be/internal/repository"
 	"e-resep-be/internal/requester"
@@ -10,40 +11,52 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/xendit/xendit-go/v6/invoice"
+	"github.com/jackc/pgx/v4"
 )
 
 type (
 	// PaymentService is an interface that has all the function to be implemented inside payment service
 	PaymentService interface {
 		GeneratePaymentInfo(ctx context.Context, req *model.GeneratePaymentInfoRequest) (*model.PaymentInfo, error)
-		HandleWebhookNotification(ctx context.Context, req invoice.InvoiceCallback) error
+		HandleWebhookNotification(ctx context.Context, gateway model.PaymentGatewayEnum, callbackID string, rawPayload []byte) error
 	}
 
 	// PaymentServiceImpl is an app payment struct that consists of all the dependencies needed for payment service
 	PaymentServiceImpl struct {
-		Context             context.Context
-		Config              *config.Configuration
-		MedicationRepo      repository.MedicationRepository
-		PatientRepo         repository.PatientRepository
-		PatientAddressRepo  repository.PatientAddressRepository
-		TransactionRepo     repository.TransactionRepository
-		PaymentRepo         repository.PaymentRepository
-		KimiaFarmaRequester requester.KimiaFarmaRequester
+		Context               context.Context
+		Config                *config.Configuration
+		MedicationRepo        repository.MedicationRepository
+		MedicationRequestRepo repository.MedicationRequestRepository
+		PatientRepo           repository.PatientRepository
+		PatientAddressRepo    repository.PatientAddressRepository
+		TransactionRepo       repository.TransactionRepository
+		PaymentRepo           repository.PaymentRepository
+		PaymentEventRepo      repository.PaymentEventRepository
+		PriceQuoteRepo        repository.PriceQuoteRepository
+		KimiaFarmaRequester   requester.KimiaFarmaRequester
+		PaymentGateways       requester.PaymentGatewayProvider
+		ShippingCalculator    ShippingCalculator
+		UnitOfWork            repository.UnitOfWork
 	}
 )
 
 // NewPaymentService return new instances payment service
-func NewPaymentService(ctx context.Context, config *config.Configuration, medicationRepo repository.MedicationRepository, patientRepo repository.PatientRepository, patientAddressRepo repository.PatientAddressRepository, transactionRepo repository.TransactionRepository, paymentRepo repository.PaymentRepository, kimiaFarmaRequester requester.KimiaFarmaRequester) *PaymentServiceImpl {
+func NewPaymentService(ctx context.Context, config *config.Configuration, medicationRepo repository.MedicationRepository, medicationRequestRepo repository.MedicationRequestRepository, patientRepo repository.PatientRepository, patientAddressRepo repository.PatientAddressRepository, transactionRepo repository.TransactionRepository, paymentRepo repository.PaymentRepository, paymentEventRepo repository.PaymentEventRepository, priceQuoteRepo repository.PriceQuoteRepository, kimiaFarmaRequester requester.KimiaFarmaRequester, paymentGateways requester.PaymentGatewayProvider, shippingCalculator ShippingCalculator, unitOfWork repository.UnitOfWork) *PaymentServiceImpl {
 	return &PaymentServiceImpl{
-		Context:             ctx,
-		Config:              config,
-		MedicationRepo:      medicationRepo,
-		PatientRepo:         patientRepo,
-		PatientAddressRepo:  patientAddressRepo,
-		TransactionRepo:     transactionRepo,
-		PaymentRepo:         paymentRepo,
-		KimiaFarmaRequester: kimiaFarmaRequester,
+		Context:               ctx,
+		Config:                config,
+		MedicationRepo:        medicationRepo,
+		MedicationRequestRepo: medicationRequestRepo,
+		PatientRepo:           patientRepo,
+		PatientAddressRepo:    patientAddressRepo,
+		TransactionRepo:       transactionRepo,
+		PaymentRepo:           paymentRepo,
+		PaymentEventRepo:      paymentEventRepo,
+		PriceQuoteRepo:        priceQuoteRepo,
+		KimiaFarmaRequester:   kimiaFarmaRequester,
+		PaymentGateways:       paymentGateways,
+		ShippingCalculator:    shippingCalculator,
+		UnitOfWork:            unitOfWork,
 	}
 }
 
@@ -84,101 +97,378 @@ func (ps *PaymentServiceImpl) GeneratePaymentInfo(ctx context.Context, req *mode
 		}
 	}
 
+	medications := make([]model.MedicationDB, 0, len(req.SelectedMedications))
+	medicationIDs := make([]int, 0, len(req.SelectedMedications))
+	codes := make([]string, 0, len(req.SelectedMedications))
 	for _, m := range req.SelectedMedications {
-		item := model.Item{}
-
 		medication, err := ps.MedicationRepo.GetByID(ctx, m.MedicationID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get medication by ID: %w", err)
 		}
 
-		medicationDetail, err := ps.KimiaFarmaRequester.CheckAvailabilityAndPriceMedicationByCode(ctx, medication.Code)
+		medications = append(medications, *medication)
+		medicationIDs = append(medicationIDs, medication.ID)
+		codes = append(codes, medication.Code)
+	}
+
+	// inactive, expired or fully dispensed prescription can't be paid
+	medicationRequests, err := ps.MedicationRequestRepo.GetByMedicationIDsAndPatientID(ctx, medicationIDs, patient.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get medication request by medication IDs: %w", err)
+	}
+
+	dispenseCounts, err := ps.MedicationRequestRepo.GetDispenseCountsByIDs(ctx, medicationRequestIDs(medicationRequests))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dispense count of medication requests: %w", err)
+	}
+
+	_, err = selectDispensableMedicationRequests(medicationRequests, dispenseCounts, medicationIDs, time.Now().In(helper.TimezoneJakarta))
+	if err != nil {
+		return nil, err
+	}
+
+	medicationDetails, err := ps.KimiaFarmaRequester.CheckAvailabilityBatch(ctx, codes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check medication availability and price: %w", err)
+	}
+
+	if err := medicationDetails.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check medication availability and price: %w", err)
+	}
+
+	for _, medication := range medications {
+		medicationDetail := medicationDetails.Items[medication.Code]
+		if !medicationDetail.IsAvailable {
+			return nil, model.NewError(model.Validation, fmt.Sprintf("item %d is not available", medication.ID))
+		}
+
+		resp.TotalPrice += medicationDetail.Price
+		resp.Items = append(resp.Items, model.Item{
+			ID:    medication.ID,
+			Name:  medication.Display,
+			Price: medicationDetail.Price,
+		})
+	}
+
+	// calculate shipping cost to selected address, default to the first address of patient
+	selectedAddress, err := selectPatientAddress(*addresses, req.PatientAddressID)
+	if err != nil {
+		return nil, err
+	}
+
+	if selectedAddress != nil {
+		shippingCost, err := ps.ShippingCalculator.Calculate(ctx, &model.ShippingCostRequest{
+			Origin:      pharmacyLocation(ps.Config),
+			Destination: addressLocation(*selectedAddress),
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to check medication availability and price: %w", err)
+			return nil, fmt.Errorf("failed to calculate shipping cost: %w", err)
 		}
 
-		item.ID = medication.ID
-		item.Name = medication.Display
-		item.Price = medicationDetail.Price
+		resp.PatientAddressID = selectedAddress.ID
+		resp.ShippingCost = shippingCost.Price
+		resp.TotalPrice += shippingCost.Price
+	}
 
-		resp.TotalPrice += medicationDetail.Price
-		resp.Items = append(resp.Items, item)
+	// lock the prices for checkout, so transaction will charge the same total
+	quote := model.PriceQuote{
+		PatientID:    patient.ID,
+		Items:        resp.Items,
+		ShippingCost: resp.ShippingCost,
+		TotalPrice:   resp.TotalPrice,
+		ExpiresAt:    time.Now().Add(ps.priceQuoteTTL()),
+	}
+
+	if selectedAddress != nil {
+		quote.PatientAddressID = &selectedAddress.ID
 	}
 
+	quoteID, err := ps.PriceQuoteRepo.Insert(ctx, &quote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create price quote: %w", err)
+	}
+
+	resp.QuoteID = quoteID
+	resp.QuoteExpiresAt = quote.ExpiresAt
+
 	return &resp, nil
 }
 
-func (ps *PaymentServiceImpl) HandleWebhookNotification(ctx context.Context, req invoice.InvoiceCallback) error {
-	// parse externalId to integer, due payment id is a integer
-	parsePaymentID, err := strconv.Atoi(req.ExternalId)
+// selectDispensableMedicationRequests return medication request redeemed by each medication, it is the newest request of the patient
+// that is active, inside its validity period and not yet dispensed as many times as allowed. medicationRequests must be ordered newest first
+func selectDispensableMedicationRequests(medicationRequests []model.MedicationRequestDB, dispenseCounts map[int]int, medicationIDs []int, now time.Time) (map[int]int, error) {
+	selected := make(map[int]int, len(medicationIDs))
+
+	for _, medicationID := range medicationIDs {
+		var latestErr error
+		prescribed := false
+		for _, medicationRequest := range medicationRequests {
+			if medicationRequest.MedicationID != medicationID {
+				continue
+			}
+			prescribed = true
+
+			err := medicationRequest.DispensableAt(now, dispenseCounts[medicationRequest.ID])
+			if err == nil {
+				selected[medicationID] = medicationRequest.ID
+				break
+			}
+
+			// error of the newest request is reported, older requests are only the fallback
+			if latestErr == nil {
+				latestErr = err
+			}
+		}
+
+		if !prescribed {
+			return nil, model.NewError(model.Validation, fmt.Sprintf("item %d is not prescribed for patient", medicationID))
+		}
+
+		if _, ok := selected[medicationID]; !ok {
+			return nil, model.NewError(model.Conflict, fmt.Sprintf("item %d can't be paid, %v", medicationID, latestErr))
+		}
+	}
+
+	return selected, nil
+}
+
+func medicationRequestIDs(medicationRequests []model.MedicationRequestDB) []int {
+	ids := make([]int, 0, len(medicationRequests))
+	for _, medicationRequest := range medicationRequests {
+		ids = append(ids, medicationRequest.ID)
+	}
+
+	return ids
+}
+
+// selectPatientAddress return address with given id, or the first address when id is empty
+func selectPatientAddress(addresses []model.PatientAddress, id int) (*model.PatientAddress, error) {
+	if len(addresses) == 0 {
+		if id != 0 {
+			return nil, model.NewError(model.Validation, "patient address not found")
+		}
+
+		return nil, nil
+	}
+
+	if id == 0 {
+		return &addresses[0], nil
+	}
+
+	for i := range addresses {
+		if addresses[i].ID == id {
+			return &addresses[i], nil
+		}
+	}
+
+	return nil, model.NewError(model.Validation, "patient address not found")
+}
+
+// priceQuoteTTL return configured price quote lifetime, default to 15 minutes
+func (ps *PaymentServiceImpl) priceQuoteTTL() time.Duration {
+	if ps.Config.Const.PriceQuoteTTL > 0 {
+		return time.Duration(ps.Config.Const.PriceQuoteTTL) * time.Minute
+	}
+
+	return 15 * time.Minute
+}
+
+// HandleWebhookNotification record every payment gateway callback and apply its status once, callback that is already processed
+// or that would move payment into illegal status is recorded and ignored
+func (ps *PaymentServiceImpl) HandleWebhookNotification(ctx context.Context, gatewayName model.PaymentGatewayEnum, callbackID string, rawPayload []byte) error {
+	gateway, err := ps.PaymentGateways.Get(gatewayName)
 	if err != nil {
 		return err
 	}
 
-	// recheck payment by id
-	payment, err := ps.PaymentRepo.GetByID(ctx, parsePaymentID)
+	notification, err := gateway.ParseChargeNotification(ctx, callbackID, rawPayload)
 	if err != nil {
 		return err
 	}
 
-	// recheck transaction by id
-	transaction, err := ps.TransactionRepo.GetByID(ctx, payment.TransactionID)
+	event, err := ps.PaymentEventRepo.InsertOrGet(ctx, &model.PaymentEvent{
+		CallbackID: notification.CallbackID,
+		InvoiceID:  notification.Charge.PartnerID,
+		ExternalID: notification.Charge.ReferenceID,
+		Status:     notification.Charge.PartnerStatus,
+		RawPayload: rawPayload,
+	})
 	if err != nil {
 		return err
 	}
 
-	// update status transaction and payment based on status from xendit callback notification
-	switch req.Status {
-	case string(invoice.INVOICESTATUS_PENDING):
-	case string(invoice.INVOICESTATUS_PAID):
-		err := ps.TransactionRepo.UpdateByID(ctx, model.Transaction{
-			Status: model.TransactionStatusEnumSuccess,
-		}, transaction.ID)
-		if err != nil {
-			return err
-		}
+	if event.ProcessedAt != nil {
+		return nil
+	}
 
-		parsePaidAt, err := time.Parse(time.RFC3339, *req.PaidAt)
+	// payment row is locked until the status of payment, transaction and event are committed together,
+	// so concurrent callbacks of the same charge are applied one after another
+	return ps.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		result, note, err := ps.applyChargeNotification(ctx, repos, notification.Charge)
 		if err != nil {
 			return err
 		}
 
-		err = ps.PaymentRepo.UpdateByID(ctx, model.Payment{
-			Status:      model.PaymentStatusEnumSuccess,
-			CompletedAt: &parsePaidAt,
-		}, parsePaymentID)
-		if err != nil {
-			return err
+		return repos.PaymentEvent.UpdateResultByID(ctx, result, note, event.ID)
+	})
+}
+
+// applyChargeNotification update status transaction and payment based on charge status from payment gateway callback
+func (ps *PaymentServiceImpl) applyChargeNotification(ctx context.Context, repos *repository.Repositories, charge model.Charge) (model.PaymentEventResultEnum, string, error) {
+	// reference id is the payment id
+	parsePaymentID, err := strconv.Atoi(charge.ReferenceID)
+	if err != nil {
+		return "", "", model.NewError(model.Validation, "invalid payment reference id")
+	}
+
+	payment, err := repos.Payment.GetByIDForUpdate(ctx, parsePaymentID)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return "", "", model.NewError(model.NotFound, "payment not found")
 		}
-	case string(invoice.INVOICESTATUS_EXPIRED):
-		err := ps.TransactionRepo.UpdateByID(ctx, model.Transaction{
-			Status: model.TransactionStatusEnumExpired,
-		}, transaction.ID)
-		if err != nil {
-			return err
+
+		return "", "", err
+	}
+
+	err = verifyChargeOfPayment(payment, charge)
+	if err != nil {
+		return "", "", err
+	}
+
+	transactionStatus, paymentStatus, ok := mapChargeStatus(charge.Status)
+	if !ok {
+		return model.PaymentEventResultEnumIgnored, fmt.Sprintf("status %s doesn't change payment", charge.PartnerStatus), nil
+	}
+
+	var completedAt *time.Time
+	if paymentStatus == model.PaymentStatusEnumSuccess {
+		completedAt = charge.PaidAt
+	}
+
+	return applyPaymentStatus(ctx, repos, paymentStatusUpdate{
+		PaymentID:         parsePaymentID,
+		TransactionStatus: transactionStatus,
+		PaymentStatus:     paymentStatus,
+		CompletedAt:       completedAt,
+	})
+}
+
+// verifyChargeOfPayment reject charge that doesn't belong to the payment, a valid notification of one payment
+// gateway must not be able to change payment that is charged by another one or for a different amount
+func verifyChargeOfPayment(payment *model.Payment, charge model.Charge) error {
+	// payments created before gateway is recorded are charged by xendit
+	gateway := payment.Gateway
+	if gateway == "" {
+		gateway = model.PaymentGatewayEnumXendit
+	}
+
+	if charge.Gateway != gateway {
+		return model.NewError(model.Validation, fmt.Sprintf("payment is charged by %s, not by %s", gateway, charge.Gateway))
+	}
+
+	if charge.PartnerID != payment.PartnerID {
+		return model.NewError(model.Validation, "charge partner id doesn't match payment")
+	}
+
+	if charge.Amount != payment.FinalPrice {
+		return model.NewError(model.Validation, fmt.Sprintf("charge amount %d doesn't match payment amount %d", charge.Amount, payment.FinalPrice))
+	}
+
+	return nil
+}
+
+// paymentStatusUpdate is a status change of payment and its transaction reported by payment partner
+type paymentStatusUpdate struct {
+	PaymentID         int
+	PartnerID         string
+	TransactionStatus model.TransactionStatusEnum
+	PaymentStatus     model.PaymentStatusEnum
+	CompletedAt       *time.Time
+	ExpiresAt         *time.Time
+}
+
+// applyPaymentStatus update status transaction and payment, update that is already applied
+// or that would move payment into illegal status is ignored. It must be called inside unit of work
+func applyPaymentStatus(ctx context.Context, repos *repository.Repositories, update paymentStatusUpdate) (model.PaymentEventResultEnum, string, error) {
+	// recheck payment by id and lock it
+	payment, err := repos.Payment.GetByIDForUpdate(ctx, update.PaymentID)
+	if err != nil {
+		return "", "", err
+	}
+
+	// recheck transaction by id
+	transaction, err := repos.Transaction.GetByID(ctx, payment.TransactionID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if payment.Status == update.PaymentStatus && transaction.Status == update.TransactionStatus {
+		return model.PaymentEventResultEnumIgnored, fmt.Sprintf("payment is already %s", update.PaymentStatus), nil
+	}
+
+	if !payment.Status.CanTransitionTo(update.PaymentStatus) || !transaction.Status.CanTransitionTo(update.TransactionStatus) {
+		return model.PaymentEventResultEnumIgnored, fmt.Sprintf("illegal transition payment %s -> %s, transaction %s -> %s", payment.Status, update.PaymentStatus, transaction.Status, update.TransactionStatus), nil
+	}
+
+	err = repos.Transaction.UpdateByID(ctx, model.Transaction{
+		Status: update.TransactionStatus,
+	}, transaction.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = repos.Payment.UpdateByID(ctx, model.Payment{
+		Status:      update.PaymentStatus,
+		PartnerID:   update.PartnerID,
+		CompletedAt: update.CompletedAt,
+		ExpiresAt:   update.ExpiresAt,
+	}, payment.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	// paid transaction is ready to be fulfilled by pharmacy
+	if update.TransactionStatus == model.TransactionStatusEnumSuccess {
+		paidAt := time.Now()
+		if update.CompletedAt != nil {
+			paidAt = *update.CompletedAt
 		}
 
-		err = ps.PaymentRepo.UpdateByID(ctx, model.Payment{
-			Status: model.PaymentStatusEnumExpired,
-		}, parsePaymentID)
+		err = repos.Fulfilment.Insert(ctx, &model.Fulfilment{
+			TransactionID: transaction.ID,
+			Status:        model.FulfilmentStatusEnumPaid,
+			PaidAt:        paidAt,
+		})
 		if err != nil {
-			return err
+			return "", "", err
 		}
-	case string(invoice.INVOICESTATUS_XENDIT_ENUM_DEFAULT_FALLBACK):
-		err := ps.TransactionRepo.UpdateByID(ctx, model.Transaction{
-			Status: model.TransactionStatusEnumFailed,
-		}, transaction.ID)
+
+		// order is pushed to kimia farma by outbox dispatcher
+		message, err := model.NewOutboxMessage(model.OutboxEventTypeEnumDispenseOrder, strconv.Itoa(transaction.ID), model.DispenseOrderPayload{
+			TransactionID: transaction.ID,
+		})
 		if err != nil {
-			return err
+			return "", "", err
 		}
 
-		err = ps.PaymentRepo.UpdateByID(ctx, model.Payment{
-			Status: model.PaymentStatusEnumFailed,
-		}, parsePaymentID)
-		if err != nil {
-			return err
+		if _, err := repos.Outbox.Insert(ctx, message); err != nil {
+			return "", "", err
 		}
 	}
 
-	return nil
+	return model.PaymentEventResultEnumApplied, "", nil
+}
+
+// mapChargeStatus return final transaction and payment status of charge status, false when the charge is still waiting for payment
+func mapChargeStatus(status model.ChargeStatusEnum) (model.TransactionStatusEnum, model.PaymentStatusEnum, bool) {
+	switch status {
+	case model.ChargeStatusEnumPaid:
+		return model.TransactionStatusEnumSuccess, model.PaymentStatusEnumSuccess, true
+	case model.ChargeStatusEnumExpired:
+		return model.TransactionStatusEnumExpired, model.PaymentStatusEnumExpired, true
+	case model.ChargeStatusEnumFailed:
+		return model.TransactionStatusEnumFailed, model.PaymentStatusEnumFailed, true
+	default:
+		return "", "", false
+	}
 }
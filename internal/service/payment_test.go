@@ -0,0 +1,219 @@
+package service
+
+import (
+	"e-resep-be/internal/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectDispensableMedicationRequests(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	now := time.Date(2024, 5, 15, 10, 0, 0, 0, jakarta)
+
+	medicationRequest := func(id, medicationID int, status model.MedicationRequestStatusEnum, repeats int, validity model.ValidityPeriod) model.MedicationRequestDB {
+		return model.MedicationRequestDB{
+			ID:           id,
+			MedicationID: medicationID,
+			Status:       status,
+			DispenseRequest: model.DispenseRequest{
+				NumberOfRepeatsAllowed: repeats,
+				ValidityPeriod:         validity,
+			},
+		}
+	}
+
+	active := model.MedicationRequestStatusEnumActive
+	valid := model.ValidityPeriod{Start: "2024-05-01", End: "2024-05-31"}
+
+	tests := []struct {
+		name string
+		// medicationRequests is ordered newest first
+		medicationRequests []model.MedicationRequestDB
+		dispenseCounts     map[int]int
+		medicationIDs      []int
+		want               map[int]int
+		wantKind           model.ErrorKind
+	}{
+		{
+			name: "never dispensed",
+			medicationRequests: []model.MedicationRequestDB{
+				medicationRequest(1, 10, active, 0, valid),
+			},
+			medicationIDs: []int{10},
+			want:          map[int]int{10: 1},
+		},
+		{
+			name: "newest request is preferred",
+			medicationRequests: []model.MedicationRequestDB{
+				medicationRequest(2, 10, active, 0, valid),
+				medicationRequest(1, 10, active, 0, valid),
+			},
+			medicationIDs: []int{10},
+			want:          map[int]int{10: 2},
+		},
+		{
+			name: "repeat is left",
+			medicationRequests: []model.MedicationRequestDB{
+				medicationRequest(1, 10, active, 2, valid),
+			},
+			dispenseCounts: map[int]int{1: 2},
+			medicationIDs:  []int{10},
+			want:           map[int]int{10: 1},
+		},
+		{
+			name: "fallback to older request when newest is used up",
+			medicationRequests: []model.MedicationRequestDB{
+				medicationRequest(2, 10, active, 0, valid),
+				medicationRequest(1, 10, active, 1, valid),
+			},
+			dispenseCounts: map[int]int{2: 1, 1: 1},
+			medicationIDs:  []int{10},
+			want:           map[int]int{10: 1},
+		},
+		{
+			name: "every item of the order",
+			medicationRequests: []model.MedicationRequestDB{
+				medicationRequest(3, 11, active, 0, model.ValidityPeriod{}),
+				medicationRequest(1, 10, active, 0, valid),
+			},
+			medicationIDs: []int{10, 11},
+			want:          map[int]int{10: 1, 11: 3},
+		},
+		{
+			name: "every repeat is dispensed",
+			medicationRequests: []model.MedicationRequestDB{
+				medicationRequest(1, 10, active, 1, valid),
+			},
+			dispenseCounts: map[int]int{1: 2},
+			medicationIDs:  []int{10},
+			wantKind:       model.Conflict,
+		},
+		{
+			name: "prescription is expired",
+			medicationRequests: []model.MedicationRequestDB{
+				medicationRequest(1, 10, active, 0, model.ValidityPeriod{End: "2024-05-14"}),
+			},
+			medicationIDs: []int{10},
+			wantKind:      model.Conflict,
+		},
+		{
+			name: "prescription is not valid yet",
+			medicationRequests: []model.MedicationRequestDB{
+				medicationRequest(1, 10, active, 0, model.ValidityPeriod{Start: "2024-05-16"}),
+			},
+			medicationIDs: []int{10},
+			wantKind:      model.Conflict,
+		},
+		{
+			name: "prescription is cancelled",
+			medicationRequests: []model.MedicationRequestDB{
+				medicationRequest(1, 10, model.MedicationRequestStatusEnumCancelled, 0, valid),
+			},
+			medicationIDs: []int{10},
+			wantKind:      model.Conflict,
+		},
+		{
+			name: "item is not prescribed",
+			medicationRequests: []model.MedicationRequestDB{
+				medicationRequest(1, 10, active, 0, valid),
+			},
+			medicationIDs: []int{10, 12},
+			wantKind:      model.Validation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectDispensableMedicationRequests(tt.medicationRequests, tt.dispenseCounts, tt.medicationIDs, now)
+			if tt.wantKind != "" {
+				if model.KindOf(err) != tt.wantKind {
+					t.Fatalf("error = %v, want %s", err, tt.wantKind)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyChargeOfPayment(t *testing.T) {
+	payment := &model.Payment{
+		PartnerID:  "42",
+		FinalPrice: 150000,
+		Gateway:    model.PaymentGatewayEnumMidtrans,
+	}
+
+	tests := []struct {
+		name    string
+		payment *model.Payment
+		charge  model.Charge
+		wantErr bool
+	}{
+		{
+			name:    "matching charge",
+			payment: payment,
+			charge:  model.Charge{Gateway: model.PaymentGatewayEnumMidtrans, PartnerID: "42", Amount: 150000},
+		},
+		{
+			name:    "amount is not reported",
+			payment: &model.Payment{PartnerID: "inv-1", FinalPrice: 150000, Gateway: model.PaymentGatewayEnumXendit},
+			charge:  model.Charge{Gateway: model.PaymentGatewayEnumXendit, PartnerID: "inv-1"},
+			wantErr: true,
+		},
+		{
+			name:    "payment without gateway is charged by xendit",
+			payment: &model.Payment{PartnerID: "inv-1", FinalPrice: 150000},
+			charge:  model.Charge{Gateway: model.PaymentGatewayEnumXendit, PartnerID: "inv-1", Amount: 150000},
+		},
+		{
+			name:    "payment without gateway reported by midtrans",
+			payment: &model.Payment{PartnerID: "inv-1", FinalPrice: 150000},
+			charge:  model.Charge{Gateway: model.PaymentGatewayEnumMidtrans, PartnerID: "inv-1", Amount: 150000},
+			wantErr: true,
+		},
+		{
+			name:    "other gateway",
+			payment: payment,
+			charge:  model.Charge{Gateway: model.PaymentGatewayEnumXendit, PartnerID: "42", Amount: 150000},
+			wantErr: true,
+		},
+		{
+			name:    "other partner id",
+			payment: payment,
+			charge:  model.Charge{Gateway: model.PaymentGatewayEnumMidtrans, PartnerID: "43", Amount: 150000},
+			wantErr: true,
+		},
+		{
+			name:    "other amount",
+			payment: payment,
+			charge:  model.Charge{Gateway: model.PaymentGatewayEnumMidtrans, PartnerID: "42", Amount: 1000},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyChargeOfPayment(tt.payment, tt.charge)
+			if tt.wantErr {
+				if model.KindOf(err) != model.Validation {
+					t.Fatalf("error = %v, want validation error", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
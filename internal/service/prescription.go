@@ -2,18 +2,28 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
 
 	"e-resep-be/internal/config"
 	"e-resep-be/internal/model"
 	"e-resep-be/internal/repository"
 	"e-resep-be/internal/requester"
+
+	"github.com/jackc/pgx/v4"
 )
 
 type (
 	// PrescriptionService is an interface that has all the function to be implemented inside prescription service
 	PrescriptionService interface {
-		Create(ctx context.Context, req *model.PrescriptionRequest, phoneNumber string) error
+		Create(ctx context.Context, req *model.PrescriptionRequest, phoneNumber, email, idempotencyKey string) error
+		CreateBundle(ctx context.Context, bundle *model.Bundle, phoneNumber, email string) (*model.Bundle, error)
 		GetByPrescriptionID(ctx context.Context, id string) ([]model.Prescription, error)
+		UpdateMedicationRequestStatus(ctx context.Context, refID string, req *model.UpdateMedicationRequestStatusRequest) error
+		UpdatePrescriptionStatus(ctx context.Context, prescriptionID string, req *model.UpdateMedicationRequestStatusRequest) error
 	}
 
 	// PrescriptionServiceImpl is an app prescription struct that consists of all the dependencies needed for prescription service
@@ -21,35 +31,263 @@ type (
 		Context             context.Context
 		Config              *config.Configuration
 		PrescriptionRepo    repository.PrescriptionRepository
-		WhatsappRequester   requester.WhatsappRequester
 		KimiaFarmaRequester requester.KimiaFarmaRequester
+		UnitOfWork          repository.UnitOfWork
 	}
 )
 
 // NewPrescriptionService return new instances prescription service
-func NewPrescriptionService(ctx context.Context, config *config.Configuration, prescriptionRepo repository.PrescriptionRepository, whatsappRequester requester.WhatsappRequester, kimiaFarmaRequester requester.KimiaFarmaRequester) *PrescriptionServiceImpl {
+func NewPrescriptionService(ctx context.Context, config *config.Configuration, prescriptionRepo repository.PrescriptionRepository, kimiaFarmaRequester requester.KimiaFarmaRequester, unitOfWork repository.UnitOfWork) *PrescriptionServiceImpl {
 	return &PrescriptionServiceImpl{
 		Context:             ctx,
 		Config:              config,
 		PrescriptionRepo:    prescriptionRepo,
-		WhatsappRequester:   whatsappRequester,
 		KimiaFarmaRequester: kimiaFarmaRequester,
+		UnitOfWork:          unitOfWork,
 	}
 }
 
-func (ps *PrescriptionServiceImpl) Create(ctx context.Context, req *model.PrescriptionRequest, phoneNumber string) error {
-	// insert to database
-	err := ps.PrescriptionRepo.Insert(ctx, req, phoneNumber)
+// Create store prescription, whatsapp message to patient is written to outbox in the same transaction and sent by outbox dispatcher.
+// Retry with the same idempotency key is acknowledged without storing again, the same key with different request is a conflict
+func (ps *PrescriptionServiceImpl) Create(ctx context.Context, req *model.PrescriptionRequest, phoneNumber, email, idempotencyKey string) error {
+	return ps.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		if idempotencyKey != "" {
+			requestHash, err := hashRequest(req, phoneNumber, email)
+			if err != nil {
+				return err
+			}
+
+			key, inserted, err := repos.IdempotencyKey.InsertOrGet(ctx, &model.IdempotencyKey{
+				Scope:       model.IdempotencyScopePrescription,
+				Key:         idempotencyKey,
+				RequestHash: requestHash,
+			})
+			if err != nil {
+				return err
+			}
+
+			if !inserted {
+				if key.RequestHash != requestHash {
+					return model.NewError(model.Conflict, "idempotency key is already used for different prescription")
+				}
+
+				return nil
+			}
+		}
+
+		_, err := insertPrescriptions(ctx, repos, []model.BundlePrescription{{Prescription: *req}}, phoneNumber, email)
+
+		return err
+	})
+}
+
+// CreateBundle store every medication request of FHIR transaction bundle in one database transaction,
+// patient is notified once per prescription. Invalid bundle is rejected as a whole, see model.Bundle.Prescriptions
+func (ps *PrescriptionServiceImpl) CreateBundle(ctx context.Context, bundle *model.Bundle, phoneNumber, email string) (*model.Bundle, error) {
+	prescriptions, err := bundle.Prescriptions()
 	if err != nil {
+		return nil, err
+	}
+
+	var results []model.PrescriptionUpsertResultEnum
+	err = ps.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		results, err = insertPrescriptions(ctx, repos, prescriptions, phoneNumber, email)
+
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := model.NewTransactionResponse(len(bundle.Entry))
+	for i, p := range prescriptions {
+		// stored item that is posted again is answered as FHIR update
+		status := fmt.Sprintf("%d %s", http.StatusOK, http.StatusText(http.StatusOK))
+		if results[i] == model.PrescriptionUpsertResultEnumCreated {
+			status = fmt.Sprintf("%d %s", http.StatusCreated, http.StatusText(http.StatusCreated))
+		}
+
+		resp.Entry[p.MedicationEntry].Response = &model.BundleEntryResponse{Status: status, Location: "Medication/" + p.Prescription.Medication.ID}
+		resp.Entry[p.RequestEntry].Response = &model.BundleEntryResponse{Status: status, Location: "MedicationRequest/" + p.Prescription.MedicationRequest.ID}
+
+		if p.PatientEntry >= 0 {
+			resp.Entry[p.PatientEntry].Response = &model.BundleEntryResponse{
+				Status:   fmt.Sprintf("%d %s", http.StatusOK, http.StatusText(http.StatusOK)),
+				Location: p.Prescription.MedicationRequest.Subject.Reference,
+			}
+		}
 	}
 
-	if phoneNumber != "" {
+	return &resp, nil
+}
+
+// insertPrescriptions upsert prescriptions and write one whatsapp message per prescription that has new item to outbox, must be called inside unit of work.
+// Patient contact from the bundle is preferred over the given phone number and email, the result of each prescription is returned in the same order
+func insertPrescriptions(ctx context.Context, repos *repository.Repositories, prescriptions []model.BundlePrescription, phoneNumber, email string) ([]model.PrescriptionUpsertResultEnum, error) {
+	var (
+		results  = make([]model.PrescriptionUpsertResultEnum, 0, len(prescriptions))
+		notified = map[string]bool{}
+		messages = []*model.OutboxMessage{}
+	)
+
+	for _, p := range prescriptions {
+		req := p.Prescription
+
+		patientPhoneNumber, patientEmail := phoneNumber, email
+		if p.PhoneNumber != "" {
+			patientPhoneNumber = p.PhoneNumber
+		}
+		if p.Email != "" {
+			patientEmail = p.Email
+		}
+
+		// upsert to database
+		result, err := repos.Prescription.Upsert(ctx, &req, patientPhoneNumber, patientEmail)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+
+		// patient is already notified when the item was first stored
+		prescriptionID := req.MedicationRequest.Identifier[0].Value
+		if result != model.PrescriptionUpsertResultEnumCreated || patientPhoneNumber == "" || notified[prescriptionID] {
+			continue
+		}
+		notified[prescriptionID] = true
+
 		// send message to patient number through whatsapp
-		err = ps.WhatsappRequester.SendMessageByRecipentNumber(ctx, req.MedicationRequest.Subject.Display, req.MedicationRequest.Identifier[0].Value, phoneNumber, model.TemplateSendPrescription)
+		message, err := model.NewOutboxMessage(model.OutboxEventTypeEnumWhatsappMessage, prescriptionID, model.WhatsappMessagePayload{
+			PatientName:  req.MedicationRequest.Subject.Display,
+			PatientID:    prescriptionID,
+			Destination:  patientPhoneNumber,
+			TemplateName: model.TemplateSendPrescription,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	for _, message := range messages {
+		if _, err := repos.Outbox.Insert(ctx, message); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// hashRequest return hash of prescription request and patient contact, to tell retry from other request sent with the same idempotency key
+func hashRequest(req *model.PrescriptionRequest, phoneNumber, email string) (string, error) {
+	reqBytes, err := json.Marshal(struct {
+		Prescription *model.PrescriptionRequest `json:"prescription"`
+		PhoneNumber  string                     `json:"phone_number"`
+		Email        string                     `json:"email"`
+	}{req, phoneNumber, email})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(reqBytes)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// UpdateMedicationRequestStatus move one prescription item into the given status, patient is notified when it is cancelled
+func (ps *PrescriptionServiceImpl) UpdateMedicationRequestStatus(ctx context.Context, refID string, req *model.UpdateMedicationRequestStatusRequest) error {
+	return ps.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		medicationRequest, err := repos.MedicationRequest.GetByRefIDForUpdate(ctx, refID)
+		if err != nil {
+			if err.Error() == pgx.ErrNoRows.Error() {
+				return model.NewError(model.NotFound, fmt.Sprintf("medication request %s is not found", refID))
+			}
+
+			return err
+		}
+
+		return updateMedicationRequestsStatus(ctx, repos, []model.MedicationRequestDB{*medicationRequest}, req)
+	})
+}
+
+// UpdatePrescriptionStatus move every item of prescription into the given status, patient is notified once when it is cancelled
+func (ps *PrescriptionServiceImpl) UpdatePrescriptionStatus(ctx context.Context, prescriptionID string, req *model.UpdateMedicationRequestStatusRequest) error {
+	return ps.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		medicationRequests, err := repos.MedicationRequest.GetByPrescriptionIDForUpdate(ctx, prescriptionID)
+		if err != nil {
+			return err
+		}
+
+		if len(medicationRequests) == 0 {
+			return model.NewError(model.NotFound, fmt.Sprintf("prescription %s is not found", prescriptionID))
+		}
+
+		return updateMedicationRequestsStatus(ctx, repos, medicationRequests, req)
+	})
+}
+
+// updateMedicationRequestsStatus move locked medication requests into the given status, must be called inside unit of work.
+// Request that is already in the status is left as is, so retried update doesn't notify patient twice
+func updateMedicationRequestsStatus(ctx context.Context, repos *repository.Repositories, medicationRequests []model.MedicationRequestDB, req *model.UpdateMedicationRequestStatusRequest) error {
+	var (
+		ids             []int
+		prescriptionIDs []string
+		patientIDs      = map[string]int{}
+	)
+
+	for _, medicationRequest := range medicationRequests {
+		if medicationRequest.Status == req.Status {
+			continue
+		}
+
+		if !medicationRequest.Status.CanTransitionTo(req.Status) {
+			return model.NewError(model.Conflict, fmt.Sprintf("medication request %s can't move from %s to %s", medicationRequest.RefID, medicationRequest.Status, req.Status))
+		}
+
+		ids = append(ids, medicationRequest.ID)
+		if _, ok := patientIDs[medicationRequest.PrescriptionID]; !ok {
+			prescriptionIDs = append(prescriptionIDs, medicationRequest.PrescriptionID)
+			patientIDs[medicationRequest.PrescriptionID] = medicationRequest.PatientID
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	err := repos.MedicationRequest.UpdateStatusByIDs(ctx, req.Status, req.StatusReason, ids)
+	if err != nil {
+		return err
+	}
+
+	if req.Status != model.MedicationRequestStatusEnumCancelled {
+		return nil
+	}
+
+	// tell patient their prescription can no longer be redeemed
+	for _, prescriptionID := range prescriptionIDs {
+		patient, err := repos.Patient.GetByID(ctx, patientIDs[prescriptionID])
 		if err != nil {
 			return err
 		}
+
+		if patient.PhoneNumber == "" {
+			continue
+		}
+
+		message, err := model.NewOutboxMessage(model.OutboxEventTypeEnumWhatsappMessage, prescriptionID, model.WhatsappMessagePayload{
+			PatientName:  patient.Name,
+			PatientID:    prescriptionID,
+			Destination:  patient.PhoneNumber,
+			TemplateName: model.TemplateCancelPrescription,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := repos.Outbox.Insert(ctx, message); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -62,14 +300,26 @@ func (ps *PrescriptionServiceImpl) GetByPrescriptionID(ctx context.Context, id s
 	}
 
 	if len(prescriptions) > 0 {
+		codes := make([]string, 0, len(prescriptions))
+		for _, p := range prescriptions {
+			codes = append(codes, p.Code)
+		}
+
+		kimiaFarmaResp, err := ps.KimiaFarmaRequester.CheckAvailabilityBatch(ctx, codes)
+		if err != nil {
+			return []model.Prescription{}, err
+		}
+
+		// medication that failed to be checked is shown as unavailable with the reason
 		for i := range prescriptions {
-			kimiaFarmaResp, err := ps.KimiaFarmaRequester.CheckAvailabilityAndPriceMedicationByCode(ctx, prescriptions[i].Code)
-			if err != nil {
-				return []model.Prescription{}, err
+			if errCode, ok := kimiaFarmaResp.Errors[prescriptions[i].Code]; ok {
+				prescriptions[i].AvailabilityError = errCode.Error()
+				continue
 			}
 
-			prescriptions[i].IsAvailable = kimiaFarmaResp.IsAvailable
-			prescriptions[i].Price = kimiaFarmaResp.Price
+			item := kimiaFarmaResp.Items[prescriptions[i].Code]
+			prescriptions[i].IsAvailable = item.IsAvailable
+			prescriptions[i].Price = item.Price
 		}
 	}
 
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/helper"
+	"e-resep-be/internal/model"
+	"e-resep-be/internal/repository"
+	"e-resep-be/internal/requester"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// defaultPendingTransactionTimeout is used when PENDING_TRANSACTION_TIMEOUT is not configured
+	defaultPendingTransactionTimeout = 30 * time.Minute
+	// defaultProcessPaymentTimeout is used when PROCESS_PAYMENT_TIMEOUT is not configured
+	defaultProcessPaymentTimeout = 10 * time.Minute
+	// reconciliationBatchSize limit the number of transactions resolved in a single run
+	reconciliationBatchSize = 100
+)
+
+type (
+	// ReconciliationService is an interface that has all the function to be implemented inside reconciliation service
+	ReconciliationService interface {
+		ResolveStalePendingTransactions(ctx context.Context) error
+		ReconcileProcessPayments(ctx context.Context) error
+		GetDiscrepancies(ctx context.Context, pages *helper.Pages) ([]model.ReconciliationDiscrepancy, error)
+	}
+
+	// ReconciliationServiceImpl is an app reconciliation struct that consists of all the dependencies needed for reconciliation service
+	ReconciliationServiceImpl struct {
+		Context                       context.Context
+		Config                        *config.Configuration
+		TransactionRepo               repository.TransactionRepository
+		PaymentRepo                   repository.PaymentRepository
+		ReconciliationDiscrepancyRepo repository.ReconciliationDiscrepancyRepository
+		PaymentGateways               requester.PaymentGatewayProvider
+		UnitOfWork                    repository.UnitOfWork
+	}
+)
+
+// NewReconciliationService return new instances reconciliation service
+func NewReconciliationService(ctx context.Context, config *config.Configuration, transactionRepo repository.TransactionRepository, paymentRepo repository.PaymentRepository, reconciliationDiscrepancyRepo repository.ReconciliationDiscrepancyRepository, paymentGateways requester.PaymentGatewayProvider, unitOfWork repository.UnitOfWork) *ReconciliationServiceImpl {
+	return &ReconciliationServiceImpl{
+		Context:                       ctx,
+		Config:                        config,
+		TransactionRepo:               transactionRepo,
+		PaymentRepo:                   paymentRepo,
+		ReconciliationDiscrepancyRepo: reconciliationDiscrepancyRepo,
+		PaymentGateways:               paymentGateways,
+		UnitOfWork:                    unitOfWork,
+	}
+}
+
+// ResolveStalePendingTransactions resolve transactions that stay pending longer than configured timeout.
+// Transaction which charge exists on payment gateway follow its status, otherwise it is marked as failed
+func (rs *ReconciliationServiceImpl) ResolveStalePendingTransactions(ctx context.Context) error {
+	transactions, err := rs.TransactionRepo.GetByStatusCreatedBefore(ctx, model.TransactionStatusEnumPending, time.Now().Add(-rs.pendingTransactionTimeout()), reconciliationBatchSize)
+	if err != nil {
+		return err
+	}
+
+	// keep resolving the rest when one transaction fail, it will be retried on the next run
+	var errs []error
+	for _, transaction := range transactions {
+		if err := rs.resolvePendingTransaction(ctx, transaction); err != nil {
+			errs = append(errs, fmt.Errorf("transaction %d: %w", transaction.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (rs *ReconciliationServiceImpl) resolvePendingTransaction(ctx context.Context, transaction model.Transaction) error {
+	payment, err := rs.PaymentRepo.GetByTransactionID(ctx, transaction.ID)
+	if err != nil {
+		if err.Error() != pgx.ErrNoRows.Error() {
+			return err
+		}
+
+		// transaction without payment never reach payment gateway
+		return rs.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+			return failPendingTransaction(ctx, repos, transaction.ID, 0)
+		})
+	}
+
+	gateway, err := rs.PaymentGateways.Get(payment.Gateway)
+	if err != nil {
+		return err
+	}
+
+	// charge reference id is the payment id, charge may exist when the app stopped right after creating it
+	charge, err := gateway.FindChargeByReferenceID(ctx, payment)
+	if err != nil {
+		return err
+	}
+
+	if charge == nil {
+		return rs.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+			return failPendingTransaction(ctx, repos, transaction.ID, payment.ID)
+		})
+	}
+
+	// charge found by reference id isn't known to the payment yet, only the amount can be checked against it
+	if charge.Amount != payment.FinalPrice {
+		return model.NewError(model.Validation, fmt.Sprintf("charge amount %d doesn't match payment amount %d", charge.Amount, payment.FinalPrice))
+	}
+
+	return rs.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		return applyCharge(ctx, repos, payment.ID, charge)
+	})
+}
+
+// applyCharge move pending transaction and payment into the status of their charge on payment gateway
+func applyCharge(ctx context.Context, repos *repository.Repositories, paymentID int, charge *model.Charge) error {
+	transactionStatus, paymentStatus, ok := mapChargeStatus(charge.Status)
+	if !ok {
+		// charge is still waiting for payment
+		transactionStatus, paymentStatus = model.TransactionStatusEnumProcess, model.PaymentStatusEnumProcess
+	}
+
+	update := paymentStatusUpdate{
+		PaymentID:         paymentID,
+		PartnerID:         charge.PartnerID,
+		TransactionStatus: transactionStatus,
+		PaymentStatus:     paymentStatus,
+	}
+
+	if paymentStatus == model.PaymentStatusEnumProcess {
+		update.ExpiresAt = charge.ExpiresAt
+	}
+
+	if paymentStatus == model.PaymentStatusEnumSuccess {
+		update.CompletedAt = charge.PaidAt
+	}
+
+	_, _, err := applyPaymentStatus(ctx, repos, update)
+
+	return err
+}
+
+// ReconcileProcessPayments poll charge of payments that stay in process longer than configured timeout and apply its status
+// the same way as webhook. Every payment which status differ from payment gateway is reported as discrepancy
+func (rs *ReconciliationServiceImpl) ReconcileProcessPayments(ctx context.Context) error {
+	payments, err := rs.PaymentRepo.GetByStatusUpdatedBefore(ctx, model.PaymentStatusEnumProcess, time.Now().Add(-rs.processPaymentTimeout()), reconciliationBatchSize)
+	if err != nil {
+		return err
+	}
+
+	// keep reconciling the rest when one payment fail, it will be retried on the next run
+	var errs []error
+	for _, payment := range payments {
+		if err := rs.reconcilePayment(ctx, payment); err != nil {
+			errs = append(errs, fmt.Errorf("payment %d: %w", payment.ID, err))
+		}
+
+		// polled payment move to the back of the next batch, also when partner is still pending or polling fail
+		if err := rs.PaymentRepo.UpdateReconciledAtByID(ctx, time.Now(), payment.ID); err != nil {
+			errs = append(errs, fmt.Errorf("payment %d: %w", payment.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (rs *ReconciliationServiceImpl) reconcilePayment(ctx context.Context, payment model.Payment) error {
+	partnerStatus, err := rs.getPartnerPaymentStatus(ctx, payment)
+	if err != nil {
+		return err
+	}
+
+	if !partnerStatus.Final {
+		// partner is still waiting for payment, nothing is missed
+		return nil
+	}
+
+	return rs.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		// recheck under lock, webhook may be applied while partner status is fetched
+		current, err := repos.Payment.GetByIDForUpdate(ctx, payment.ID)
+		if err != nil {
+			return err
+		}
+
+		if current.Status == partnerStatus.PaymentStatus {
+			return nil
+		}
+
+		update := paymentStatusUpdate{
+			PaymentID:         payment.ID,
+			TransactionStatus: partnerStatus.TransactionStatus,
+			PaymentStatus:     partnerStatus.PaymentStatus,
+		}
+
+		if partnerStatus.PaymentStatus == model.PaymentStatusEnumSuccess {
+			update.CompletedAt = partnerStatus.PaidAt
+		}
+
+		result, note, err := applyPaymentStatus(ctx, repos, update)
+		if err != nil {
+			return err
+		}
+
+		discrepancy := model.ReconciliationDiscrepancy{
+			PaymentID:     payment.ID,
+			TransactionID: payment.TransactionID,
+			InvoiceID:     payment.PartnerID,
+			PaymentStatus: current.Status,
+			InvoiceStatus: partnerStatus.Status,
+			Result:        result,
+		}
+
+		if note != "" {
+			discrepancy.Note = &note
+		}
+
+		_, err = repos.ReconciliationDiscrepancy.Insert(ctx, &discrepancy)
+
+		return err
+	})
+}
+
+// partnerPaymentStatus is status of payment on payment gateway, taken from its charge
+type partnerPaymentStatus struct {
+	Status            string
+	TransactionStatus model.TransactionStatusEnum
+	PaymentStatus     model.PaymentStatusEnum
+	// Final is false when partner is still waiting for payment
+	Final  bool
+	PaidAt *time.Time
+}
+
+// getPartnerPaymentStatus fetch payment status from its charge on the payment gateway that charge it
+func (rs *ReconciliationServiceImpl) getPartnerPaymentStatus(ctx context.Context, payment model.Payment) (*partnerPaymentStatus, error) {
+	gateway, err := rs.PaymentGateways.Get(payment.Gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	charge, err := gateway.GetCharge(ctx, &payment)
+	if err != nil {
+		return nil, err
+	}
+
+	status := partnerPaymentStatus{
+		Status: charge.PartnerStatus,
+		PaidAt: charge.PaidAt,
+	}
+	status.TransactionStatus, status.PaymentStatus, status.Final = mapChargeStatus(charge.Status)
+
+	return &status, nil
+}
+
+// GetDiscrepancies return discrepancies found by reconciliation, the newest first
+func (rs *ReconciliationServiceImpl) GetDiscrepancies(ctx context.Context, pages *helper.Pages) ([]model.ReconciliationDiscrepancy, error) {
+	total, err := rs.ReconciliationDiscrepancyRepo.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pages.SetData(total)
+
+	return rs.ReconciliationDiscrepancyRepo.Get(ctx, pages.PerPage, (pages.Page-1)*pages.PerPage)
+}
+
+// processPaymentTimeout return configured duration before process payment is polled from payment gateway, default to 10 minutes
+func (rs *ReconciliationServiceImpl) processPaymentTimeout() time.Duration {
+	if rs.Config.Reconciliation.ProcessPaymentTimeout > 0 {
+		return time.Duration(rs.Config.Reconciliation.ProcessPaymentTimeout) * time.Minute
+	}
+
+	return defaultProcessPaymentTimeout
+}
+
+// pendingTransactionTimeout return configured duration before pending transaction is reconciled, default to 30 minutes
+func (rs *ReconciliationServiceImpl) pendingTransactionTimeout() time.Duration {
+	if rs.Config.Reconciliation.PendingTransactionTimeout > 0 {
+		return time.Duration(rs.Config.Reconciliation.PendingTransactionTimeout) * time.Minute
+	}
+
+	return defaultPendingTransactionTimeout
+}
@@ -0,0 +1,426 @@
+package service
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+	"e-resep-be/internal/repository"
+	"e-resep-be/internal/requester"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// unacknowledgedRefundTimeout is how long payment gateway is given to acknowledge refund before it is resubmitted
+	unacknowledgedRefundTimeout = 10 * time.Minute
+	// refundResubmissionBatchSize limit the number of refunds resubmitted in a single run
+	refundResubmissionBatchSize = 100
+)
+
+type (
+	// RefundService is an interface that has all the function to be implemented inside refund service
+	RefundService interface {
+		CreateRefund(ctx context.Context, transactionID int, req *model.CreateRefundRequest) (*model.Refund, error)
+		HandleRefundNotification(ctx context.Context, gateway model.PaymentGatewayEnum, callbackID string, rawPayload []byte) error
+		ResubmitUnacknowledgedRefunds(ctx context.Context) error
+	}
+
+	// RefundServiceImpl is an app refund struct that consists of all the dependencies needed for refund service
+	RefundServiceImpl struct {
+		Context          context.Context
+		Config           *config.Configuration
+		PaymentRepo      repository.PaymentRepository
+		RefundRepo       repository.RefundRepository
+		PaymentEventRepo repository.PaymentEventRepository
+		PaymentGateways  requester.PaymentGatewayProvider
+		UnitOfWork       repository.UnitOfWork
+	}
+)
+
+// NewRefundService return new instances refund service
+func NewRefundService(ctx context.Context, config *config.Configuration, paymentRepo repository.PaymentRepository, refundRepo repository.RefundRepository, paymentEventRepo repository.PaymentEventRepository, paymentGateways requester.PaymentGatewayProvider, unitOfWork repository.UnitOfWork) *RefundServiceImpl {
+	return &RefundServiceImpl{
+		Context:          ctx,
+		Config:           config,
+		PaymentRepo:      paymentRepo,
+		RefundRepo:       refundRepo,
+		PaymentEventRepo: paymentEventRepo,
+		PaymentGateways:  paymentGateways,
+		UnitOfWork:       unitOfWork,
+	}
+}
+
+// CreateRefund refund the selected items of paid transaction through the payment gateway that charge it. Shipping cost is
+// refunded together with the last refunded items. Refund is recorded as pending before payment gateway is called, so the
+// selected items are reserved and the refund callback always find it. Transaction status follow the refund once it is
+// succeeded, right away for payment gateway that refund synchronously or once payment gateway notify it otherwise
+func (rs *RefundServiceImpl) CreateRefund(ctx context.Context, transactionID int, req *model.CreateRefundRequest) (*model.Refund, error) {
+	var (
+		r       model.Refund
+		payment *model.Payment
+	)
+
+	err := rs.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		p, err := repos.Payment.GetByTransactionID(ctx, transactionID)
+		if err != nil {
+			if err.Error() == pgx.ErrNoRows.Error() {
+				return model.NewError(model.NotFound, "transaction not found")
+			}
+
+			return err
+		}
+
+		// lock payment, so items of the same transaction can't be refunded twice by concurrent requests
+		payment, err = repos.Payment.GetByIDForUpdate(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+
+		transaction, err := repos.Transaction.GetByID(ctx, transactionID)
+		if err != nil {
+			return err
+		}
+
+		if transaction.Status != model.TransactionStatusEnumSuccess && transaction.Status != model.TransactionStatusEnumPartiallyRefunded {
+			return model.NewError(model.Conflict, fmt.Sprintf("transaction can't be refunded, status is %s", transaction.Status))
+		}
+
+		details, err := repos.Transaction.GetDetailsByTransactionID(ctx, transactionID)
+		if err != nil {
+			return err
+		}
+
+		// item of failed refund can be refunded again
+		refundedIDs, err := repos.Refund.GetDetailIDsByTransactionID(ctx, transactionID, []model.RefundStatusEnum{model.RefundStatusEnumPending, model.RefundStatusEnumSucceeded})
+		if err != nil {
+			return err
+		}
+
+		refundDetails, err := selectRefundDetails(details, refundedIDs, req.TransactionDetailIDs)
+		if err != nil {
+			return err
+		}
+
+		r = model.Refund{
+			TransactionID: transaction.ID,
+			PaymentID:     payment.ID,
+			Reason:        req.Reason,
+			Status:        model.RefundStatusEnumPending,
+			Details:       refundDetails,
+		}
+
+		for _, detail := range refundDetails {
+			r.Amount += detail.Amount
+		}
+
+		if len(refundDetails)+len(refundedIDs) == len(details) {
+			r.Amount += transaction.AdditionalPrice
+		}
+
+		r.ID, err = repos.Refund.Insert(ctx, &r)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rs.submitRefund(ctx, payment, &r)
+}
+
+// ResubmitUnacknowledgedRefunds send pending refunds that payment gateway never acknowledged again, e.g. when the first
+// request timed out. Refund id is the idempotency key, so refund that did reach payment gateway isn't created twice
+func (rs *RefundServiceImpl) ResubmitUnacknowledgedRefunds(ctx context.Context) error {
+	refunds, err := rs.RefundRepo.GetUnacknowledgedUpdatedBefore(ctx, time.Now().Add(-unacknowledgedRefundTimeout), refundResubmissionBatchSize)
+	if err != nil {
+		return err
+	}
+
+	// keep resubmitting the rest when one refund fail, it will be retried on the next run
+	var errs []error
+	for i := range refunds {
+		payment, err := rs.PaymentRepo.GetByID(ctx, refunds[i].PaymentID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("refund %d: %w", refunds[i].ID, err))
+
+			continue
+		}
+
+		if _, err := rs.submitRefund(ctx, payment, &refunds[i]); err != nil {
+			errs = append(errs, fmt.Errorf("refund %d: %w", refunds[i].ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// submitRefund send pending refund to payment gateway and record its response. Refund is only failed when payment gateway
+// definitely reject it, refund of unknown outcome e.g. timeout or 5xx stay pending until its callback arrive or it is resubmitted
+func (rs *RefundServiceImpl) submitRefund(ctx context.Context, payment *model.Payment, r *model.Refund) (*model.Refund, error) {
+	gateway, err := rs.PaymentGateways.Get(payment.Gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	// refund id is the idempotency key, so payment gateway create the refund once even when the request is retried
+	refundResp, gatewayErr := gateway.CreateRefund(ctx, payment, &model.RefundChargeRequest{
+		ReferenceID: strconv.Itoa(r.ID),
+		Amount:      r.Amount,
+		Reason:      r.Reason,
+		Metadata: map[string]interface{}{
+			"transaction_id": r.TransactionID,
+			"note":           r.Reason,
+		},
+	})
+
+	err = rs.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		current, err := repos.Refund.GetByIDForUpdate(ctx, r.ID)
+		if err != nil {
+			return err
+		}
+
+		// refund callback may arrive before the response of payment gateway, it already decide the refund status
+		if current.Status != model.RefundStatusEnumPending {
+			r.Status = current.Status
+			r.PartnerID = current.PartnerID
+			r.FailureCode = current.FailureCode
+			gatewayErr = nil
+
+			return nil
+		}
+
+		if gatewayErr != nil {
+			// payment gateway may have created the refund, it is settled by its callback or by resubmission
+			if !model.IsRejected(gatewayErr) {
+				return nil
+			}
+
+			failureCode := model.RefundFailureCodeRejected
+			r.Status = model.RefundStatusEnumFailed
+			r.FailureCode = &failureCode
+
+			return applyRefundStatus(ctx, repos, r, r.Status, failureCode)
+		}
+
+		if refundResp.PartnerID != "" {
+			err = repos.Refund.UpdatePartnerIDByID(ctx, refundResp.PartnerID, r.ID)
+			if err != nil {
+				return err
+			}
+
+			r.PartnerID = &refundResp.PartnerID
+		}
+
+		if refundResp.Status != model.RefundStatusEnumPending {
+			err = applyRefundStatus(ctx, repos, r, refundResp.Status, refundResp.FailureCode)
+			if err != nil {
+				return err
+			}
+
+			r.Status = refundResp.Status
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if gatewayErr != nil {
+		if !model.IsRejected(gatewayErr) {
+			return nil, model.NewError(model.Partner, fmt.Sprintf("refund %d is pending, payment gateway didn't confirm it: %v", r.ID, gatewayErr))
+		}
+
+		return nil, model.NewError(model.Partner, fmt.Sprintf("failed to create refund: %v", gatewayErr))
+	}
+
+	return r, nil
+}
+
+// selectRefundDetails return refund detail of requested transaction details, every item that is not refunded yet when none is requested
+func selectRefundDetails(details []model.TransactionDetail, refundedIDs []int, requestedIDs []int) ([]model.RefundDetail, error) {
+	refunded := make(map[int]bool, len(refundedIDs))
+	for _, id := range refundedIDs {
+		refunded[id] = true
+	}
+
+	byID := make(map[int]model.TransactionDetail, len(details))
+	for _, detail := range details {
+		byID[detail.ID] = detail
+	}
+
+	if len(requestedIDs) == 0 {
+		for _, detail := range details {
+			if !refunded[detail.ID] {
+				requestedIDs = append(requestedIDs, detail.ID)
+			}
+		}
+
+		if len(requestedIDs) == 0 {
+			return nil, model.NewError(model.Conflict, "every item of transaction is already refunded")
+		}
+	}
+
+	refundDetails := make([]model.RefundDetail, 0, len(requestedIDs))
+	selected := make(map[int]bool, len(requestedIDs))
+	for _, id := range requestedIDs {
+		detail, ok := byID[id]
+		if !ok {
+			return nil, model.NewError(model.Validation, fmt.Sprintf("transaction detail %d doesn't belong to transaction", id))
+		}
+
+		if selected[id] {
+			return nil, model.NewError(model.Validation, fmt.Sprintf("transaction detail %d is requested more than once", id))
+		}
+
+		if refunded[id] {
+			return nil, model.NewError(model.Conflict, fmt.Sprintf("transaction detail %d is already refunded", id))
+		}
+
+		selected[id] = true
+		refundDetails = append(refundDetails, model.RefundDetail{
+			TransactionDetailID: detail.ID,
+			Amount:              detail.Price,
+		})
+	}
+
+	return refundDetails, nil
+}
+
+// HandleRefundNotification record every payment gateway refund callback and apply its status once, the transaction and payment
+// become refunded when every item is refunded and partially refunded otherwise
+func (rs *RefundServiceImpl) HandleRefundNotification(ctx context.Context, gatewayName model.PaymentGatewayEnum, callbackID string, rawPayload []byte) error {
+	gateway, err := rs.PaymentGateways.Get(gatewayName)
+	if err != nil {
+		return err
+	}
+
+	notification, err := gateway.ParseRefundNotification(ctx, callbackID, rawPayload)
+	if err != nil {
+		return err
+	}
+
+	event, err := rs.PaymentEventRepo.InsertOrGet(ctx, &model.PaymentEvent{
+		CallbackID: notification.CallbackID,
+		InvoiceID:  notification.Refund.ChargePartnerID,
+		ExternalID: notification.Refund.ReferenceID,
+		Status:     notification.Refund.PartnerStatus,
+		RawPayload: rawPayload,
+	})
+	if err != nil {
+		return err
+	}
+
+	if event.ProcessedAt != nil {
+		return nil
+	}
+
+	return rs.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		result, note, err := rs.applyRefundNotification(ctx, repos, notification.Refund)
+		if err != nil {
+			return err
+		}
+
+		return repos.PaymentEvent.UpdateResultByID(ctx, result, note, event.ID)
+	})
+}
+
+// applyRefundNotification update status refund, transaction and payment based on status from payment gateway refund callback
+func (rs *RefundServiceImpl) applyRefundNotification(ctx context.Context, repos *repository.Repositories, chargeRefund model.ChargeRefund) (model.PaymentEventResultEnum, string, error) {
+	if chargeRefund.Status != model.RefundStatusEnumSucceeded && chargeRefund.Status != model.RefundStatusEnumFailed {
+		return model.PaymentEventResultEnumIgnored, fmt.Sprintf("status %s doesn't change refund", chargeRefund.PartnerStatus), nil
+	}
+
+	// reference id is the refund id
+	refundID, err := strconv.Atoi(chargeRefund.ReferenceID)
+	if err != nil {
+		return "", "", model.NewError(model.Validation, "invalid refund reference id")
+	}
+
+	r, err := repos.Refund.GetByIDForUpdate(ctx, refundID)
+	if err != nil {
+		// refund may not be committed yet when callback arrive too early, let payment gateway retry the callback
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return "", "", model.NewError(model.NotFound, "refund not found")
+		}
+
+		return "", "", err
+	}
+
+	if r.Status != model.RefundStatusEnumPending {
+		return model.PaymentEventResultEnumIgnored, fmt.Sprintf("refund is already %s", r.Status), nil
+	}
+
+	err = applyRefundStatus(ctx, repos, r, chargeRefund.Status, chargeRefund.FailureCode)
+	if err != nil {
+		return "", "", err
+	}
+
+	return model.PaymentEventResultEnumApplied, "", nil
+}
+
+// applyRefundStatus update status of pending refund, transaction and payment follow the refund when it is succeeded
+func applyRefundStatus(ctx context.Context, repos *repository.Repositories, r *model.Refund, status model.RefundStatusEnum, failureCode string) error {
+	err := repos.Refund.UpdateStatusByID(ctx, status, failureCode, r.ID)
+	if err != nil {
+		return err
+	}
+
+	if status == model.RefundStatusEnumFailed {
+		return nil
+	}
+
+	return applyRefundedStatus(ctx, repos, r)
+}
+
+// applyRefundedStatus move transaction and payment of succeeded refund into refunded or partially refunded status
+func applyRefundedStatus(ctx context.Context, repos *repository.Repositories, r *model.Refund) error {
+	payment, err := repos.Payment.GetByIDForUpdate(ctx, r.PaymentID)
+	if err != nil {
+		return err
+	}
+
+	transaction, err := repos.Transaction.GetByID(ctx, r.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	details, err := repos.Transaction.GetDetailsByTransactionID(ctx, r.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	refundedIDs, err := repos.Refund.GetDetailIDsByTransactionID(ctx, r.TransactionID, []model.RefundStatusEnum{model.RefundStatusEnumSucceeded})
+	if err != nil {
+		return err
+	}
+
+	transactionStatus, paymentStatus := model.TransactionStatusEnumPartiallyRefunded, model.PaymentStatusEnumPartiallyRefunded
+	if len(refundedIDs) >= len(details) {
+		transactionStatus, paymentStatus = model.TransactionStatusEnumRefunded, model.PaymentStatusEnumRefunded
+	}
+
+	if transaction.Status.CanTransitionTo(transactionStatus) {
+		err = repos.Transaction.UpdateByID(ctx, model.Transaction{
+			Status: transactionStatus,
+		}, transaction.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if payment.Status.CanTransitionTo(paymentStatus) {
+		err = repos.Payment.UpdateByID(ctx, model.Payment{
+			Status: paymentStatus,
+		}, payment.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
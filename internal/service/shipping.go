@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/helper"
+	"e-resep-be/internal/model"
+	"e-resep-be/internal/repository"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+type (
+	// ShippingCalculator is an interface to calculate shipping cost from dispensing pharmacy to patient address
+	ShippingCalculator interface {
+		Calculate(ctx context.Context, req *model.ShippingCostRequest) (*model.ShippingCost, error)
+	}
+
+	// DistanceBandShippingCalculatorImpl calculate shipping cost using tariff table by sub district or distance band
+	DistanceBandShippingCalculatorImpl struct {
+		Context            context.Context
+		Config             *config.Configuration
+		ShippingTariffRepo repository.ShippingTariffRepository
+	}
+)
+
+// NewDistanceBandShippingCalculator return new instances distance band shipping calculator
+func NewDistanceBandShippingCalculator(ctx context.Context, config *config.Configuration, shippingTariffRepo repository.ShippingTariffRepository) *DistanceBandShippingCalculatorImpl {
+	return &DistanceBandShippingCalculatorImpl{
+		Context:            ctx,
+		Config:             config,
+		ShippingTariffRepo: shippingTariffRepo,
+	}
+}
+
+func (sc *DistanceBandShippingCalculatorImpl) Calculate(ctx context.Context, req *model.ShippingCostRequest) (*model.ShippingCost, error) {
+	distance := helper.HaversineDistance(req.Origin.Latitude, req.Origin.Longitude, req.Destination.Latitude, req.Destination.Longitude)
+
+	tariff, err := sc.ShippingTariffRepo.GetBySubDistrictOrDistance(ctx, req.Destination.SubDistrict, distance)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, model.NewError(model.Validation, fmt.Sprintf("address is out of delivery area (%.2f km)", distance))
+		}
+
+		return nil, err
+	}
+
+	return &model.ShippingCost{
+		DistanceKm: distance,
+		Price:      tariff.Price,
+	}, nil
+}
+
+// pharmacyLocation return dispensing pharmacy location from configuration
+func pharmacyLocation(config *config.Configuration) model.Location {
+	return model.Location{
+		Latitude:    config.Pharmacy.PharmacyLatitude,
+		Longitude:   config.Pharmacy.PharmacyLongitude,
+		SubDistrict: config.Pharmacy.PharmacySubDistrict,
+	}
+}
+
+// addressLocation return location of patient address
+func addressLocation(address model.PatientAddress) model.Location {
+	return model.Location{
+		Latitude:    address.Latitude,
+		Longitude:   address.Longitude,
+		SubDistrict: address.SubDistrict,
+	}
+}
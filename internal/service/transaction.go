@@ -3,12 +3,24 @@ package service
 import (
 	"context"
 	"e-resep-be/internal/config"
+	"e-resep-be/internal/helper"
 	"e-resep-be/internal/model"
 	"e-resep-be/internal/repository"
 	"e-resep-be/internal/requester"
+	"errors"
 	"fmt"
+	"time"
 
-	"github.com/xendit/xendit-go/v6/invoice"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// defaultPaymentDuration is used when XENDIT_INVOICE_DURATION is not configured
+	defaultPaymentDuration = 24 * time.Hour
+
+	// payment result sent to frontend through redirect url
+	paymentRedirectStatusSuccess = "success"
+	paymentRedirectStatusFailed  = "failed"
 )
 
 type (
@@ -16,116 +28,430 @@ type (
 	TransactionService interface {
 		CreateTransaction(ctx context.Context, req *model.CreateTransactionRequest) (*model.CreateTransactionResponse, error)
 		CheckStatusByPartnerID(ctx context.Context, partnerID string) (*model.CheckStatusTransactionResponse, error)
+		CancelTransaction(ctx context.Context, id int) (*model.Transaction, error)
+		GetHistory(ctx context.Context, req *model.GetTransactionHistoryRequest, pages *helper.Pages) ([]model.TransactionHistory, error)
 	}
 
 	// TransactionServiceImpl is an app transaction struct that consists of all the dependencies needed for transaction service
 	TransactionServiceImpl struct {
-		Context         context.Context
-		Config          *config.Configuration
-		PatientRepo     repository.PatientRepository
-		TransactionRepo repository.TransactionRepository
-		PaymentRepo     repository.PaymentRepository
-		XenditRequester requester.XenditRequester
+		Context             context.Context
+		Config              *config.Configuration
+		PatientRepo         repository.PatientRepository
+		PatientAddressRepo  repository.PatientAddressRepository
+		MedicationRepo      repository.MedicationRepository
+		TransactionRepo     repository.TransactionRepository
+		PaymentRepo         repository.PaymentRepository
+		PriceQuoteRepo      repository.PriceQuoteRepository
+		PaymentGateways     requester.PaymentGatewayProvider
+		KimiaFarmaRequester requester.KimiaFarmaRequester
+		ShippingCalculator  ShippingCalculator
+		UnitOfWork          repository.UnitOfWork
 	}
 )
 
 // NewTransactionService return new instances transaction service
-func NewTransactionService(ctx context.Context, config *config.Configuration, patientRepo repository.PatientRepository, transactionRepo repository.TransactionRepository, paymentRepo repository.PaymentRepository, xenditRequester requester.XenditRequester) *TransactionServiceImpl {
+func NewTransactionService(ctx context.Context, config *config.Configuration, patientRepo repository.PatientRepository, patientAddressRepo repository.PatientAddressRepository, medicationRepo repository.MedicationRepository, transactionRepo repository.TransactionRepository, paymentRepo repository.PaymentRepository, priceQuoteRepo repository.PriceQuoteRepository, paymentGateways requester.PaymentGatewayProvider, kimiaFarmaRequester requester.KimiaFarmaRequester, shippingCalculator ShippingCalculator, unitOfWork repository.UnitOfWork) *TransactionServiceImpl {
 	return &TransactionServiceImpl{
-		Context:         ctx,
-		Config:          config,
-		PatientRepo:     patientRepo,
-		TransactionRepo: transactionRepo,
-		PaymentRepo:     paymentRepo,
-		XenditRequester: xenditRequester,
+		Context:             ctx,
+		Config:              config,
+		PatientRepo:         patientRepo,
+		PatientAddressRepo:  patientAddressRepo,
+		MedicationRepo:      medicationRepo,
+		TransactionRepo:     transactionRepo,
+		PaymentRepo:         paymentRepo,
+		PriceQuoteRepo:      priceQuoteRepo,
+		PaymentGateways:     paymentGateways,
+		KimiaFarmaRequester: kimiaFarmaRequester,
+		ShippingCalculator:  shippingCalculator,
+		UnitOfWork:          unitOfWork,
 	}
 }
 
 func (ts *TransactionServiceImpl) CreateTransaction(ctx context.Context, req *model.CreateTransactionRequest) (*model.CreateTransactionResponse, error) {
-	// validate total price is same with sum price inside each item
+	// re-derive items price and shipping cost on server side, so patient can't submit their own price
+	quote, err := ts.getPriceQuote(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ts.verifyItems(ctx, req, quote)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ts.verifyShippingCost(ctx, req, quote)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate total price is same with sum price inside each item and shipping cost
 	totalPriceInItems := 0
 	for _, item := range req.Items {
 		totalPriceInItems += item.Price
 	}
 
-	if totalPriceInItems != req.TotalPrice {
+	if totalPriceInItems+req.AdditionalPrice != req.TotalPrice {
 		return nil, model.NewError(model.Validation, "invalid total price")
 	}
 
+	// QRIS has a single channel, while invoice let patient choose the channel on payment gateway page
+	switch req.PaymentMethod {
+	case "", model.PaymentMethodEnumInvoice:
+		req.PaymentMethod, req.ChannelCode = model.PaymentMethodEnumInvoice, ""
+	case model.PaymentMethodEnumQRIS:
+		req.ChannelCode = string(model.PaymentMethodEnumQRIS)
+	}
+
 	// get patient by id
 	patient, err := ts.PatientRepo.GetByID(ctx, req.PatientID)
 	if err != nil {
 		return nil, err
 	}
 
-	// insert trx & trx details
-	transactionID, err := ts.TransactionRepo.Insert(ctx, req)
+	// new payment is charged by the configured payment gateway, the gateway is recorded so payment keep using it
+	gatewayName, gateway := ts.PaymentGateways.Active()
+
+	var (
+		transactionID         int
+		paymentID             int
+		getTransactionDetails []model.TransactionDetail
+	)
+
+	// insert trx, trx details & payment atomically, so there is no transaction left without payment
+	err = ts.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		// every item redeem one dispense of its medication request
+		err := assignMedicationRequests(ctx, repos, req)
+		if err != nil {
+			return err
+		}
+
+		// insert trx & trx details
+		transactionID, err = repos.Transaction.Insert(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		// quote is consumed in the same transaction, concurrent checkout with the same quote wait here and is rejected
+		if quote != nil {
+			err = repos.PriceQuote.ConsumeByID(ctx, quote.ID, transactionID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// get details transaction by trx id
+		getTransactionDetails, err = repos.Transaction.GetDetailsByTransactionID(ctx, transactionID)
+		if err != nil {
+			return err
+		}
+
+		// insert payment
+		paymentID, err = repos.Payment.Insert(ctx, &model.CreatePaymentRequest{
+			TransactionID: transactionID,
+			FinalPrice:    req.TotalPrice,
+			Method:        req.PaymentMethod,
+			ChannelCode:   req.ChannelCode,
+			Gateway:       gatewayName,
+		})
+
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// get details transaction by trx id
-	getTransactionDetails, err := ts.TransactionRepo.GetDetailsByTransactionID(ctx, transactionID)
+	// charge patient with the chosen payment method, invoice let patient choose it on payment gateway page
+	charge, err := gateway.CreateCharge(ctx, ts.chargeRequest(req, patient, getTransactionDetails, paymentID))
 	if err != nil {
+		// compensate the pending rows, so they are not left behind without charge.
+		// When compensation also fail, the rows stay pending and are resolved by reconciliation job
+		errCompensate := ts.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+			return failPendingTransaction(ctx, repos, transactionID, paymentID)
+		})
+		if errCompensate != nil {
+			return nil, errors.Join(err, errCompensate)
+		}
+
 		return nil, err
 	}
 
-	// insert payment
-	paymentID, err := ts.PaymentRepo.Insert(ctx, &model.CreatePaymentRequest{
-		TransactionID: transactionID,
-		FinalPrice:    req.TotalPrice,
+	// update transaction & payment status to process together, so both never disagree
+	err = ts.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		// update transaction status to process by id
+		err := repos.Transaction.UpdateByID(ctx, model.Transaction{
+			Status: model.TransactionStatusEnumProcess,
+		}, transactionID)
+		if err != nil {
+			return err
+		}
+
+		// update payment status to process and fill partner id by id
+		return repos.Payment.UpdateByID(ctx, model.Payment{
+			Status:    model.PaymentStatusEnumProcess,
+			PartnerID: charge.PartnerID,
+			ExpiresAt: charge.ExpiresAt,
+		}, paymentID)
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	// initiate invoice object request
-	invoiceReq := invoice.NewCreateInvoiceRequest(fmt.Sprintf("%d", paymentID), float64(req.TotalPrice))
+	resp := model.CreateTransactionResponse{
+		ID:                   charge.PartnerID,
+		PaymentMethod:        req.PaymentMethod,
+		ChannelCode:          req.ChannelCode,
+		VirtualAccountNumber: charge.VirtualAccountNumber,
+		QRString:             charge.QRString,
+		DeeplinkURL:          charge.DeeplinkURL,
+		ExpiresAt:            charge.ExpiresAt,
+	}
+
+	// invoice checkout page is kept as invoice url, so existing client keep working
+	if req.PaymentMethod.IsDirectCharge() {
+		resp.CheckoutURL = charge.CheckoutURL
+	} else {
+		resp.InvoiceURL = charge.CheckoutURL
+	}
+
+	return &resp, nil
+}
+
+// chargeRequest build charge request of the payment, items and shipping cost are listed so patient see what they pay for
+func (ts *TransactionServiceImpl) chargeRequest(req *model.CreateTransactionRequest, patient *model.Patient, details []model.TransactionDetail, paymentID int) *model.ChargeRequest {
+	chargeReq := model.ChargeRequest{
+		ReferenceID:  fmt.Sprintf("%d", paymentID),
+		Amount:       req.TotalPrice,
+		Description:  fmt.Sprintf("Transaction E-RESEP with id %d", paymentID),
+		Method:       req.PaymentMethod,
+		ChannelCode:  req.ChannelCode,
+		MobileNumber: req.MobileNumber,
+		Customer: model.ChargeCustomer{
+			ID:   fmt.Sprintf("%d", patient.ID),
+			Name: patient.Name,
+		},
+		ShippingCost:       req.AdditionalPrice,
+		Duration:           ts.paymentDuration(),
+		SuccessRedirectURL: paymentRedirectURL(ts.Config, patient, paymentRedirectStatusSuccess),
+		FailureRedirectURL: paymentRedirectURL(ts.Config, patient, paymentRedirectStatusFailed),
+	}
+
+	if patient.PhoneNumber != "" {
+		chargeReq.Customer.PhoneNumber = helper.FormatPhoneNumberE164(patient.PhoneNumber)
+	}
 
-	// set customer data inside invoices
-	customerData := invoice.NewCustomerObject()
-	customerData.SetCustomerId(fmt.Sprintf("%d", patient.ID))
-	customerData.SetGivenNames(patient.Name)
-	invoiceReq.SetCustomer(*customerData)
+	if patient.Email != nil {
+		chargeReq.Customer.Email = *patient.Email
+	}
 
-	// set description inside invoices
-	invoiceReq.SetDescription(fmt.Sprintf("Create Invoice Transaction E-RESEP with id %d", paymentID))
+	for _, trxDetail := range details {
+		chargeReq.Items = append(chargeReq.Items, model.ChargeItem{
+			ReferenceID: fmt.Sprintf("%d", trxDetail.ID),
+			Name:        trxDetail.MedicationName,
+			Price:       trxDetail.Price,
+			Quantity:    1,
+		})
+	}
 
-	// set transaction items inside invoices
-	for _, trxDetail := range getTransactionDetails {
-		item := invoice.NewInvoiceItem(trxDetail.MedicationName, float32(trxDetail.Price), 1)
-		item.SetReferenceId(fmt.Sprintf("%d", trxDetail.ID))
+	return &chargeReq
+}
 
-		invoiceReq.Items = append(invoiceReq.Items, *item)
+// paymentDuration return configured payment deadline, default to 24 hours
+func (ts *TransactionServiceImpl) paymentDuration() time.Duration {
+	if ts.Config.Xendit.XenditInvoiceDuration > 0 {
+		return time.Duration(ts.Config.Xendit.XenditInvoiceDuration) * time.Minute
 	}
 
-	// call xendit to create invoices
-	results, err := ts.XenditRequester.CreateInvoice(ctx, *invoiceReq)
+	return defaultPaymentDuration
+}
+
+// paymentRedirectURL return prescription page of patient on frontend, patient is sent back there after paying
+func paymentRedirectURL(config *config.Configuration, patient *model.Patient, status string) string {
+	return fmt.Sprintf("%s/resep/%s?payment=%s", config.Const.ClientURL, patient.RefID, status)
+}
+
+// getPriceQuote return locked price quote of the request, nil when request doesn't use quote
+func (ts *TransactionServiceImpl) getPriceQuote(ctx context.Context, req *model.CreateTransactionRequest) (*model.PriceQuote, error) {
+	if req.QuoteID == 0 {
+		return nil, nil
+	}
+
+	quote, err := ts.PriceQuoteRepo.GetByID(ctx, req.QuoteID)
 	if err != nil {
 		return nil, err
 	}
 
-	// update transaction status to process by id
-	err = ts.TransactionRepo.UpdateByID(ctx, model.Transaction{
-		Status: model.TransactionStatusEnumProcess,
-	}, transactionID)
+	if quote.PatientID != req.PatientID {
+		return nil, model.NewError(model.Validation, "price quote doesn't belong to patient")
+	}
+
+	if quote.IsExpired() {
+		return nil, model.NewError(model.Validation, "price quote is expired, please regenerate payment info")
+	}
+
+	if quote.IsConsumed() {
+		return nil, model.NewError(model.Conflict, "price quote is already used, please regenerate payment info")
+	}
+
+	return quote, nil
+}
+
+// verifyItems check every item belongs to medication request of the patient and its price match
+// the locked price quote, or the current pharmacy price when no quote is given
+func (ts *TransactionServiceImpl) verifyItems(ctx context.Context, req *model.CreateTransactionRequest, quote *model.PriceQuote) error {
+	ids := make([]int, 0, len(req.Items))
+	seen := make(map[int]bool, len(req.Items))
+	for _, item := range req.Items {
+		if seen[item.ID] {
+			return model.NewError(model.Validation, fmt.Sprintf("item %d is duplicated", item.ID))
+		}
+
+		seen[item.ID] = true
+		ids = append(ids, item.ID)
+	}
+
+	medications, err := ts.MedicationRepo.GetByIDsAndPatientID(ctx, ids, req.PatientID)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	medicationByID := make(map[int]model.MedicationDB, len(medications))
+	for _, medication := range medications {
+		medicationByID[medication.ID] = medication
+	}
+
+	for _, id := range ids {
+		if _, ok := medicationByID[id]; !ok {
+			return model.NewError(model.Validation, fmt.Sprintf("item %d is not prescribed for patient", id))
+		}
+	}
+
+	prices, err := ts.derivePrices(ctx, quote, medications)
+	if err != nil {
+		return err
+	}
+
+	for i := range req.Items {
+		price, ok := prices[req.Items[i].ID]
+		if !ok {
+			return model.NewError(model.Validation, fmt.Sprintf("item %d is not part of price quote", req.Items[i].ID))
+		}
+
+		if req.Items[i].Price != price {
+			return model.NewError(model.Validation, fmt.Sprintf("price of item %d doesn't match, expected %d", req.Items[i].ID, price))
+		}
+
+		req.Items[i].Name = medicationByID[req.Items[i].ID].Display
+	}
+
+	return nil
+}
+
+// assignMedicationRequests set medication request redeemed by each item, must be called inside unit of work.
+// Medication requests stay locked until the transaction is inserted, so concurrent checkout can't pay the same repeat twice
+func assignMedicationRequests(ctx context.Context, repos *repository.Repositories, req *model.CreateTransactionRequest) error {
+	medicationIDs := make([]int, 0, len(req.Items))
+	for _, item := range req.Items {
+		medicationIDs = append(medicationIDs, item.ID)
+	}
+
+	medicationRequests, err := repos.MedicationRequest.GetByMedicationIDsAndPatientIDForUpdate(ctx, medicationIDs, req.PatientID)
+	if err != nil {
+		return err
+	}
+
+	dispenseCounts, err := repos.MedicationRequest.GetDispenseCountsByIDs(ctx, medicationRequestIDs(medicationRequests))
+	if err != nil {
+		return err
+	}
+
+	selected, err := selectDispensableMedicationRequests(medicationRequests, dispenseCounts, medicationIDs, time.Now().In(helper.TimezoneJakarta))
+	if err != nil {
+		return err
+	}
+
+	for i := range req.Items {
+		medicationRequestID := selected[req.Items[i].ID]
+		req.Items[i].MedicationRequestID = &medicationRequestID
+	}
+
+	return nil
+}
+
+// derivePrices return price per medication id taken from locked price quote, or from pharmacy when there is no quote.
+// every medication must still be available at pharmacy, stock may run out after the quote is made
+func (ts *TransactionServiceImpl) derivePrices(ctx context.Context, quote *model.PriceQuote, medications []model.MedicationDB) (map[int]int, error) {
+	codes := make([]string, 0, len(medications))
+	for _, medication := range medications {
+		codes = append(codes, medication.Code)
 	}
 
-	// update payment status to process and fill partner id by id
-	err = ts.PaymentRepo.UpdateByID(ctx, model.Payment{
-		Status:    model.PaymentStatusEnumProcess,
-		PartnerID: *results.Id,
-	}, paymentID)
+	medicationDetails, err := ts.KimiaFarmaRequester.CheckAvailabilityBatch(ctx, codes)
 	if err != nil {
 		return nil, err
 	}
 
-	return &model.CreateTransactionResponse{
-		ID:         *results.Id,
-		InvoiceURL: results.InvoiceUrl,
-	}, nil
+	if err := medicationDetails.Err(); err != nil {
+		return nil, err
+	}
+
+	prices := make(map[int]int, len(medications))
+	for _, medication := range medications {
+		medicationDetail := medicationDetails.Items[medication.Code]
+		if !medicationDetail.IsAvailable {
+			return nil, model.NewError(model.Validation, fmt.Sprintf("item %d is not available", medication.ID))
+		}
+
+		if quote == nil {
+			prices[medication.ID] = medicationDetail.Price
+		}
+	}
+
+	if quote != nil {
+		for _, item := range quote.Items {
+			prices[item.ID] = item.Price
+		}
+	}
+
+	return prices, nil
+}
+
+// verifyShippingCost check shipping cost inside additional price match the locked price quote,
+// or the calculated shipping cost to patient address when no quote is given
+func (ts *TransactionServiceImpl) verifyShippingCost(ctx context.Context, req *model.CreateTransactionRequest, quote *model.PriceQuote) error {
+	var shippingCost int
+
+	if quote != nil {
+		if quote.PatientAddressID == nil || *quote.PatientAddressID != req.PatientAddressID {
+			return model.NewError(model.Validation, "patient address doesn't match price quote, please regenerate payment info")
+		}
+
+		shippingCost = quote.ShippingCost
+	} else {
+		address, err := ts.PatientAddressRepo.GetByID(ctx, req.PatientAddressID)
+		if err != nil {
+			return err
+		}
+
+		if address.PatientID != req.PatientID {
+			return model.NewError(model.Validation, "patient address doesn't belong to patient")
+		}
+
+		cost, err := ts.ShippingCalculator.Calculate(ctx, &model.ShippingCostRequest{
+			Origin:      pharmacyLocation(ts.Config),
+			Destination: addressLocation(*address),
+		})
+		if err != nil {
+			return err
+		}
+
+		shippingCost = cost.Price
+	}
+
+	if req.AdditionalPrice != shippingCost {
+		return model.NewError(model.Validation, fmt.Sprintf("shipping cost doesn't match, expected %d", shippingCost))
+	}
+
+	return nil
 }
 
 func (ts *TransactionServiceImpl) CheckStatusByPartnerID(ctx context.Context, partnerID string) (*model.CheckStatusTransactionResponse, error) {
@@ -149,7 +475,197 @@ func (ts *TransactionServiceImpl) CheckStatusByPartnerID(ctx context.Context, pa
 	}
 
 	resp.Transaction = transaction
+	resp.Payment = payment
 	*resp.Items = append(*resp.Items, details...)
 
 	return &resp, nil
 }
+
+// GetHistory return transactions of patient from the newest one, each with its items and payment status
+func (ts *TransactionServiceImpl) GetHistory(ctx context.Context, req *model.GetTransactionHistoryRequest, pages *helper.Pages) ([]model.TransactionHistory, error) {
+	patient, err := ts.PatientRepo.GetByRefID(ctx, req.PatientRefID)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, model.NewError(model.NotFound, "patient not found")
+		}
+
+		return nil, err
+	}
+
+	filter := model.TransactionHistoryFilter{
+		PatientID: patient.ID,
+		Statuses:  req.Statuses,
+	}
+
+	// dates are in western indonesia time, end date is included until the end of the day
+	if req.StartDate != "" {
+		createdFrom, err := time.ParseInLocation(model.TransactionHistoryDateLayout, req.StartDate, helper.TimezoneJakarta)
+		if err != nil {
+			return nil, model.NewError(model.Validation, "invalid start date")
+		}
+
+		filter.CreatedFrom = &createdFrom
+	}
+
+	if req.EndDate != "" {
+		endDate, err := time.ParseInLocation(model.TransactionHistoryDateLayout, req.EndDate, helper.TimezoneJakarta)
+		if err != nil {
+			return nil, model.NewError(model.Validation, "invalid end date")
+		}
+
+		createdUntil := endDate.AddDate(0, 0, 1)
+		filter.CreatedUntil = &createdUntil
+	}
+
+	total, err := ts.TransactionRepo.CountHistory(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	pages.SetData(total)
+
+	transactions, err := ts.TransactionRepo.GetHistory(ctx, filter, pages.PerPage, (pages.Page-1)*pages.PerPage)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(transactions) == 0 {
+		return transactions, nil
+	}
+
+	ids := make([]int, 0, len(transactions))
+	for _, transaction := range transactions {
+		ids = append(ids, transaction.ID)
+	}
+
+	// get items of every transaction in the page at once
+	details, err := ts.TransactionRepo.GetDetailsByTransactionIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	detailsByTransactionID := make(map[int][]model.TransactionDetail, len(transactions))
+	for _, detail := range details {
+		detailsByTransactionID[detail.TransactionID] = append(detailsByTransactionID[detail.TransactionID], detail)
+	}
+
+	for i := range transactions {
+		transactions[i].Items = detailsByTransactionID[transactions[i].ID]
+		if transactions[i].Items == nil {
+			transactions[i].Items = []model.TransactionDetail{}
+		}
+	}
+
+	return transactions, nil
+}
+
+// CancelTransaction cancel transaction that is still waiting for payment and expire its charge on payment gateway.
+// Payment row is locked while the charge is expired, so paid webhook of the same charge is applied either
+// before cancellation (and cancellation is rejected) or after it (and the webhook is ignored)
+func (ts *TransactionServiceImpl) CancelTransaction(ctx context.Context, id int) (*model.Transaction, error) {
+	var transaction *model.Transaction
+
+	err := ts.UnitOfWork.WithTx(ctx, func(repos *repository.Repositories) error {
+		payment, err := repos.Payment.GetByTransactionID(ctx, id)
+		if err != nil {
+			if err.Error() == pgx.ErrNoRows.Error() {
+				return model.NewError(model.NotFound, "transaction not found")
+			}
+
+			return err
+		}
+
+		// lock payment until transaction and payment are cancelled
+		payment, err = repos.Payment.GetByIDForUpdate(ctx, payment.ID)
+		if err != nil {
+			return err
+		}
+
+		transaction, err = repos.Transaction.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if transaction.Status != model.TransactionStatusEnumProcess || payment.Status != model.PaymentStatusEnumProcess {
+			return model.NewError(model.Conflict, fmt.Sprintf("transaction can't be cancelled, status is %s", transaction.Status))
+		}
+
+		gateway, err := ts.PaymentGateways.Get(payment.Gateway)
+		if err != nil {
+			return err
+		}
+
+		// payment gateway refuse to expire charge that is already paid
+		charge, err := gateway.CancelCharge(ctx, payment)
+		if err != nil {
+			var errModel *model.Error
+			if errors.As(err, &errModel) {
+				return err
+			}
+
+			return model.NewError(model.Partner, fmt.Sprintf("failed to expire charge: %v", err))
+		}
+
+		if charge.Status != model.ChargeStatusEnumExpired {
+			return model.NewError(model.Conflict, fmt.Sprintf("transaction can't be cancelled, charge is %s", charge.PartnerStatus))
+		}
+
+		err = repos.Transaction.UpdateByID(ctx, model.Transaction{
+			Status: model.TransactionStatusEnumCancelled,
+		}, transaction.ID)
+		if err != nil {
+			return err
+		}
+
+		err = repos.Payment.UpdateByID(ctx, model.Payment{
+			Status: model.PaymentStatusEnumCancelled,
+		}, payment.ID)
+		if err != nil {
+			return err
+		}
+
+		transaction.Status = model.TransactionStatusEnumCancelled
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// failPendingTransaction mark transaction and its payment as failed, rows that already left pending status are kept as is.
+// Zero payment id means transaction doesn't have payment
+func failPendingTransaction(ctx context.Context, repos *repository.Repositories, transactionID, paymentID int) error {
+	if paymentID != 0 {
+		payment, err := repos.Payment.GetByIDForUpdate(ctx, paymentID)
+		if err != nil {
+			return err
+		}
+
+		if payment.Status != model.PaymentStatusEnumPending {
+			return nil
+		}
+
+		err = repos.Payment.UpdateByID(ctx, model.Payment{
+			Status: model.PaymentStatusEnumFailed,
+		}, paymentID)
+		if err != nil {
+			return err
+		}
+	}
+
+	transaction, err := repos.Transaction.GetByID(ctx, transactionID)
+	if err != nil {
+		return err
+	}
+
+	if transaction.Status != model.TransactionStatusEnumPending {
+		return nil
+	}
+
+	return repos.Transaction.UpdateByID(ctx, model.Transaction{
+		Status: model.TransactionStatusEnumFailed,
+	}, transactionID)
+}
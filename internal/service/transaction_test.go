@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"e-resep-be/internal/model"
+	"e-resep-be/internal/requester"
+	"reflect"
+	"testing"
+)
+
+// fakeKimiaFarmaRequester answer availability from a fixed table, other calls are not expected
+type fakeKimiaFarmaRequester struct {
+	requester.KimiaFarmaRequester
+	items map[string]model.CheckAvailabilityResponse
+}
+
+func (f *fakeKimiaFarmaRequester) CheckAvailabilityBatch(ctx context.Context, kfaCodes []string) (*model.CheckAvailabilityBatchResponse, error) {
+	resp := model.NewCheckAvailabilityBatchResponse()
+	for _, code := range kfaCodes {
+		resp.Items[code] = f.items[code]
+	}
+
+	return resp, nil
+}
+
+func TestDerivePrices(t *testing.T) {
+	medications := []model.MedicationDB{
+		{ID: 1, Code: "KFA-1"},
+		{ID: 2, Code: "KFA-2"},
+	}
+
+	tests := []struct {
+		name    string
+		items   map[string]model.CheckAvailabilityResponse
+		quote   *model.PriceQuote
+		want    map[int]int
+		wantErr bool
+	}{
+		{
+			name: "price from pharmacy",
+			items: map[string]model.CheckAvailabilityResponse{
+				"KFA-1": {IsAvailable: true, Price: 1000},
+				"KFA-2": {IsAvailable: true, Price: 2000},
+			},
+			want: map[int]int{1: 1000, 2: 2000},
+		},
+		{
+			name: "price locked by quote",
+			items: map[string]model.CheckAvailabilityResponse{
+				"KFA-1": {IsAvailable: true, Price: 1500},
+				"KFA-2": {IsAvailable: true, Price: 2500},
+			},
+			quote: &model.PriceQuote{Items: []model.Item{{ID: 1, Price: 1000}, {ID: 2, Price: 2000}}},
+			want:  map[int]int{1: 1000, 2: 2000},
+		},
+		{
+			name: "unavailable without quote",
+			items: map[string]model.CheckAvailabilityResponse{
+				"KFA-1": {IsAvailable: true, Price: 1000},
+				"KFA-2": {IsAvailable: false, Price: 2000},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unavailable after quote is made",
+			items: map[string]model.CheckAvailabilityResponse{
+				"KFA-1": {IsAvailable: false, Price: 1000},
+				"KFA-2": {IsAvailable: true, Price: 2000},
+			},
+			quote:   &model.PriceQuote{Items: []model.Item{{ID: 1, Price: 1000}, {ID: 2, Price: 2000}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := &TransactionServiceImpl{KimiaFarmaRequester: &fakeKimiaFarmaRequester{items: tt.items}}
+
+			got, err := ts.derivePrices(context.Background(), tt.quote, medications)
+			if tt.wantErr {
+				if model.KindOf(err) != model.Validation {
+					t.Fatalf("error = %v, want validation error", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("derivePrices() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("derivePrices() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package stub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+// courierStubPrice is the price of every shipment booked on courier stub
+const courierStubPrice = 15000
+
+// courierStub keep booked shipments in memory, shipment is lost when the stub server restart
+type courierStub struct {
+	config     *config.Configuration
+	httpClient *http.Client
+
+	mu           sync.Mutex
+	shipments    map[string]*model.CourierShipment
+	idempotency  map[string]string
+	eventCounter int
+}
+
+// registerCourier register courier stub routes, the app is pointed here by COURIER_URL.
+// Shipment status is moved manually through POST /v1/shipments/:id/status, which post the webhook to STUB_COURIER_CALLBACK_URL
+func registerCourier(g *echo.Group, config *config.Configuration) {
+	s := &courierStub{
+		config:      config,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		shipments:   map[string]*model.CourierShipment{},
+		idempotency: map[string]string{},
+	}
+
+	g.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			apiKey := strings.TrimPrefix(ctx.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if apiKey == "" || (config.Courier.CourierAPIKey != "" && apiKey != config.Courier.CourierAPIKey) {
+				return serveFixture(ctx, http.StatusUnauthorized, "fixtures/courier/unauthorized.json")
+			}
+
+			return next(ctx)
+		}
+	})
+
+	g.POST("/v1/shipments", s.createShipment)
+	g.GET("/v1/shipments/:id", s.getShipment)
+	g.POST("/v1/shipments/:id/status", s.updateShipmentStatus)
+}
+
+// createShipment book shipment in allocating status, request with the same idempotency key return the first shipment
+func (s *courierStub) createShipment(ctx echo.Context) error {
+	var req model.CourierShipmentRequest
+	if err := ctx.Bind(&req); err != nil || req.ReferenceID == "" || len(req.Items) == 0 {
+		return serveFixture(ctx, http.StatusBadRequest, "fixtures/courier/bad_request.json")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := ctx.Request().Header.Get("Idempotency-Key")
+	if id, ok := s.idempotency[key]; ok && key != "" {
+		return ctx.JSON(http.StatusCreated, model.CourierShipmentResponse{Data: *s.shipments[id]})
+	}
+
+	seq := len(s.shipments) + 1
+	shipment := model.CourierShipment{
+		ID:             fmt.Sprintf("shp_stub_%d", seq),
+		ReferenceID:    req.ReferenceID,
+		TrackingNumber: fmt.Sprintf("STUB%08d", seq),
+		TrackingURL:    fmt.Sprintf("https://courier.example.com/track/STUB%08d", seq),
+		Status:         model.CourierStatusEnumAllocating,
+		Price:          courierStubPrice,
+		UpdatedAt:      time.Now().UTC(),
+	}
+
+	s.shipments[shipment.ID] = &shipment
+	if key != "" {
+		s.idempotency[key] = shipment.ID
+	}
+
+	return ctx.JSON(http.StatusCreated, model.CourierShipmentResponse{Data: shipment})
+}
+
+func (s *courierStub) getShipment(ctx echo.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shipment, ok := s.shipments[ctx.Param("id")]
+	if !ok {
+		return serveFixture(ctx, http.StatusNotFound, "fixtures/courier/not_found.json")
+	}
+
+	return ctx.JSON(http.StatusOK, model.CourierShipmentResponse{Data: *shipment})
+}
+
+// updateShipmentStatus move shipment into requested status and post the courier webhook
+func (s *courierStub) updateShipmentStatus(ctx echo.Context) error {
+	var req struct {
+		Status model.CourierStatusEnum `json:"status"`
+	}
+	if err := ctx.Bind(&req); err != nil || req.Status == "" {
+		return serveFixture(ctx, http.StatusBadRequest, "fixtures/courier/bad_request.json")
+	}
+
+	s.mu.Lock()
+	shipment, ok := s.shipments[ctx.Param("id")]
+	if !ok {
+		s.mu.Unlock()
+		return serveFixture(ctx, http.StatusNotFound, "fixtures/courier/not_found.json")
+	}
+
+	shipment.Status = req.Status
+	shipment.UpdatedAt = time.Now().UTC()
+	s.eventCounter++
+
+	notification := model.CourierNotification{
+		EventID: fmt.Sprintf("evt_stub_%d", s.eventCounter),
+		Data:    *shipment,
+	}
+	s.mu.Unlock()
+
+	if err := s.sendNotification(ctx, &notification); err != nil {
+		log.Println("courier stub webhook ERROR", err)
+	}
+
+	return ctx.JSON(http.StatusOK, model.CourierShipmentResponse{Data: notification.Data})
+}
+
+// sendNotification post courier webhook to STUB_COURIER_CALLBACK_URL, it is skipped when the url is not configured
+func (s *courierStub) sendNotification(ctx echo.Context, notification *model.CourierNotification) error {
+	if s.config.Stub.StubCourierCallbackURL == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx.Request().Context(), http.MethodPost, s.config.Stub.StubCourierCallbackURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("x-callback-token", s.config.Courier.CourierCallbackToken)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("courier callback responded %d", resp.StatusCode)
+	}
+
+	return nil
+}
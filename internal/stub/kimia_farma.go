@@ -0,0 +1,90 @@
+package stub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	"e-resep-be/internal/config"
+	"e-resep-be/internal/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registerKimiaFarma register kimia farma stub routes, see requester.KimiaFarmaRequesterImpl for the wire contract
+func registerKimiaFarma(g *echo.Group, config *config.Configuration) {
+	g.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if config.KimiaFarma.KimiaFarmaAPIKey != "" && ctx.Request().Header.Get("X-API-Key") != config.KimiaFarma.KimiaFarmaAPIKey {
+				return serveFixture(ctx, http.StatusUnauthorized, "fixtures/kimia_farma/unauthorized.json")
+			}
+
+			return next(ctx)
+		}
+	})
+
+	g.GET("/v1/medications/:kfa_code/availability", func(ctx echo.Context) error {
+		path := fmt.Sprintf("fixtures/kimia_farma/availability/%s.json", ctx.Param("kfa_code"))
+		if _, err := fs.Stat(fixtures, path); err != nil {
+			return serveFixture(ctx, http.StatusNotFound, "fixtures/kimia_farma/not_found.json")
+		}
+
+		return serveFixture(ctx, http.StatusOK, path)
+	})
+
+	// batch response is assembled from the recorded single availability fixtures
+	g.POST("/v1/medications/availability", func(ctx echo.Context) error {
+		var req model.KimiaFarmaBatchAvailabilityRequest
+		if err := ctx.Bind(&req); err != nil {
+			return serveFixture(ctx, http.StatusBadRequest, "fixtures/kimia_farma/bad_request.json")
+		}
+
+		resp := model.KimiaFarmaBatchAvailabilityResponse{
+			Data:   []model.CheckAvailabilityResponse{},
+			Errors: []model.KimiaFarmaBatchError{},
+		}
+
+		for _, code := range req.KFACodes {
+			var fixture model.KimiaFarmaAvailabilityResponse
+
+			b, err := fixtures.ReadFile(fmt.Sprintf("fixtures/kimia_farma/availability/%s.json", code))
+			if err == nil {
+				err = json.Unmarshal(b, &fixture)
+			}
+
+			if err != nil {
+				resp.Errors = append(resp.Errors, model.KimiaFarmaBatchError{
+					KFACode: code,
+					KimiaFarmaError: model.KimiaFarmaError{
+						Code:    "MEDICATION_NOT_FOUND",
+						Message: "medication not found",
+					},
+				})
+				continue
+			}
+
+			resp.Data = append(resp.Data, fixture.Data)
+		}
+
+		return ctx.JSON(http.StatusOK, resp)
+	})
+	// dispense order is always received, the requested reference is echoed back
+	g.POST("/v1/dispense-orders", func(ctx echo.Context) error {
+		var req model.KimiaFarmaDispenseOrderRequest
+		if err := ctx.Bind(&req); err != nil || req.ReferenceID == "" || len(req.Items) == 0 {
+			return serveFixture(ctx, http.StatusBadRequest, "fixtures/kimia_farma/bad_request.json")
+		}
+
+		resp, err := readFixtureObject("fixtures/kimia_farma/dispense_order.json")
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
+		}
+
+		data, _ := resp["data"].(map[string]interface{})
+		data["order_id"] = "KF-ORD-" + req.ReferenceID
+		data["reference_id"] = req.ReferenceID
+
+		return ctx.JSON(http.StatusCreated, resp)
+	})
+}
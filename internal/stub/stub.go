@@ -0,0 +1,39 @@
+package stub
+
+import (
+	"embed"
+	"net/http"
+
+	"e-resep-be/internal/config"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// fixtures contains recorded partner responses served by the stub server
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// NewServer return echo instance that imitate partner APIs using recorded fixtures, so the app can run offline
+func NewServer(config *config.Configuration) *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+	e.Use(middleware.Logger())
+
+	registerKimiaFarma(e.Group("/kimia-farma"), config)
+	registerXendit(e.Group("/xendit"), config)
+	registerCourier(e.Group("/courier"), config)
+
+	return e
+}
+
+// serveFixture write fixture file as JSON response with given status code
+func serveFixture(ctx echo.Context, statusCode int, path string) error {
+	b, err := fixtures.ReadFile(path)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
+	}
+
+	return ctx.JSONBlob(statusCode, b)
+}
@@ -0,0 +1,109 @@
+package stub
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"e-resep-be/internal/config"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xendit/xendit-go/v6/payment_request"
+	"github.com/xendit/xendit-go/v6/refund"
+)
+
+// paymentRequestFixtures map payment method type into its recorded payment request
+var paymentRequestFixtures = map[payment_request.PaymentMethodType]string{
+	payment_request.PAYMENTMETHODTYPE_VIRTUAL_ACCOUNT: "fixtures/xendit/payment_request/virtual_account.json",
+	payment_request.PAYMENTMETHODTYPE_QR_CODE:         "fixtures/xendit/payment_request/qr_code.json",
+	payment_request.PAYMENTMETHODTYPE_EWALLET:         "fixtures/xendit/payment_request/ewallet.json",
+}
+
+// registerXendit register xendit stub routes, xendit sdk is pointed here by XENDIT_URL
+func registerXendit(g *echo.Group, config *config.Configuration) {
+	g.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if apiKey, _, ok := ctx.Request().BasicAuth(); !ok || (config.Xendit.XenditAPIKey != "" && apiKey != config.Xendit.XenditAPIKey) {
+				return serveFixture(ctx, http.StatusUnauthorized, "fixtures/xendit/unauthorized.json")
+			}
+
+			return next(ctx)
+		}
+	})
+
+	// refund is always accepted as pending, the requested invoice, reference and amount are echoed back
+	g.POST("/refunds", func(ctx echo.Context) error {
+		var req refund.CreateRefund
+		if err := ctx.Bind(&req); err != nil {
+			return serveFixture(ctx, http.StatusBadRequest, "fixtures/xendit/bad_request.json")
+		}
+
+		resp, err := readFixtureObject("fixtures/xendit/refund.json")
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
+		}
+
+		resp["invoice_id"] = req.InvoiceId
+		resp["reference_id"] = req.ReferenceId
+		resp["amount"] = req.Amount
+		resp["metadata"] = req.Metadata
+
+		if req.PaymentRequestId != nil {
+			resp["payment_request_id"] = req.PaymentRequestId
+		}
+
+		return ctx.JSON(http.StatusOK, resp)
+	})
+
+	// payment request is served from the fixture of requested payment method type, the requested reference and amount are echoed back
+	g.POST("/payment_requests", func(ctx echo.Context) error {
+		var req payment_request.PaymentRequestParameters
+		if err := ctx.Bind(&req); err != nil || req.PaymentMethod == nil {
+			return serveFixture(ctx, http.StatusBadRequest, "fixtures/xendit/bad_request.json")
+		}
+
+		path, ok := paymentRequestFixtures[req.PaymentMethod.Type]
+		if !ok {
+			return serveFixture(ctx, http.StatusBadRequest, "fixtures/xendit/bad_request.json")
+		}
+
+		resp, err := readFixtureObject(path)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
+		}
+
+		resp["reference_id"] = req.ReferenceId
+		resp["amount"] = req.Amount
+		resp["metadata"] = req.Metadata
+
+		paymentMethod, _ := resp["payment_method"].(map[string]interface{})
+		if va := req.PaymentMethod.VirtualAccount.Get(); va != nil && paymentMethod != nil {
+			fixtureVA, _ := paymentMethod["virtual_account"].(map[string]interface{})
+			fixtureVA["channel_code"] = va.ChannelCode
+
+			channelProperties, _ := fixtureVA["channel_properties"].(map[string]interface{})
+			channelProperties["customer_name"] = va.ChannelProperties.CustomerName
+		}
+
+		if ewallet := req.PaymentMethod.Ewallet.Get(); ewallet != nil && ewallet.ChannelCode != nil && paymentMethod != nil {
+			fixtureEwallet, _ := paymentMethod["ewallet"].(map[string]interface{})
+			fixtureEwallet["channel_code"] = ewallet.ChannelCode
+		}
+
+		return ctx.JSON(http.StatusCreated, resp)
+	})
+}
+
+// readFixtureObject read json object fixture, so the stub can echo requested fields back
+func readFixtureObject(path string) (map[string]interface{}, error) {
+	b, err := fixtures.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// Job is a function that is run periodically by worker
+	Job func(ctx context.Context) error
+
+	// Worker run a job periodically in background until its context is cancelled
+	Worker struct {
+		Name     string
+		Interval time.Duration
+		Logger   *logrus.Logger
+		Job      Job
+
+		done chan struct{}
+	}
+)
+
+// NewWorker return new instances worker
+func NewWorker(name string, interval time.Duration, logger *logrus.Logger, job Job) *Worker {
+	return &Worker{
+		Name:     name,
+		Interval: interval,
+		Logger:   logger,
+		Job:      job,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start run the job every interval in a separate goroutine, the first run start after one interval
+func (w *Worker) Start(ctx context.Context) {
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+
+		w.Logger.Info("WORKER STARTED: ", w.Name)
+
+		for {
+			select {
+			case <-ctx.Done():
+				w.Logger.Info("WORKER STOPPED: ", w.Name)
+
+				return
+			case <-ticker.C:
+				w.run(ctx)
+			}
+		}
+	}()
+}
+
+// Wait block until worker is stopped, the running job is always finished first
+func (w *Worker) Wait() {
+	<-w.done
+}
+
+func (w *Worker) run(ctx context.Context) {
+	// a panic inside job must not stop the worker
+	defer func() {
+		if r := recover(); r != nil {
+			w.Logger.Error("Worker.run PANIC ", w.Name, " ", r)
+		}
+	}()
+
+	if err := w.Job(ctx); err != nil {
+		w.Logger.Error("Worker.run ERROR ", w.Name, " ", err)
+	}
+}
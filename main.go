@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"e-resep-be/internal/application"
+	"e-resep-be/internal/config"
 	"e-resep-be/internal/infrastructure"
 
 	"github.com/joho/godotenv"
@@ -18,6 +19,7 @@ import (
 const (
 	localServerMode = "local"
 	httpServerMode  = "http"
+	stubServerMode  = "stub"
 )
 
 func main() {
@@ -38,6 +40,17 @@ func main() {
 		mode = os.Args[1]
 	}
 
+	// stub mode only serve recorded partner responses, so it doesn't need database or any app dependencies
+	if mode == stubServerMode {
+		cfg := config.NewConfig()
+		stubServer := infrastructure.ServeStub(cfg)
+		if err := stubServer.Start(fmt.Sprintf(":%d", cfg.Stub.StubPort)); err != nil && err != http.ErrServerClosed {
+			panic(err)
+		}
+
+		return
+	}
+
 	// create a context with background for setup the application
 	ctx := context.Background()
 	app, err := application.SetupApplication(ctx)
@@ -49,9 +62,14 @@ func main() {
 	switch mode {
 	case localServerMode, httpServerMode:
 		var (
-			httpServer = infrastructure.ServeHTTP(app)
+			dep        = application.SetupDependencyInjection(app)
+			httpServer = infrastructure.ServeHTTP(app, dep)
 		)
 
+		// Start background workers, they are stopped before the app is closed
+		workerCtx, stopWorkers := context.WithCancel(context.Background())
+		workers := infrastructure.ServeWorker(workerCtx, app, dep)
+
 		server := &http.Server{
 			Addr:    fmt.Sprintf(":%d", app.Config.Server.AppPort),
 			Handler: httpServer,
@@ -75,6 +93,11 @@ func main() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
+		stopWorkers()
+		for _, w := range workers {
+			w.Wait()
+		}
+
 		app.Close(ctx)
 
 		// Shutdown the server gracefully